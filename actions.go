@@ -66,6 +66,17 @@ const (
 
 	ActionZoomIncrease
 	ActionZoomDecrease
+
+	ActionPastRuns
+	ActionAchievements
+	ActionSafeTravel
+	ActionStealthExplore
+	ActionBestiary
+	ActionToggleVision
+	ActionNoisePreview
+	ActionOverview
+	ActionCycleTheme
+	ActionWizardExportState
 )
 
 var ConfigurableKeyActions = [...]action{
@@ -84,6 +95,7 @@ var ConfigurableKeyActions = [...]action{
 	ActionExamine,
 	ActionGoToStairs,
 	ActionExplore,
+	ActionStealthExplore,
 	ActionLogs,
 	ActionDump,
 	ActionSave,
@@ -94,8 +106,12 @@ var ConfigurableKeyActions = [...]action{
 	ActionNextObject,
 	ActionNextStairs,
 	ActionTarget,
+	ActionSafeTravel,
 	ActionExclude,
-	ActionClearExclude}
+	ActionClearExclude,
+	ActionToggleVision,
+	ActionNoisePreview,
+	ActionOverview}
 
 func (k action) normalModeAction() bool {
 	switch k {
@@ -105,6 +121,7 @@ func (k action) normalModeAction() bool {
 		ActionDescend,
 		ActionGoToStairs,
 		ActionExplore,
+		ActionStealthExplore,
 		ActionExamine,
 		ActionEvoke,
 		ActionInteract,
@@ -115,6 +132,9 @@ func (k action) normalModeAction() bool {
 		ActionMenu,
 		ActionMenuCommandHelp,
 		ActionMenuTargetingHelp,
+		ActionPastRuns,
+		ActionAchievements,
+		ActionBestiary,
 		ActionSave,
 		ActionQuit,
 		ActionSettings,
@@ -122,8 +142,13 @@ func (k action) normalModeAction() bool {
 		ActionWizardMenu,
 		ActionWizardInfo,
 		ActionWizardToggleMode,
+		ActionWizardExportState,
 		ActionZoomIncrease,
-		ActionZoomDecrease:
+		ActionZoomDecrease,
+		ActionToggleVision,
+		ActionNoisePreview,
+		ActionOverview,
+		ActionCycleTheme:
 		return true
 	default:
 		return false
@@ -156,6 +181,8 @@ func (k action) String() (text string) {
 		text = "Go to nearest stairs"
 	case ActionExplore:
 		text = "Autoexplore"
+	case ActionStealthExplore:
+		text = "Stealthy autoexplore"
 	case ActionExamine:
 		text = "Examine"
 	case ActionEvoke:
@@ -180,6 +207,12 @@ func (k action) String() (text string) {
 		text = "Help (keyboard examine mode)"
 	case ActionSettings:
 		text = "Settings and key bindings"
+	case ActionPastRuns:
+		text = "Past runs"
+	case ActionAchievements:
+		text = "Achievements"
+	case ActionBestiary:
+		text = "Bestiary"
 	case ActionWizard:
 		text = "Wizard (debug) mode"
 	case ActionWizardMenu:
@@ -194,10 +227,20 @@ func (k action) String() (text string) {
 		text = "Toggle tiles/ascii display"
 	case ActionToggleShowNumbers:
 		text = "Toggle hearts/numbers"
+	case ActionToggleVision:
+		text = "Toggle monster vision overlay"
+	case ActionNoisePreview:
+		text = "Cycle noise preview"
+	case ActionOverview:
+		text = "Level overview"
+	case ActionCycleTheme:
+		text = "Cycle color theme"
 	case ActionWizardInfo:
 		text = "Info"
 	case ActionWizardToggleMode:
 		text = "toggle normal/map/all wizard mode"
+	case ActionWizardExportState:
+		text = "export game state as JSON"
 	case ActionZoomIncrease:
 		text = "increase zoom"
 	case ActionZoomDecrease:
@@ -236,10 +279,16 @@ func (k action) targetingModeDescription() (text string) {
 		text = "Target next stairs"
 	case ActionTarget:
 		text = "Go to"
+	case ActionSafeTravel:
+		text = "Go to by a safe path"
 	case ActionExclude:
 		text = "exclude area from auto-travel"
 	case ActionClearExclude:
 		text = "revert exclude area from auto-travel"
+	case ActionToggleVision:
+		text = "Toggle monster vision overlay"
+	case ActionNoisePreview:
+		text = "Cycle noise preview"
 	case ActionEscape:
 		text = "Quit targeting mode"
 	}
@@ -255,8 +304,11 @@ func (k action) targetingModeAction() bool {
 		ActionNextObject,
 		ActionNextStairs,
 		ActionTarget,
+		ActionSafeTravel,
 		ActionExclude,
 		ActionClearExclude,
+		ActionToggleVision,
+		ActionNoisePreview,
 		ActionEscape:
 		return true
 	default:
@@ -307,6 +359,7 @@ func (md *model) normalModeAction(action action) (again bool, eff gruid.Effect,
 		err = actionErrorUnknown
 	case ActionW, ActionS, ActionN, ActionE:
 		if !md.targ.kbTargeting {
+			g.record(simAction{Kind: SimMove, Dir: keyToDir(action)})
 			again, err = g.PlayerBump(g.Player.P.Add(keyToDir(action)))
 		} else {
 			p := md.targ.ex.p.Add(keyToDir(action))
@@ -317,6 +370,7 @@ func (md *model) normalModeAction(action action) (again bool, eff gruid.Effect,
 		}
 	case ActionRunW, ActionRunS, ActionRunN, ActionRunE:
 		if !md.targ.kbTargeting {
+			g.record(simAction{Kind: SimRun, Dir: keyToDir(action)})
 			again, err = g.GoToDir(keyToDir(action))
 		} else {
 			q := invalidPos
@@ -335,10 +389,16 @@ func (md *model) normalModeAction(action action) (again bool, eff gruid.Effect,
 		}
 	case ActionExclude:
 		again = true
-		md.excludeZone(md.targ.ex.p)
+		if valid(md.targ.ex.p) {
+			g.record(simAction{Kind: SimExclude, Target: md.targ.ex.p})
+			g.ExcludeZone(md.targ.ex.p)
+		}
 	case ActionClearExclude:
 		again = true
-		md.clearExcludeZone(md.targ.ex.p)
+		if valid(md.targ.ex.p) {
+			g.record(simAction{Kind: SimClearExclude, Target: md.targ.ex.p})
+			g.ClearExcludeZone(md.targ.ex.p)
+		}
 	case ActionPreviousMonster:
 		again = true
 		md.nextMonster("-", md.targ.ex.p, md.targ.ex)
@@ -354,6 +414,17 @@ func (md *model) normalModeAction(action action) (again bool, eff gruid.Effect,
 		if err != nil {
 			break
 		}
+		g.record(simAction{Kind: SimTravel, Target: md.targ.ex.p})
+		if g.MoveToTarget() {
+			again = false
+		}
+	case ActionSafeTravel:
+		again = true
+		err = g.SetSafeAutoTarget(md.targ.ex.p)
+		if err != nil {
+			break
+		}
+		g.record(simAction{Kind: SimSafeTravel, Target: md.targ.ex.p})
 		if g.MoveToTarget() {
 			again = false
 		}
@@ -361,6 +432,7 @@ func (md *model) normalModeAction(action action) (again bool, eff gruid.Effect,
 		again = true
 		md.CancelExamine()
 	case ActionWaitTurn:
+		g.record(simAction{Kind: SimWait})
 		g.WaitTurn()
 	case ActionGoToStairs:
 		again = true
@@ -376,7 +448,10 @@ func (md *model) normalModeAction(action action) (again bool, eff gruid.Effect,
 			err = md.target()
 			if err != nil {
 				err = errors.New("There is no safe path to the nearest stairs.")
-			} else if !g.MoveToTarget() {
+				break
+			}
+			g.record(simAction{Kind: SimTravel, Target: stair})
+			if !g.MoveToTarget() {
 				err = errors.New("You could not move toward stairs.")
 			}
 		} else {
@@ -384,13 +459,17 @@ func (md *model) normalModeAction(action action) (again bool, eff gruid.Effect,
 		}
 	case ActionInteract:
 		c := g.Dungeon.Cell(g.Player.P)
+		if terrain(c) != MagaraCell {
+			// magaras are equipped from the menu
+			g.record(simAction{Kind: SimInteract})
+		}
 		switch terrain(c) {
 		case StairCell:
 			if terrain(g.Dungeon.Cell(g.Player.P)) == StairCell && g.Objects.Stairs[g.Player.P] != BlockedStair {
 				// TODO: animation
 				//ui.MenuSelectedAnimation(MenuInteract, true)
 				strt := g.Objects.Stairs[g.Player.P]
-				err = md.checkShaedra(strt)
+				err = g.checkShaedra(strt)
 				if err != nil {
 					break
 				}
@@ -427,14 +506,7 @@ func (md *model) normalModeAction(action action) (again bool, eff gruid.Effect,
 		case LightCell:
 			err = g.ExtinguishFire()
 		case StoryCell:
-			if g.Objects.Story[g.Player.P] == StoryArtifact && !g.LiberatedArtifact {
-				g.PushEventFirst(&playerEvent{Action: StorySequence}, g.Turn)
-				g.LiberatedArtifact = true
-			} else if g.Objects.Story[g.Player.P] == StoryArtifactSealed {
-				err = errors.New("The artifact is protected by a magical stone barrier.")
-			} else {
-				err = errors.New("You cannot interact with anything here.")
-			}
+			err = g.InteractStory()
 		default:
 			err = errors.New("You cannot interact with anything here.")
 		}
@@ -445,7 +517,11 @@ func (md *model) normalModeAction(action action) (again bool, eff gruid.Effect,
 		again = true
 		md.openIventory()
 	case ActionExplore:
+		g.record(simAction{Kind: SimExplore})
 		again, err = g.Autoexplore()
+	case ActionStealthExplore:
+		g.record(simAction{Kind: SimStealthExplore})
+		again, err = g.StealthAutoexplore()
 	case ActionExamine:
 		again = true
 		md.KeyboardExamine()
@@ -459,6 +535,15 @@ func (md *model) normalModeAction(action action) (again bool, eff gruid.Effect,
 	case ActionMenuTargetingHelp:
 		again = true
 		md.ExamineHelp()
+	case ActionPastRuns:
+		again = true
+		md.openPastRuns()
+	case ActionAchievements:
+		again = true
+		md.openAchievements()
+	case ActionBestiary:
+		again = true
+		md.openBestiary()
 	case ActionMenu:
 		again = true
 		md.openMenu()
@@ -507,13 +592,10 @@ func (md *model) normalModeAction(action action) (again bool, eff gruid.Effect,
 			err = actionErrorUnknown
 		}
 	case ActionWizardDescend:
-		if g.Wizard && g.Depth == WinDepth && !g.LiberatedShaedra {
-			g.RescuedShaedra()
-		}
 		if g.Wizard && g.Depth < MaxDepth {
-			g.StoryPrint("Descended wizardly")
+			g.record(simAction{Kind: SimWizardDescend})
 			again = true
-			if g.Descend(DescendNormal) {
+			if g.WizardDescend() {
 				md.win()
 			}
 		} else {
@@ -521,7 +603,9 @@ func (md *model) normalModeAction(action action) (again bool, eff gruid.Effect,
 		}
 	case ActionWizard:
 		again = true
-		if !md.g.Wizard {
+		if md.g.Daily != "" {
+			md.g.Print("Wizard mode is not available in daily challenges.")
+		} else if !md.g.Wizard {
 			md.g.PrintStyled("Do you really want to enter wizard mode (irreversible)? [y/N]", logConfirm)
 			md.mode = modeWizardConfirmation
 		}
@@ -562,6 +646,33 @@ func (md *model) normalModeAction(action action) (again bool, eff gruid.Effect,
 		}
 		md.updateStatusInfo()
 		md.mode = modeNormal
+	case ActionToggleVision:
+		again = true
+		GameConfig.VisionOverlay = !GameConfig.VisionOverlay
+		err := SaveConfig()
+		if err != nil {
+			g.Print(err.Error())
+		}
+		md.mode = modeNormal
+	case ActionNoisePreview:
+		again = true
+		md.cycleNoisePreview()
+	case ActionOverview:
+		again = true
+		md.openOverview()
+	case ActionCycleTheme:
+		again = true
+		th := nextTheme()
+		GameConfig.Theme = th.Name
+		err := SaveConfig()
+		if err != nil {
+			g.Print(err.Error())
+		}
+		applyThemeConf()
+		clearCache()
+		g.Printf("Color theme: %s.", th.Name)
+		eff = gruid.Cmd(func() gruid.Msg { return gruid.MsgScreen{} })
+		md.mode = modeNormal
 	case ActionWizardInfo:
 		again = true
 		md.wizardInfo()
@@ -575,6 +686,21 @@ func (md *model) normalModeAction(action action) (again bool, eff gruid.Effect,
 			g.WizardMode = WizardNormal
 		}
 		md.mode = modeNormal
+	case ActionWizardExportState:
+		if !g.Wizard {
+			err = actionErrorUnknown
+			break
+		}
+		again = true
+		md.mode = modeNormal
+		file, err := g.WriteState()
+		if err != nil {
+			g.PrintfStyled("Error: %v", logError, err)
+		} else if file != "" {
+			g.Printf("Game state written to %s.", file)
+		} else {
+			g.Print("Game state written.")
+		}
 	case ActionZoomIncrease:
 		again = true
 		if md.zoomlevel >= zoomMax {
@@ -628,16 +754,7 @@ func (md *model) readScroll() {
 		}
 		md.smallPager.SetLines(stts)
 		md.smallPager.SetCursor(gruid.Point{0, 0})
-		if !md.g.Stats.Lore[md.g.Depth] {
-			md.g.StoryPrint("Read lore message")
-		}
-		md.g.Stats.Lore[md.g.Depth] = true
-		if len(md.g.Stats.Lore) == 4 {
-			AchLoreStudent.Get(md.g)
-		}
-		if len(md.g.Stats.Lore) == len(md.g.Params.Lore) {
-			AchLoremaster.Get(md.g)
-		}
+		md.g.ReadLore()
 	default:
 		md.smallPager.SetBox(&ui.Box{Title: ui.Text("Story Message").WithStyle(st.WithFg(ColorCyan))})
 		stts := []ui.StyledText{}
@@ -650,6 +767,20 @@ func (md *model) readScroll() {
 	}
 }
 
+// ReadLore records that the lore message of the current depth has been read.
+func (g *game) ReadLore() {
+	if !g.Stats.Lore[g.Depth] {
+		g.StoryPrint("Read lore message")
+	}
+	g.Stats.Lore[g.Depth] = true
+	if len(g.Stats.Lore) == 4 {
+		AchLoreStudent.Get(g)
+	}
+	if len(g.Stats.Lore) == len(g.Params.Lore) {
+		AchLoremaster.Get(g)
+	}
+}
+
 type actionError int
 
 const (
@@ -677,6 +808,8 @@ var settingsActions = []action{
 	ActionSetKeys,
 	ActionInvertLOS,
 	ActionToggleShowNumbers,
+	ActionToggleVision,
+	ActionCycleTheme,
 }
 
 func (md *model) openSettings() {
@@ -741,6 +874,10 @@ var menuActions = []action{
 	ActionLogs,
 	ActionMenuCommandHelp,
 	ActionMenuTargetingHelp,
+	ActionPastRuns,
+	ActionAchievements,
+	ActionBestiary,
+	ActionOverview,
 	ActionSettings,
 	ActionSave,
 	ActionQuit,
@@ -830,6 +967,7 @@ func (md *model) equipMagaraMenu() {
 var wizardActions = []action{
 	ActionWizardInfo,
 	ActionWizardToggleMode,
+	ActionWizardExportState,
 }
 
 func (md *model) openWizardMenu() {
@@ -892,6 +1030,10 @@ func (md *model) KeysHelp() {
 		"Go to nearest stairs", "G",
 		"Run in a direction", "shift+arrows or HJKL",
 		"Autoexplore (use with caution)", "o",
+		"Stealthy autoexplore", "O",
+		"Toggle monster vision overlay", "c",
+		"Cycle noise preview", "n",
+		"Level overview (travel, export)", "X",
 		"Write game statistics to file", "#",
 		"Quit without saving", "Q",
 		"Change settings and key bindings", "=",
@@ -906,17 +1048,33 @@ func (md *model) ExamineHelp() {
 	md.updateKeysDescription("Examine Commands", []string{
 		"Move cursor", "arrows or wasd or hjkl",
 		"Go to/select target", "“.” or enter",
+		"Go to by a safe path", "T",
 		"Cycle through monsters", "+",
 		"Cycle through stairs", ">",
 		"Cycle through objects", "o",
 		"Exclude area from auto-travel", "e",
+		"Toggle monster vision overlay", "c",
+		"Cycle noise preview", "n",
 		"Revert exclude area from auto-travel", "r",
 		"Close/cancel examination mode", "x or esc or space",
 	})
 }
 
-func (md *model) checkShaedra(st stair) (err error) {
-	g := md.g
+// InteractStory handles interaction with a story cell: only the artifact can
+// be taken, once the barrier protecting it has been removed.
+func (g *game) InteractStory() (err error) {
+	if g.Objects.Story[g.Player.P] == StoryArtifact && !g.LiberatedArtifact {
+		g.PushEventFirst(&playerEvent{Action: StorySequence}, g.Turn)
+		g.LiberatedArtifact = true
+	} else if g.Objects.Story[g.Player.P] == StoryArtifactSealed {
+		err = errors.New("The artifact is protected by a magical stone barrier.")
+	} else {
+		err = errors.New("You cannot interact with anything here.")
+	}
+	return err
+}
+
+func (g *game) checkShaedra(st stair) (err error) {
 	if g.Depth == WinDepth && st == NormalStair && terrain(g.Dungeon.Cell(g.Places.Shaedra)) == StoryCell {
 		err = errors.New("You have to rescue Shaedra first!")
 	}
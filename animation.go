@@ -32,6 +32,13 @@ type AnimFrame struct {
 
 type msgAnim int
 
+// animsDisabled reports whether animations should be skipped, either because
+// they were disabled by the user, or because the game runs headless without a
+// model.
+func (md *model) animsDisabled() bool {
+	return md == nil || DisableAnimations
+}
+
 func (md *model) initAnimations() {
 	gd := md.gd.Slice(md.gd.Range().Shift(0, 2, 0, -1))
 	max := gd.Size()
@@ -125,7 +132,7 @@ func (a *Animations) Frame(d time.Duration) {
 }
 
 func (md *model) SwappingAnimation(mp, pp gruid.Point) {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	md.startAnimSeq()
@@ -141,7 +148,7 @@ func (md *model) SwappingAnimation(mp, pp gruid.Point) {
 }
 
 func (md *model) TeleportAnimation(from, to gruid.Point, showto bool) {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	md.startAnimSeq()
@@ -166,7 +173,7 @@ const (
 )
 
 func (md *model) MonsterProjectileAnimation(ray []gruid.Point, r rune, fg gruid.Color) {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	md.startAnimSeq()
@@ -210,7 +217,7 @@ func (md *model) ExplosionDrawAt(p gruid.Point, fg gruid.Color) {
 }
 
 func (md *model) NoiseAnimation(noises []gruid.Point) {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	md.LOSWavesAnimation(DefaultLOSRange, WaveMagicNoise, md.g.Player.P)
@@ -230,7 +237,7 @@ func (md *model) NoiseAnimation(noises []gruid.Point) {
 }
 
 func (md *model) ExplosionAnimation(es explosionStyle, p gruid.Point) {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	g := md.g
@@ -278,6 +285,9 @@ func (g *game) Waves(maxCost int, ws wavestyle, center gruid.Point) (dists []int
 }
 
 func (md *model) LOSWavesAnimation(r int, ws wavestyle, center gruid.Point) {
+	if md.animsDisabled() {
+		return
+	}
 	dists, cdists := md.g.Waves(r, ws, center)
 	for _, d := range dists {
 		wave := cdists[d]
@@ -300,7 +310,7 @@ const (
 )
 
 func (md *model) WaveAnimation(wave []int, ws wavestyle) {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	md.startAnimSeq()
@@ -341,7 +351,7 @@ func (md *model) WaveAnimation(wave []int, ws wavestyle) {
 }
 
 func (md *model) WallExplosionAnimation(p gruid.Point) {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	md.startAnimSeq()
@@ -363,7 +373,7 @@ const (
 )
 
 func (md *model) BeamsAnimation(ray []gruid.Point, bs beamstyle) {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	md.startAnimSeq()
@@ -393,7 +403,7 @@ func (md *model) BeamsAnimation(ray []gruid.Point, bs beamstyle) {
 }
 
 func (md *model) SlowingMagaraAnimation(ray []gruid.Point) {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	md.startAnimSeq()
@@ -429,7 +439,7 @@ func (md *model) ProjectileSymbol(dir gruid.Point) (r rune) {
 }
 
 func (md *model) MonsterJavelinAnimation(ray []gruid.Point, hit bool) {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	g := md.g
@@ -446,7 +456,7 @@ func (md *model) MonsterJavelinAnimation(ray []gruid.Point, hit bool) {
 }
 
 func (md *model) WoundedAnimation() {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	g := md.g
@@ -461,7 +471,7 @@ func (md *model) WoundedAnimation() {
 }
 
 func (md *model) PlayerGoodEffectAnimation() {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	g := md.g
@@ -476,7 +486,7 @@ func (md *model) PlayerGoodEffectAnimation() {
 }
 
 func (md *model) StatusEndAnimation() {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	g := md.g
@@ -487,7 +497,7 @@ func (md *model) StatusEndAnimation() {
 }
 
 func (md *model) EffectAtPPAnimation() {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	g := md.g
@@ -498,7 +508,7 @@ func (md *model) EffectAtPPAnimation() {
 }
 
 func (md *model) FoundFakeStairsAnimation() {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	g := md.g
@@ -508,7 +518,7 @@ func (md *model) FoundFakeStairsAnimation() {
 }
 
 func (md *model) MusicAnimation(p gruid.Point) {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	// TODO: not convinced by this animation
@@ -521,7 +531,7 @@ func (md *model) MusicAnimation(p gruid.Point) {
 }
 
 func (md *model) PushAnimation(path []gruid.Point) {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	if len(path) == 0 {
@@ -539,7 +549,7 @@ func (md *model) PushAnimation(path []gruid.Point) {
 }
 
 func (md *model) MagicMappingAnimation() {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	md.startAnimSeq()
@@ -547,7 +557,7 @@ func (md *model) MagicMappingAnimation() {
 }
 
 func (md *model) AbyssFallAnimation() {
-	if DisableAnimations {
+	if md.animsDisabled() {
 		return
 	}
 	gd := rl.NewGrid(DungeonWidth, DungeonHeight)
@@ -567,68 +577,119 @@ func (md *model) AbyssFallAnimation() {
 }
 
 func (md *model) Story() {
-	switch md.g.Depth {
+	g := md.g
+	switch g.Depth {
 	case WinDepth:
 		md.FreeingShaedra()
 	case MaxDepth:
 		md.TakingArtifact()
 	default:
 		md.mode = modeNormal // should not happen
+		return
 	}
+	if g.StoryStep(md.story) {
+		md.story = 0
+		md.mode = modeNormal
+		return
+	}
+	if md.story == 0 {
+		md.mode = modeStory
+		md.confirm = true
+	}
+	md.story++
 }
 
 func (md *model) FreeingShaedra() {
+	if md.animsDisabled() {
+		return
+	}
 	g := md.g
 	switch md.story {
-	case 0:
-		md.mode = modeStory
-		g.Print("You see Shaedra. She is wounded!")
-		g.PrintStyled("Shaedra: “Oh, it's you, Syu! Let's flee with Marevor's magara!”", logSpecial)
-		md.confirm = true
 	case 1:
-		if !DisableAnimations {
-			md.startAnimSeq()
-			_, _, bg := md.positionDrawing(g.Places.Monolith)
-			md.anims.Draw(g.Places.Monolith, 'Φ', ColorFgMagicPlace, bg)
-			md.anims.Frame(AnimDurMediumLong)
-		}
-		g.Objects.Stairs[g.Places.Monolith] = WinStair
-		g.Dungeon.SetCell(g.Places.Monolith, StairCell)
-		if !DisableAnimations {
-			md.startAnimSeq()
-			md.anims.Frame(AnimDurMediumLong)
-			_, _, bg := md.positionDrawing(g.Places.Marevor)
-			md.anims.Draw(g.Places.Marevor, 'Φ', ColorFgMagicPlace, bg)
-			md.anims.Frame(AnimDurMediumLong)
-		}
-		g.Objects.Story[g.Places.Marevor] = StoryMarevor
-		g.PrintStyled("Marevor: “And what about the mission? Take that magara!”", logSpecial)
-		g.PrintStyled("Shaedra: “Pff, don't be reckless!”", logSpecial)
-		g.PrintStyled("[(x) to continue]", logConfirm)
+		md.startAnimSeq()
+		_, _, bg := md.positionDrawing(g.Places.Monolith)
+		md.anims.Draw(g.Places.Monolith, 'Φ', ColorFgMagicPlace, bg)
+		md.anims.Frame(AnimDurMediumLong)
+		md.startAnimSeq()
+		md.anims.Frame(AnimDurMediumLong)
+		_, _, bg = md.positionDrawing(g.Places.Marevor)
+		md.anims.Draw(g.Places.Marevor, 'Φ', ColorFgMagicPlace, bg)
+		md.anims.Frame(AnimDurMediumLong)
 	case 2:
-		if !DisableAnimations {
-			md.startAnimSeq()
-			_, _, bg := md.positionDrawing(g.Places.Marevor)
-			md.anims.Draw(g.Places.Marevor, 'Φ', ColorFgMagicPlace, bg)
-			md.anims.Draw(g.Places.Shaedra, 'Φ', ColorFgMagicPlace, bg)
-			md.anims.Frame(AnimDurMediumLong)
+		md.startAnimSeq()
+		_, _, bg := md.positionDrawing(g.Places.Marevor)
+		md.anims.Draw(g.Places.Marevor, 'Φ', ColorFgMagicPlace, bg)
+		md.anims.Draw(g.Places.Shaedra, 'Φ', ColorFgMagicPlace, bg)
+		md.anims.Frame(AnimDurMediumLong)
+	}
+}
+
+// StoryStep applies the given step of the current depth's story sequence,
+// and reports whether the sequence is finished. Steps after the first one
+// follow the player's confirmations.
+func (g *game) StoryStep(step int) bool {
+	switch g.Depth {
+	case WinDepth:
+		switch step {
+		case 0:
+			g.Print("You see Shaedra. She is wounded!")
+			g.PrintStyled("Shaedra: “Oh, it's you, Syu! Let's flee with Marevor's magara!”", logSpecial)
+		case 1:
+			g.OpenMonolithPortal()
+			g.MarevorAppears()
+			g.PrintStyled("Marevor: “And what about the mission? Take that magara!”", logSpecial)
+			g.PrintStyled("Shaedra: “Pff, don't be reckless!”", logSpecial)
+			g.PrintStyled("[(x) to continue]", logConfirm)
+		default:
+			g.FreedShaedra()
+			return true
 		}
-		g.Dungeon.SetCell(g.Places.Shaedra, GroundCell)
-		g.Dungeon.SetCell(g.Places.Marevor, ScrollCell)
-		g.Objects.Scrolls[g.Places.Marevor] = ScrollExtended
-		g.RescuedShaedra()
-		md.story = 0
-		md.mode = modeNormal
-		return
+	case MaxDepth:
+		switch step {
+		case 0:
+			g.PrintStyled("You take and use the artifact.", logSpecial)
+		case 1:
+			g.Dungeon.SetCell(g.Places.Artifact, GroundCell)
+			g.OpenMonolithPortal()
+			g.MarevorAppears()
+			g.PrintStyled("Marevor: “Great! Let's escape and find some bones to celebrate!”", logSpecial)
+			g.PrintStyled("Syu: “Sorry, but I prefer bananas!”", logSpecial)
+			g.PrintStyled("[(x) to continue]", logConfirm)
+		default:
+			g.RetrievedArtifact()
+			return true
+		}
+	default:
+		return true
 	}
-	md.story++
+	return false
+}
+
+// OpenMonolithPortal turns the monolith into the magical portal used to
+// escape.
+func (g *game) OpenMonolithPortal() {
+	g.Objects.Stairs[g.Places.Monolith] = WinStair
+	g.Dungeon.SetCell(g.Places.Monolith, StairCell)
+}
+
+// MarevorAppears makes Marevor appear at his story place.
+func (g *game) MarevorAppears() {
+	g.Objects.Story[g.Places.Marevor] = StoryMarevor
+}
+
+// FreedShaedra makes Shaedra leave, with Marevor leaving a message behind.
+func (g *game) FreedShaedra() {
+	g.Dungeon.SetCell(g.Places.Shaedra, GroundCell)
+	g.Dungeon.SetCell(g.Places.Marevor, ScrollCell)
+	g.Objects.Scrolls[g.Places.Marevor] = ScrollExtended
+	g.RescuedShaedra()
 }
 
 func (g *game) RescuedShaedra() {
 	g.Player.Magaras = append(g.Player.Magaras, magara{})
 	g.Player.Inventory.Misc = NoItem
 	g.PrintStyled("You equip the new magara in the artifact's old place.", logSpecial)
-	if RandInt(2) == 0 {
+	if g.randInt(2) == 0 {
 		g.Player.Magaras[len(g.Player.Magaras)-1] = magara{Kind: DispersalMagara, Charges: DispersalMagara.DefaultCharges()}
 	} else {
 		g.Player.Magaras[len(g.Player.Magaras)-1] = magara{Kind: DelayedOricExplosionMagara, Charges: DelayedOricExplosionMagara.DefaultCharges()}
@@ -637,38 +698,21 @@ func (g *game) RescuedShaedra() {
 }
 
 func (md *model) TakingArtifact() {
-	g := md.g
-	switch md.story {
-	case 0:
-		md.mode = modeStory
-		g.PrintStyled("You take and use the artifact.", logSpecial)
-		md.confirm = true
-	case 1:
-		g.Dungeon.SetCell(g.Places.Artifact, GroundCell)
-		if !DisableAnimations {
-			md.startAnimSeq()
-			_, _, bg := md.positionDrawing(g.Places.Monolith)
-			md.anims.Draw(g.Places.Monolith, 'Φ', ColorFgMagicPlace, bg)
-			md.anims.Frame(AnimDurMediumLong)
-		}
-		g.Objects.Stairs[g.Places.Monolith] = WinStair
-		g.Dungeon.SetCell(g.Places.Monolith, StairCell)
-		if !DisableAnimations {
-			md.startAnimSeq()
-			_, _, bg := md.positionDrawing(g.Places.Marevor)
-			md.anims.Draw(g.Places.Marevor, 'Φ', ColorFgMagicPlace, bg)
-			md.anims.Frame(AnimDurMediumLong)
-		}
-		g.Objects.Story[g.Places.Marevor] = StoryMarevor
-		g.PrintStyled("Marevor: “Great! Let's escape and find some bones to celebrate!”", logSpecial)
-		g.PrintStyled("Syu: “Sorry, but I prefer bananas!”", logSpecial)
-		g.PrintStyled("[(x) to continue]", logConfirm)
-	case 2:
-		g.Dungeon.SetCell(g.Places.Marevor, GroundCell)
-		AchRetrievedArtifact.Get(g)
-		md.story = 0
-		md.mode = modeNormal
+	if md.animsDisabled() || md.story != 1 {
 		return
 	}
-	md.story++
+	g := md.g
+	md.startAnimSeq()
+	_, _, bg := md.positionDrawing(g.Places.Monolith)
+	md.anims.Draw(g.Places.Monolith, 'Φ', ColorFgMagicPlace, bg)
+	md.anims.Frame(AnimDurMediumLong)
+	md.startAnimSeq()
+	_, _, bg = md.positionDrawing(g.Places.Marevor)
+	md.anims.Draw(g.Places.Marevor, 'Φ', ColorFgMagicPlace, bg)
+	md.anims.Frame(AnimDurMediumLong)
+}
+
+func (g *game) RetrievedArtifact() {
+	g.Dungeon.SetCell(g.Places.Marevor, GroundCell)
+	AchRetrievedArtifact.Get(g)
 }
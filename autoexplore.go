@@ -7,6 +7,17 @@ import (
 )
 
 func (g *game) Autoexplore() (again bool, err error) {
+	return g.autoexplore(false)
+}
+
+// StealthAutoexplore is like Autoexplore, but it prefers paths hidden from
+// known monsters and waits behind doors when hearing noises, as described in
+// stealthCost.
+func (g *game) StealthAutoexplore() (again bool, err error) {
+	return g.autoexplore(true)
+}
+
+func (g *game) autoexplore(stealth bool) (again bool, err error) {
 	if mons := g.MonsterInLOS(); mons.Exists() {
 		return again, errors.New("You cannot auto-explore while there are monsters in view.")
 	}
@@ -20,6 +31,9 @@ func (g *game) Autoexplore() (again bool, err error) {
 	if len(sources) == 0 {
 		return again, errors.New("Some excluded places remain unexplored.")
 	}
+	g.AutoStealth = stealth
+	g.autoNoiseWait = 0
+	g.autoNoiseWaited = 0
 	g.BuildAutoexploreMap(sources)
 	n, finished := g.NextAuto()
 	if finished || n == nil {
@@ -76,13 +90,25 @@ const unreachable = 9999
 
 func (g *game) BuildAutoexploreMap(sources []gruid.Point) {
 	ap := &autoexplorePath{g: g}
-	g.PRauto.BreadthFirstMap(ap, sources, unreachable)
+	if g.AutoStealth {
+		g.PRauto.DijkstraMap(ap, sources, unreachable)
+	} else {
+		g.PRauto.BreadthFirstMap(ap, sources, unreachable)
+	}
 	g.AutoexploreMapRebuild = false
 }
 
+// autoexploreMapAt returns the cost of p in the last autoexplore map.
+func (g *game) autoexploreMapAt(p gruid.Point) int {
+	if g.AutoStealth {
+		return g.PRauto.DijkstraMapAt(p)
+	}
+	return g.PRauto.BreadthFirstMapAt(p)
+}
+
 func (g *game) NextAuto() (next *gruid.Point, finished bool) {
 	ap := &autoexplorePath{g: g}
-	if g.PRauto.BreadthFirstMapAt(g.Player.P) > unreachable {
+	if g.autoexploreMapAt(g.Player.P) > unreachable {
 		return nil, false
 	}
 	neighbors := ap.Neighbors(g.Player.P)
@@ -90,17 +116,45 @@ func (g *game) NextAuto() (next *gruid.Point, finished bool) {
 		return nil, false
 	}
 	n := neighbors[0]
-	ncost := g.PRauto.BreadthFirstMapAt(n)
+	ncost := g.autoexploreMapAt(n)
 	for _, p := range neighbors[1:] {
-		cost := g.PRauto.BreadthFirstMapAt(p)
+		cost := g.autoexploreMapAt(p)
 		if cost < ncost {
 			n = p
 			ncost = cost
 		}
 	}
-	if ncost >= g.PRauto.BreadthFirstMapAt(g.Player.P) {
+	if ncost >= g.autoexploreMapAt(g.Player.P) {
 		finished = true
 	}
 	next = &n
 	return next, finished
 }
+
+// Stealthy autoexplore waits at most stealthNoiseWait turns after hearing a
+// noise before going through a door, and stops after waiting
+// stealthMaxNoiseWait turns in total.
+const (
+	stealthNoiseWait    = 5
+	stealthMaxNoiseWait = 20
+)
+
+// autoWaitBehindDoor reports whether stealthy autoexplore should wait behind
+// a door instead of stopping after hearing a noise, and sets up the wait.
+func (g *game) autoWaitBehindDoor() bool {
+	if !g.Autoexploring || g.AutoHalt || !g.AutoStealth || g.AutoexploreMapRebuild {
+		return false
+	}
+	n, finished := g.NextAuto()
+	if finished || n == nil || terrain(g.Dungeon.Cell(*n)) != DoorCell {
+		return false
+	}
+	if g.autoNoiseWaited >= stealthMaxNoiseWait {
+		return false
+	}
+	if g.autoNoiseWait == 0 {
+		g.Print("You wait behind the door.")
+	}
+	g.autoNoiseWait = stealthNoiseWait
+	return true
+}
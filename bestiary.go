@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/anaseto/gruid"
+	"github.com/anaseto/gruid/ui"
+)
+
+// bestiaryEntry records what is known about a monster kind.
+type bestiaryEntry struct {
+	Seen    int   `json:"seen"`    // number of monsters seen
+	Killed  int   `json:"killed"`  // number of monsters killed
+	Spotted int   `json:"spotted"` // number of times the player was spotted
+	Depths  []int `json:"depths"`  // sorted depths where the kind was seen
+}
+
+// bestiary maps monster names to bestiary entries. The bestiary file
+// accumulates the entries of all finished games (except wizard games), and is
+// a JSON object, like the following:
+//
+//	{"guard": {"seen": 21, "killed": 2, "spotted": 7, "depths": [1, 2, 3]},
+//	 "tree mushroom": {"seen": 3, "killed": 0, "spotted": 0, "depths": [6]}}
+//
+// Names are used instead of monster kinds, so that the file stays valid when
+// monsters are added.
+type bestiary map[string]*bestiaryEntry
+
+// markSeen marks the monster as seen by the player, and records it in the
+// bestiary statistics.
+func (m *monster) markSeen(g *game) {
+	m.Seen = true
+	g.Stats.SeenMons[m.Kind]++
+	if g.Stats.MonsDepths[m.Kind] == nil {
+		g.Stats.MonsDepths[m.Kind] = map[int]bool{}
+	}
+	g.Stats.MonsDepths[m.Kind][g.Depth] = true
+}
+
+// Bestiary returns the bestiary of the current game.
+func (g *game) Bestiary() bestiary {
+	b := bestiary{}
+	for mk := range MonsData {
+		mk := monsterKind(mk)
+		e := &bestiaryEntry{
+			Seen:    g.Stats.SeenMons[mk],
+			Killed:  g.Stats.KilledMons[mk],
+			Spotted: g.Stats.SpottingMons[mk],
+			Depths:  []int{},
+		}
+		for d := range g.Stats.MonsDepths[mk] {
+			e.Depths = append(e.Depths, d)
+		}
+		sort.Ints(e.Depths)
+		if e.Seen > 0 || e.Killed > 0 || e.Spotted > 0 {
+			b[mk.String()] = e
+		}
+	}
+	return b
+}
+
+// merge adds the entries of another bestiary.
+func (b bestiary) merge(ob bestiary) {
+	for name, oe := range ob {
+		e, ok := b[name]
+		if !ok {
+			e = &bestiaryEntry{Depths: []int{}}
+			b[name] = e
+		}
+		e.Seen += oe.Seen
+		e.Killed += oe.Killed
+		e.Spotted += oe.Spotted
+		depths := map[int]bool{}
+		for _, d := range append(e.Depths, oe.Depths...) {
+			depths[d] = true
+		}
+		e.Depths = e.Depths[:0]
+		for d := range depths {
+			e.Depths = append(e.Depths, d)
+		}
+		sort.Ints(e.Depths)
+	}
+}
+
+// encodeBestiary returns the contents of the bestiary file.
+func encodeBestiary(b bestiary) ([]byte, error) {
+	return json.MarshalIndent(b, "", "\t")
+}
+
+// parseBestiary parses the bestiary file, as described in bestiary.
+func parseBestiary(data []byte) (bestiary, error) {
+	b := bestiary{}
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("bestiary: %v", err)
+	}
+	for name, e := range b {
+		if e == nil {
+			return nil, fmt.Errorf("bestiary: no entry for %s", name)
+		}
+	}
+	return b, nil
+}
+
+// recordBestiary adds the bestiary of the finished game to the bestiary file.
+// Wizard games are not recorded.
+func (g *game) recordBestiary() error {
+	if g.Wizard {
+		return nil
+	}
+	b, err := LoadBestiary()
+	if err != nil {
+		return err
+	}
+	b.merge(g.Bestiary())
+	return SaveBestiary(b)
+}
+
+func (e *bestiaryEntry) String() string {
+	depths := []string{}
+	for _, d := range e.Depths {
+		depths = append(depths, fmt.Sprint(d))
+	}
+	return fmt.Sprintf("seen %d, killed %d, spotted you %d times, depths: %s",
+		e.Seen, e.Killed, e.Spotted, strings.Join(depths, " "))
+}
+
+// openBestiary shows the monsters encountered in the current game or in past
+// ones, with their description.
+func (md *model) openBestiary() {
+	past, err := LoadBestiary()
+	if err != nil {
+		md.g.PrintfStyled("Error: %v", logError, err)
+		past = bestiary{}
+	}
+	md.bestiary = past
+	md.beasts = []monsterKind{}
+	cur := md.g.Bestiary()
+	entries := []ui.MenuEntry{}
+	for mk := range MonsData {
+		mk := monsterKind(mk)
+		if cur[mk.String()] == nil && past[mk.String()] == nil {
+			continue
+		}
+		md.beasts = append(md.beasts, mk)
+		entries = append(entries, ui.MenuEntry{
+			Text: ui.Textf(" %c - %s", mk.Letter(), mk),
+		})
+	}
+	if len(entries) == 0 {
+		md.g.Print("You did not encounter any monsters yet.")
+		md.mode = modeNormal
+		return
+	}
+	altBgEntries(entries)
+	md.menu.SetBox(&ui.Box{Title: ui.Text("Bestiary").WithStyle(gruid.Style{}.WithFg(ColorYellow))})
+	md.menu.SetEntries(entries)
+	md.menu.SetActive(0)
+	md.mode = modeMenu
+	md.menuMode = modeBestiary
+	md.updateBestiaryDescription()
+}
+
+func (md *model) updateBestiaryDescription() {
+	mk := md.beasts[md.menu.Active()]
+	cur := md.g.Bestiary()
+	all := bestiary{}
+	all.merge(md.bestiary)
+	all.merge(cur)
+	lines := []string{mk.Desc(), ""}
+	if e, ok := cur[mk.String()]; ok {
+		lines = append(lines, "@tThis game:@N "+e.String())
+	}
+	lines = append(lines, "@tAll games:@N "+all[mk.String()].String())
+	lines = append(lines, "", "@tTraits:@N "+mk.traits())
+	stt := ui.Text(strings.Join(lines, "\n")).WithMarkup('t', gruid.Style{}.WithFg(ColorYellow))
+	md.description.Content = stt.Format(UIWidth/2 - 1 - 2)
+	md.description.Box = &ui.Box{Title: ui.Textf("%s (%c)", mk, mk.Letter())}
+}
@@ -0,0 +1,537 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+
+	"github.com/anaseto/gruid"
+	"github.com/anaseto/gruid/paths"
+)
+
+// bot chooses the next player action of a headless game from the state
+// visible to the player.
+type bot interface {
+	// Action returns the next action. The error returned by the previous
+	// action, if any, is given, so that the bot can try something else.
+	Action(s *sim, err error) simAction
+}
+
+// explorerBot is a reference bot. It explores levels stealthily until it
+// finds stairs, and then takes the nearest ones. It flees from monsters
+// hunting it, or that were hunting it shortly before, toward stairs when
+// possible, jumping over monsters when cornered, and evokes magaras when in
+// danger. Out of danger, it rests in barrels when hurt and equips items. It
+// escapes as soon as Shaedra is rescued.
+type explorerBot struct {
+	rand     *rand.Rand
+	pr       *paths.PathRange
+	last     simAction
+	depth    int
+	explored bool // no more auto-explore on current level
+	fails    int  // consecutive failed actions
+	norest   bool // resting failed since last move
+	alarm    int  // last turn with a threat in view
+}
+
+// botThreat is a monster threatening the player, at its known position.
+type botThreat struct {
+	m *monster
+	p gruid.Point
+}
+
+// botAlarmTurns is the number of turns the reference bot keeps fleeing from
+// the last known positions of monsters that were hunting it.
+const botAlarmTurns = 20
+
+func newExplorerBot(seed int64) *explorerBot {
+	return &explorerBot{
+		rand: rand.New(rand.NewSource(seed)),
+		pr:   paths.NewPathRange(gruid.NewRange(0, 0, DungeonWidth, DungeonHeight)),
+	}
+}
+
+func (b *explorerBot) Action(s *sim, err error) simAction {
+	g := s.Game()
+	if g.Depth != b.depth {
+		b.depth = g.Depth
+		b.explored = false
+		b.fails = 0
+	}
+	if err != nil {
+		b.fails++
+		switch b.last.Kind {
+		case SimExplore, SimStealthExplore:
+			if g.MonsterInLOS() == nil {
+				b.explored = true
+			}
+		case SimInteract:
+			b.norest = true
+		}
+	} else {
+		b.fails = 0
+		if b.last.Kind != SimInteract {
+			b.norest = false
+		}
+	}
+	b.last = b.action(s)
+	return b.last
+}
+
+// Costs of the flee map, computed as in some other roguelike bots: a
+// position costs less the farther it is from threatening monsters, and then
+// costs are relaxed so that distances to escape routes are taken into
+// account, instead of running into dead ends.
+const (
+	botFleeRange    = 20   // maximum distance to threats taken into account
+	botFleeDist     = 12   // gain per cell of distance to threats
+	botFleeStep     = 10   // cost of a step
+	botFleeSeen     = 30   // penalty for cells in view of monsters
+	botFleeAdjacent = 400  // penalty for cells next to threats
+	botFleeHidden   = 15   // gain for hiding places
+	botFleeStairs   = 1000 // gain for stairs
+)
+
+func (b *explorerBot) action(s *sim) simAction {
+	g := s.Game()
+	switch {
+	case b.fails > 6:
+		// stuck even with random moves (lignified, for example)
+		return simAction{Kind: SimWait}
+	case b.fails > 3:
+		// stuck: try some random move
+		return simAction{Kind: SimMove, Dir: b.randomDir()}
+	}
+	threats := b.threats(g)
+	if len(threats) > 0 {
+		return b.flee(g, threats)
+	}
+	if g.Depth == WinDepth {
+		if g.LiberatedShaedra {
+			return b.goTo(g, g.Places.Monolith, SimDescend)
+		}
+		if explored(g.Dungeon.Cell(g.Places.Shaedra)) {
+			return b.goTo(g, g.Places.Shaedra, SimWait)
+		}
+	}
+	if a, ok := b.care(g); ok {
+		return a
+	}
+	p, ok := b.nearestStair(g)
+	if !ok && !b.explored {
+		if g.MonsterInLOS() == nil {
+			return simAction{Kind: SimStealthExplore}
+		}
+		if a, ok := b.exploreStep(g); ok {
+			return a
+		}
+	}
+	if !ok {
+		return simAction{Kind: SimMove, Dir: b.randomDir()}
+	}
+	return b.goTo(g, p, SimDescend)
+}
+
+// threats returns the monsters in view that are hunting or seeing the
+// player, as well as, shortly after, the last known positions of monsters
+// that were hunting the player.
+func (b *explorerBot) threats(g *game) []botThreat {
+	ths := []botThreat{}
+	for _, m := range g.Monsters {
+		if !m.Exists() || !g.Player.Sees(m.P) || m.Peaceful(g) || m.State == Resting {
+			continue
+		}
+		if m.State == Hunting || m.SeesPlayer(g) || distance(m.P, g.Player.P) <= 1 {
+			ths = append(ths, botThreat{m: m, p: m.P})
+		}
+	}
+	if len(ths) > 0 {
+		b.alarm = g.Turn
+		return ths
+	}
+	if g.Turn-b.alarm > botAlarmTurns {
+		return ths
+	}
+	for _, m := range g.Monsters {
+		q, st, ok := g.knownMonster(m)
+		if ok && st == Hunting && !m.Peaceful(g) && distance(q, g.Player.P) <= botFleeRange/2 {
+			ths = append(ths, botThreat{m: m, p: q})
+		}
+	}
+	return ths
+}
+
+// care returns an action that takes care of the player out of danger:
+// equipping items on the ground, and resting in a barrel when hurt.
+func (b *explorerBot) care(g *game) (simAction, bool) {
+	c := g.Dungeon.Cell(g.Player.P)
+	if terrain(c) == ItemCell && b.last.Kind != SimInteract {
+		return simAction{Kind: SimInteract}, true
+	}
+	hurt := g.Player.HP <= g.Player.HPMax()-2 || g.Player.MP <= g.Player.MPMax()/2
+	if !hurt || g.Player.Bananas <= 0 || b.norest {
+		return simAction{}, false
+	}
+	if terrain(c) == BarrelCell {
+		if g.MonsterInLOS() != nil {
+			// no threats in view: wait for them to go away
+			return simAction{Kind: SimWait}, true
+		}
+		return simAction{Kind: SimInteract}, true
+	}
+	barrels := []gruid.Point{}
+	for _, p := range sortedPoints(g.Objects.Barrels) {
+		if explored(g.Dungeon.Cell(p)) && !g.ExclusionsMap[p] {
+			barrels = append(barrels, p)
+		}
+	}
+	if len(barrels) == 0 {
+		return simAction{}, false
+	}
+	p := g.SortedNearestTo(barrels, g.Player.P)[0]
+	return simAction{Kind: SimSafeTravel, Target: p}, true
+}
+
+// flee returns an action for escaping from the given threatening monsters:
+// descending if on stairs, moving along the flee map, jumping over a monster
+// when cornered, or evoking a magara when in danger.
+func (b *explorerBot) flee(g *game, threats []botThreat) simAction {
+	if terrain(g.Dungeon.Cell(g.Player.P)) == StairCell && g.Objects.Stairs[g.Player.P] == NormalStair {
+		if g.Depth != WinDepth || g.LiberatedShaedra {
+			return simAction{Kind: SimDescend}
+		}
+	}
+	fm := b.fleeMap(g, threats)
+	best := g.Player.P
+	cost := fm[idx(best)]
+	if g.MonsterLOS[g.Player.P] {
+		// waiting in view of monsters is as bad as staying next to them
+		cost += botFleeAdjacent
+	}
+	for _, q := range g.playerPassableNeighbors(g.Player.P) {
+		if m := g.MonsterAt(q); m.Exists() && !m.Peaceful(g) {
+			continue
+		}
+		if terrain(g.Dungeon.Cell(q)) == BarrelCell && g.MonsterLOS[g.Player.P] {
+			continue
+		}
+		if fm[idx(q)] < cost {
+			best = q
+			cost = fm[idx(q)]
+		}
+	}
+	if b.threatDistance(best, threats) <= 1 || g.Player.HP <= 2 && b.threatDistance(best, threats) <= 3 {
+		if dir, p, ok := b.jump(g, threats); ok && fm[idx(p)] < cost {
+			return simAction{Kind: SimMove, Dir: dir}
+		}
+		if a, ok := b.evoke(g, threats); ok {
+			return a
+		}
+	}
+	if best == g.Player.P {
+		return simAction{Kind: SimWait}
+	}
+	return simAction{Kind: SimMove, Dir: best.Sub(g.Player.P)}
+}
+
+// fleeMap returns the flee map for the given threats, indexed with idx.
+// Unknown or unpassable positions have cost unreachable.
+func (b *explorerBot) fleeMap(g *game, threats []botThreat) []int {
+	sources := []gruid.Point{}
+	for _, t := range threats {
+		sources = append(sources, t.p)
+	}
+	b.pr.BreadthFirstMap(&noisePath{g: g}, sources, botFleeRange)
+	fm := make([]int, DungeonWidth*DungeonHeight)
+	danger := make([]int, DungeonWidth*DungeonHeight)
+	passable := []gruid.Point{}
+	it := g.Dungeon.Grid.Iterator()
+	for it.Next() {
+		p := it.P()
+		c := cell(it.Cell())
+		if !explored(c) || !c.IsPlayerPassable() {
+			fm[idx(p)] = unreachable
+			continue
+		}
+		passable = append(passable, p)
+		d := b.pr.BreadthFirstMapAt(p)
+		if g.MonsterLOS[p] {
+			danger[idx(p)] += botFleeSeen
+		}
+		if d <= 1 {
+			danger[idx(p)] += botFleeAdjacent
+		}
+		cost := -botFleeDist * d
+		if (c.Hides() || terrain(c) == FoliageCell) && !g.MonsterLOS[p] {
+			cost -= botFleeHidden
+		}
+		if terrain(c) == StairCell && g.Objects.Stairs[p] == NormalStair && (g.Depth != WinDepth || g.LiberatedShaedra) {
+			cost -= botFleeStairs
+		}
+		fm[idx(p)] = cost + danger[idx(p)]
+	}
+	steps := [][2]int{}
+	for _, p := range passable {
+		for _, q := range g.playerPassableNeighbors(p) {
+			if fm[idx(q)] < unreachable {
+				steps = append(steps, [2]int{idx(p), idx(q)})
+			}
+		}
+	}
+	// dangers are paid on each step, so that paths avoid them
+	for changed := true; changed; {
+		changed = false
+		for _, st := range steps {
+			i, j := st[0], st[1]
+			if c := fm[j] + botFleeStep + danger[i]; c < fm[i] {
+				fm[i] = c
+				changed = true
+			}
+		}
+	}
+	return fm
+}
+
+// threatDistance returns the distance from p to the nearest threat.
+func (b *explorerBot) threatDistance(p gruid.Point, threats []botThreat) int {
+	dist := unreachable
+	for _, t := range threats {
+		if d := distance(t.p, p); d < dist {
+			dist = d
+		}
+	}
+	return dist
+}
+
+// jump returns the direction of a jump over an adjacent threat, and the
+// landing position, if possible.
+func (b *explorerBot) jump(g *game, threats []botThreat) (gruid.Point, gruid.Point, bool) {
+	if g.Player.HasStatus(StatusExhausted) {
+		return ZP, invalidPos, false
+	}
+	for _, t := range threats {
+		if distance(t.p, g.Player.P) != 1 || t.m.Kind == MonsExplosiveNadre {
+			continue
+		}
+		dir := t.p.Sub(g.Player.P)
+		p := t.p
+		for g.PlayerCanPass(p) && g.MonsterAt(p).Exists() {
+			p = p.Add(dir)
+		}
+		if g.PlayerCanPass(p) {
+			return dir, p, true
+		}
+	}
+	return ZP, invalidPos, false
+}
+
+// exploreStep returns a move toward the nearest unexplored place, like
+// stealthy auto-explore, but even with monsters in view.
+func (b *explorerBot) exploreStep(g *game) (simAction, bool) {
+	sources := g.AutoexploreSources()
+	if len(sources) == 0 {
+		return simAction{}, false
+	}
+	stealth := g.AutoStealth
+	g.AutoStealth = true
+	g.BuildAutoexploreMap(sources)
+	n, finished := g.NextAuto()
+	g.AutoStealth = stealth
+	if finished || n == nil {
+		return simAction{}, false
+	}
+	return simAction{Kind: SimMove, Dir: n.Sub(g.Player.P)}, true
+}
+
+// nearestStair returns the nearest known normal stairs, if any.
+func (b *explorerBot) nearestStair(g *game) (gruid.Point, bool) {
+	stairs := []gruid.Point{}
+	for _, p := range g.StairsSlice() {
+		if terrain(g.Dungeon.Cell(p)) == StairCell && g.Objects.Stairs[p] == NormalStair {
+			stairs = append(stairs, p)
+		}
+	}
+	if len(stairs) == 0 {
+		return invalidPos, false
+	}
+	return g.SortedNearestTo(stairs, g.Player.P)[0], true
+}
+
+// goTo returns a safe travel action toward p, or the given action if the
+// player is already there or next to it (for unpassable places).
+func (b *explorerBot) goTo(g *game, p gruid.Point, at simActionKind) simAction {
+	if g.Player.P == p || distance(g.Player.P, p) <= 1 && !g.Dungeon.Cell(p).IsPlayerPassable() {
+		return simAction{Kind: at}
+	}
+	if !g.Dungeon.Cell(p).IsPlayerPassable() {
+		for _, q := range g.playerPassableNeighbors(p) {
+			if explored(g.Dungeon.Cell(q)) {
+				p = q
+				break
+			}
+		}
+	}
+	if b.fails > 0 {
+		return simAction{Kind: SimTravel, Target: p}
+	}
+	return simAction{Kind: SimSafeTravel, Target: p}
+}
+
+// evoke returns an evocation action for the most appropriate magara to
+// escape from the given monsters, if any.
+func (b *explorerBot) evoke(g *game, threats []botThreat) (simAction, bool) {
+	adjacent := b.threatDistance(g.Player.P, threats) <= 1
+	best, bestPriority := -1, 0
+	for i, mag := range g.Player.Magaras {
+		if g.CanUseMagara(i) != nil || b.last.Kind == SimEvoke && b.last.N == i {
+			// do not insist with a magara that did not help
+			continue
+		}
+		priority := 0
+		switch mag.Kind {
+		case EnergyMagara:
+			if g.Player.HP <= 2 {
+				priority = 10
+			}
+		case TeleportOtherMagara, ParalysisMagara, ConfusionMagara:
+			if b.targets(g, mag.Kind, threats) {
+				priority = 9
+			}
+		case SleepingMagara, LignificationMagara:
+			// adjacent monsters wake up or still hit
+			if !adjacent && b.targets(g, mag.Kind, threats) {
+				priority = 8
+			}
+		case TeleportMagara:
+			priority = 7
+		case BlinkMagara, SwappingMagara:
+			priority = 6
+		case SwiftnessMagara, ObstructionMagara:
+			priority = 5
+		case FogMagara:
+			if !adjacent {
+				priority = 4
+			}
+		case ShadowsMagara:
+			if !g.Illuminated(g.Player.P) {
+				priority = 3
+			}
+		case TransparencyMagara:
+			if g.Illuminated(g.Player.P) {
+				priority = 3
+			}
+		case DispersalMagara:
+			if adjacent {
+				priority = 2
+			}
+		}
+		if priority > bestPriority {
+			best, bestPriority = i, priority
+		}
+	}
+	if best < 0 {
+		return simAction{}, false
+	}
+	return simAction{Kind: SimEvoke, N: best}, true
+}
+
+// targets reports whether evoking a magara of the given kind would affect
+// one of the given monsters.
+func (b *explorerBot) targets(g *game, k magaraKind, threats []botThreat) bool {
+	mp, ok := g.MagaraPreview(k)
+	if !ok {
+		return false
+	}
+	for _, m := range mp.Targets {
+		for _, t := range threats {
+			if m == t.m {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (b *explorerBot) randomDir() gruid.Point {
+	dirs := []gruid.Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	return dirs[b.rand.Intn(len(dirs))]
+}
+
+// botMaxActions is the maximum number of actions in a bot game.
+const botMaxActions = 20000
+
+// botResult contains the outcome of a bot game.
+type botResult struct {
+	Won     bool
+	Depth   int // deepest depth reached
+	Turns   int
+	Spotted int
+	USpot   int // number of monsters that spotted the player
+	DSpot   [MaxDepth + 1]int
+}
+
+// PlayBot plays a headless game with the given bot until it ends or the
+// bot exceeds botMaxActions actions.
+func PlayBot(b bot, seed int64) botResult {
+	s := newSim(seed)
+	g := s.Game()
+	res := botResult{Depth: g.Depth}
+	var err error
+	for i := 0; i < botMaxActions && !s.Finished(); i++ {
+		err = s.Do(b.Action(s, err))
+		if g.Depth > res.Depth {
+			res.Depth = g.Depth
+		}
+	}
+	res.Won = s.Won()
+	res.Turns = g.Stats.Turns
+	res.Spotted = g.Stats.NSpotted
+	res.USpot = g.Stats.NUSpotted
+	res.DSpot = g.Stats.DSpotted
+	return res
+}
+
+// RunBots plays n headless games with the reference bot and writes aggregate
+// statistics to w. Game i uses seed+i as seed, or a random seed if seed is
+// zero.
+func RunBots(w io.Writer, n int, seed int64) {
+	var wins, depths, turns, spotted, uspot int
+	var dspot [MaxDepth + 1]int
+	var reached [MaxDepth + 1]int
+	for i := 0; i < n; i++ {
+		gseed := int64(0)
+		if seed != 0 {
+			gseed = seed + int64(i)
+		}
+		res := PlayBot(newExplorerBot(seed+int64(i)), gseed)
+		if res.Won {
+			wins++
+		}
+		depths += res.Depth
+		for d := 1; d <= res.Depth && d <= MaxDepth; d++ {
+			reached[d]++
+		}
+		turns += res.Turns
+		spotted += res.Spotted
+		uspot += res.USpot
+		for d := range dspot {
+			dspot[d] += res.DSpot[d]
+		}
+	}
+	if n <= 0 {
+		return
+	}
+	fmt.Fprintf(w, "Games: %d\n", n)
+	fmt.Fprintf(w, "Win rate: %.1f%% (%d/%d)\n", float64(100*wins)/float64(n), wins, n)
+	fmt.Fprintf(w, "Average depth reached: %.2f\n", float64(depths)/float64(n))
+	fmt.Fprintf(w, "Average turns: %.1f\n", float64(turns)/float64(n))
+	fmt.Fprintf(w, "Average spotted: %.2f times by %.2f monsters\n", float64(spotted)/float64(n), float64(uspot)/float64(n))
+	fmt.Fprintf(w, "\n| Depth | Reached | Spotted/game |\n")
+	for d := 1; d <= MaxDepth; d++ {
+		spd := 0.0
+		if reached[d] > 0 {
+			spd = float64(dspot[d]) / float64(reached[d])
+		}
+		fmt.Fprintf(w, "| %5d | %7d | %12.2f |\n", d, reached[d], spd)
+	}
+}
@@ -227,6 +227,66 @@ func (c cell) IsNotable() bool {
 	}
 }
 
+// Name returns a short name identifying the kind of terrain, independently
+// of the game state.
+func (c cell) Name() (name string) {
+	switch terrain(c) {
+	case WallCell:
+		name = "wall"
+	case GroundCell:
+		name = "ground"
+	case DoorCell:
+		name = "door"
+	case FoliageCell:
+		name = "foliage"
+	case BarrelCell:
+		name = "barrel"
+	case StairCell:
+		name = "stair"
+	case StoneCell:
+		name = "stone"
+	case MagaraCell:
+		name = "magara"
+	case BananaCell:
+		name = "banana"
+	case LightCell:
+		name = "light"
+	case ExtinguishedLightCell:
+		name = "extinguished light"
+	case TableCell:
+		name = "table"
+	case TreeCell:
+		name = "tree"
+	case HoledWallCell:
+		name = "holed wall"
+	case ScrollCell:
+		name = "scroll"
+	case StoryCell:
+		name = "story"
+	case ItemCell:
+		name = "item"
+	case BarrierCell:
+		name = "barrier"
+	case WindowCell:
+		name = "window"
+	case ChasmCell:
+		name = "chasm"
+	case WaterCell:
+		name = "water"
+	case RubbleCell:
+		name = "rubble"
+	case CavernCell:
+		name = "cavern"
+	case FakeStairCell:
+		name = "fake stair"
+	case PotionCell:
+		name = "potion"
+	case QueenRockCell:
+		name = "queen rock"
+	}
+	return name
+}
+
 func (c cell) ShortString(g *game, p gruid.Point) (desc string) {
 	switch terrain(c) {
 	case WallCell:
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anaseto/gruid"
+	"github.com/anaseto/gruid/ui"
+)
+
+// dailyDate returns the date of the daily challenge at time t. Dates are in
+// UTC, so that players in different time zones share the same challenge.
+func dailyDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// dailySeed returns the seed of the daily challenge of the given date.
+func dailySeed(date string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("harmonist daily " + date))
+	seed := int64(h.Sum64() &^ (1 << 63))
+	if seed == 0 {
+		seed = 1
+	}
+	return seed
+}
+
+// challengeOutcome is the outcome of a daily challenge.
+type challengeOutcome int
+
+const (
+	ChallengeStarted challengeOutcome = iota // not finished yet
+	ChallengeDeath
+	ChallengeEscape
+	ChallengeAbandoned
+)
+
+var challengeOutcomeNames = map[challengeOutcome]string{
+	ChallengeStarted:   "started",
+	ChallengeDeath:     "death",
+	ChallengeEscape:    "escape",
+	ChallengeAbandoned: "abandoned",
+}
+
+func (oc challengeOutcome) String() string {
+	return challengeOutcomeNames[oc]
+}
+
+// score is an entry of the daily challenges scoreboard.
+//
+// The scoreboard is a text file with one entry per line. An entry is
+// appended when a challenge starts, and another one when it ends, so that a
+// challenge cannot be restarted. For example:
+//
+//	2021-04-03 started turns 0 depth 1 shaedra no artifact no achievements 0 spotted 0 spotters 0
+//	2021-04-03 escape turns 5432 depth 11 shaedra yes artifact yes achievements 7 spotted 21 spotters 9
+type score struct {
+	Date         string
+	Outcome      challengeOutcome
+	Turns        int
+	Depth        int
+	Shaedra      bool // whether Shaedra was rescued
+	Artifact     bool // whether the artifact was recovered
+	Achievements int
+	Spotted      int // number of times the player was spotted
+	Spotters     int // number of monsters that spotted the player
+}
+
+// Score returns the scoreboard entry of the game's daily challenge, with the
+// given outcome.
+func (g *game) Score(oc challengeOutcome) score {
+	return score{
+		Date:         g.Daily,
+		Outcome:      oc,
+		Turns:        g.Turn,
+		Depth:        max(g.Depth, g.ExploredLevels),
+		Shaedra:      g.LiberatedShaedra,
+		Artifact:     g.LiberatedArtifact,
+		Achievements: len(g.Stats.Achievements),
+		Spotted:      g.Stats.NSpotted,
+		Spotters:     g.Stats.NUSpotted,
+	}
+}
+
+func yesno(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func (s score) String() string {
+	return fmt.Sprintf("%s %v turns %d depth %d shaedra %s artifact %s achievements %d spotted %d spotters %d",
+		s.Date, s.Outcome, s.Turns, s.Depth, yesno(s.Shaedra), yesno(s.Artifact), s.Achievements, s.Spotted, s.Spotters)
+}
+
+// parseScore parses a scoreboard entry.
+func parseScore(fields []string) (score, error) {
+	s := score{}
+	if len(fields) < 2 || len(fields)%2 != 0 {
+		return s, errors.New("bad number of fields")
+	}
+	s.Date = fields[0]
+	ok := false
+	for oc, name := range challengeOutcomeNames {
+		if name == fields[1] {
+			s.Outcome = oc
+			ok = true
+		}
+	}
+	if !ok {
+		return s, fmt.Errorf("unknown outcome: %s", fields[1])
+	}
+	for i := 2; i < len(fields); i += 2 {
+		key, value := fields[i], fields[i+1]
+		var err error
+		switch key {
+		case "turns":
+			s.Turns, err = strconv.Atoi(value)
+		case "depth":
+			s.Depth, err = strconv.Atoi(value)
+		case "shaedra":
+			s.Shaedra = value == "yes"
+		case "artifact":
+			s.Artifact = value == "yes"
+		case "achievements":
+			s.Achievements, err = strconv.Atoi(value)
+		case "spotted":
+			s.Spotted, err = strconv.Atoi(value)
+		case "spotters":
+			s.Spotters, err = strconv.Atoi(value)
+		default:
+			err = fmt.Errorf("unknown field: %s", key)
+		}
+		if err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// parseScoreboard parses the scoreboard file, as described in score.
+func parseScoreboard(data []byte) ([]score, error) {
+	scores := []score{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		s, err := parseScore(fields)
+		if err != nil {
+			return nil, fmt.Errorf("scoreboard line %d: %v", n, err)
+		}
+		scores = append(scores, s)
+	}
+	return scores, sc.Err()
+}
+
+// dailyScores returns the last entry of each daily challenge, most recent
+// challenges first.
+func dailyScores(scores []score) []score {
+	last := map[string]score{}
+	for _, s := range scores {
+		last[s.Date] = s
+	}
+	dscores := []score{}
+	for _, s := range last {
+		dscores = append(dscores, s)
+	}
+	sort.Slice(dscores, func(i, j int) bool { return dscores[i].Date > dscores[j].Date })
+	return dscores
+}
+
+// dailyPlayed reports whether the daily challenge of the given date was
+// already started.
+func dailyPlayed(scores []score, date string) bool {
+	for _, s := range scores {
+		if s.Date == date {
+			return true
+		}
+	}
+	return false
+}
+
+// StartDaily records the start of the game's daily challenge in the
+// scoreboard. It returns an error if the challenge was already played.
+func (g *game) StartDaily() error {
+	scores, err := LoadScoreboard()
+	if err != nil {
+		return err
+	}
+	if dailyPlayed(scores, g.Daily) {
+		return fmt.Errorf("daily challenge of %s already played", g.Daily)
+	}
+	return AppendScore(g.Score(ChallengeStarted))
+}
+
+var errDailyOver = errors.New("daily challenge already over")
+
+// ResumeDaily checks that the game's daily challenge, loaded from a save, is
+// not over yet.
+func (g *game) ResumeDaily() error {
+	scores, err := LoadScoreboard()
+	if err != nil {
+		return err
+	}
+	for _, s := range scores {
+		if s.Date == g.Daily && s.Outcome != ChallengeStarted {
+			return errDailyOver
+		}
+	}
+	return nil
+}
+
+// scoreboardLines returns the lines of the scoreboard view.
+func scoreboardLines(scores []score) []ui.StyledText {
+	st := ui.StyledText{}.WithStyle(gruid.Style{})
+	head := st.WithStyle(gruid.Style{}.WithFg(ColorYellow))
+	lines := []ui.StyledText{
+		head.WithText(fmt.Sprintf("%-10s  %-9s  %5s  %5s  %-7s  %-8s  %5s  %7s", "Date", "Outcome", "Turns", "Depth", "Shaedra", "Artifact", "Achs", "Spotted")),
+	}
+	for _, s := range dailyScores(scores) {
+		lines = append(lines, st.WithText(fmt.Sprintf("%-10s  %-9v  %5d  %5d  %-7s  %-8s  %5d  %3d/%3d",
+			s.Date, s.Outcome, s.Turns, s.Depth, yesno(s.Shaedra), yesno(s.Artifact), s.Achievements, s.Spotted, s.Spotters)))
+	}
+	if len(lines) == 1 {
+		lines = append(lines, st.WithText("No daily challenge played yet."))
+	}
+	return lines
+}
+
+// scoreboardViewer is a model listing past daily challenges.
+type scoreboardViewer struct {
+	gd    gruid.Grid
+	pager *ui.Pager
+}
+
+func newScoreboardViewer() (*scoreboardViewer, error) {
+	scores, err := LoadScoreboard()
+	if err != nil {
+		return nil, err
+	}
+	v := &scoreboardViewer{gd: gruid.NewGrid(UIWidth, UIHeight)}
+	v.pager = ui.NewPager(ui.PagerConfig{
+		Grid: gruid.NewGrid(UIWidth, UIHeight),
+		Box:  &ui.Box{Title: ui.Text("Daily Challenges").WithStyle(gruid.Style{}.WithFg(ColorYellow))},
+		Keys: ui.PagerKeys{Quit: []gruid.Key{gruid.KeySpace, "x", "X", "q", "Q", gruid.KeyEscape}},
+	})
+	v.pager.SetLines(scoreboardLines(scores))
+	return v, nil
+}
+
+func (v *scoreboardViewer) Update(msg gruid.Msg) gruid.Effect {
+	switch msg.(type) {
+	case gruid.MsgInit:
+		return nil
+	case gruid.MsgQuit:
+		return gruid.End()
+	}
+	v.pager.Update(msg)
+	if v.pager.Action() == ui.PagerQuit {
+		return gruid.End()
+	}
+	return nil
+}
+
+func (v *scoreboardViewer) Draw() gruid.Grid {
+	v.gd.Fill(gruid.Cell{Rune: ' '})
+	v.gd.Copy(v.pager.Draw())
+	return v.gd
+}
@@ -28,6 +28,9 @@ var (
 	ColorBg,
 	ColorBgDark,
 	ColorBgLOS,
+	ColorBgHuntingLOS,
+	ColorBgMonsterLOS,
+	ColorBgNoise,
 	ColorFg,
 	ColorFgObject,
 	ColorFgTree,
@@ -62,9 +65,18 @@ var (
 )
 
 func init() {
+	setDefaultColors()
+}
+
+// setDefaultColors sets the semantic colors to their default palette colors.
+// Themes may then change them.
+func setDefaultColors() {
 	ColorBg = ColorBackground
 	ColorBgDark = ColorBackground
 	ColorBgLOS = ColorBackgroundSecondary
+	ColorBgHuntingLOS = ColorRed
+	ColorBgMonsterLOS = ColorMagenta
+	ColorBgNoise = ColorCyan
 	ColorFg = ColorForeground
 	ColorFgDark = ColorForegroundSecondary
 	ColorFgLOS = ColorForegroundEmph
@@ -27,7 +27,9 @@ func (m *monster) InflictDamage(g *game, damage, max int) {
 	if oldHP > max && g.Player.HP <= max {
 		g.StoryPrintf("Critical hit by %s (HP: %d)", m.Kind, g.Player.HP)
 		g.md.WoundedAnimation() // twice
-		g.md.criticalHPWarning()
+		if g.md != nil {
+			g.md.criticalHPWarning()
+		}
 	} else if g.Player.HP > 0 {
 		g.StoryPrintf("Hit by %s (HP: %d)", m.Kind, g.Player.HP)
 	} else {
@@ -67,14 +69,7 @@ func (g *game) MakeNoise(noise int, at gruid.Point) {
 		if !m.Exists() {
 			continue
 		}
-		if m.State == Hunting {
-			continue
-		}
-		d := g.PR.BreadthFirstMapAt(m.P)
-		if d > noise {
-			continue
-		}
-		if m.State == Resting && 3*d > 2*noise || m.Status(MonsExhausted) && m.State == Resting && 3*d > noise {
+		if !m.HearsNoise(m.State, g.PR.BreadthFirstMapAt(m.P), noise) {
 			continue
 		}
 		if m.SeesPlayer(g) {
@@ -86,6 +81,21 @@ func (g *game) MakeNoise(noise int, at gruid.Point) {
 	}
 }
 
+// HearsNoise reports whether the monster, in the given state, is disturbed by
+// a noise of the given intensity made at noise distance d. Hunting monsters
+// ignore noises, and resting ones only hear close enough noises.
+func (m *monster) HearsNoise(st monsterState, d, noise int) bool {
+	switch {
+	case st == Hunting || d > noise:
+		return false
+	case st == Resting && 3*d > 2*noise:
+		return false
+	case st == Resting && m.Status(MonsExhausted) && 3*d > noise:
+		return false
+	}
+	return true
+}
+
 func (m *monster) LeaveRoomForPlayer(g *game) gruid.Point {
 	dij := &monPath{g: g, monster: m}
 	nodes := g.PR.DijkstraMap(dij, []gruid.Point{m.P}, 10)
@@ -58,13 +58,17 @@ func (md *model) Draw() gruid.Grid {
 			}
 			md.statusDesc.Draw(md.gd.Slice(md.gd.Range().Lines(UIHeight-4, UIHeight-1).Shift(x, 0, 0, 0)))
 		}
+	case modeEvokeConfirmation:
+		md.drawEvocationPreview()
+	case modeOverview:
+		md.drawOverview()
 	case modePager:
 		md.gd.Copy(md.pager.Draw())
 	case modeSmallPager:
 		md.gd.Slice(gruid.NewRange(10, 2, UIWidth, UIHeight-1)).Copy(md.smallPager.Draw())
 	case modeMenu:
 		switch md.menuMode {
-		case modeInventory, modeEquip, modeEvocation:
+		case modeInventory, modeEquip, modeEvocation, modeBestiary:
 			gd := md.menu.Draw()
 			md.gd.Copy(gd)
 			md.description.Draw(md.gd.Slice(md.gd.Range().Columns(UIWidth/2, UIWidth)))
@@ -85,6 +89,12 @@ func (md *model) Draw() gruid.Grid {
 			}
 			t.Draw(gd.Slice(gd.Range().Line(max.Y-1).Shift(2, 0, 0, 0)))
 			md.gd.Copy(gd)
+		case modePastRuns:
+			gd := md.runsMenu.Draw()
+			max := gd.Size()
+			t := ui.Text("(s) sort (Enter) details").WithStyle(gruid.Style{}.WithFg(ColorCyan))
+			t.Draw(gd.Slice(gd.Range().Line(max.Y-1).Shift(2, 0, 0, 0)))
+			md.gd.Copy(gd)
 		}
 	}
 	md.gd.Slice(md.gd.Range().Line(UIHeight - 1)).Copy(md.status.Draw())
@@ -129,18 +139,44 @@ func drawWelcome(gd gruid.Grid) gruid.Grid {
 }
 
 func (md *model) drawMap(gd gruid.Grid) {
+	md.computeVision()
+	md.computeNoisePreview()
 	it := md.g.Dungeon.Grid.Iterator()
 	for it.Next() {
 		p := it.P()
 		r, fg, bg := md.positionDrawing(p)
 		attrs := AttrInMap
-		if md.g.Highlight[p] || p == md.targ.ex.p {
+		if md.g.Highlight[p] || p == md.targ.ex.p || md.noise[p] {
 			attrs |= AttrReverse
 		}
 		gd.Set(p, gruid.Cell{Rune: r, Style: gruid.Style{Fg: fg, Bg: bg, Attrs: attrs}})
 	}
 }
 
+// computeVision updates the monster vision overlay, if enabled. When
+// examining a visible monster, only its own vision is shown.
+func (md *model) computeVision() {
+	g := md.g
+	if !GameConfig.VisionOverlay {
+		md.vision = nil
+		return
+	}
+	ms := []*monster{}
+	if g.MonsterTargLOS != nil {
+		if m := g.MonsterAt(md.targ.ex.p); m.Exists() {
+			ms = append(ms, m)
+		}
+	}
+	if len(ms) == 0 {
+		for _, m := range g.Monsters {
+			if m.Exists() && g.Player.Sees(m.P) {
+				ms = append(ms, m)
+			}
+		}
+	}
+	md.vision = g.MonsterVision(ms)
+}
+
 func (md *model) positionDrawing(p gruid.Point) (r rune, fgColor, bgColor gruid.Color) {
 	g := md.g
 	c := g.Dungeon.Cell(p)
@@ -218,12 +254,14 @@ func (md *model) positionDrawing(p gruid.Point) (r rune, fgColor, bgColor gruid.
 		if fgTerrain != ColorFgLOS {
 			fgColor = fgTerrain
 		}
-		if g.MonsterTargLOS != nil {
-			if g.MonsterTargLOS[p] {
+		if md.vision == nil {
+			if g.MonsterTargLOS != nil {
+				if g.MonsterTargLOS[p] {
+					fgColor = ColorFgWanderingMonster
+				}
+			} else if g.MonsterLOS[p] {
 				fgColor = ColorFgWanderingMonster
 			}
-		} else if g.MonsterLOS[p] {
-			fgColor = ColorFgWanderingMonster
 		}
 		if cld, ok := g.Clouds[p]; ok && g.Player.Sees(p) {
 			r = '§'
@@ -252,6 +290,16 @@ func (md *model) positionDrawing(p gruid.Point) (r rune, fgColor, bgColor gruid.
 			fgColor = ColorFgLOSLight
 		}
 	}
+	if hunting, ok := md.vision[p]; ok && !(g.Player.Sees(p) && g.MonsterAt(p).Exists()) {
+		if hunting {
+			bgColor = ColorBgHuntingLOS
+		} else {
+			bgColor = ColorBgMonsterLOS
+		}
+	}
+	if _, ok := md.noise[p]; ok {
+		bgColor = ColorBgNoise
+	}
 	return
 }
 
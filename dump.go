@@ -52,6 +52,7 @@ func (g *game) SortedKilledMonsters() monsSlice {
 func (g *game) Dump() string {
 	buf := &strings.Builder{}
 	fmt.Fprintf(buf, " -- Harmonist version %s character file --\n\n", Version)
+	fmt.Fprintf(buf, "Seed: %d\n\n", g.Seed)
 	if g.Wizard {
 		fmt.Fprintf(buf, "**WIZARD MODE**\n")
 	}
@@ -105,6 +106,9 @@ func (g *game) Dump() string {
 		s = ""
 	}
 	fmt.Fprintf(buf, "You explored %d level%s out of %d.\n", maxDepth, s, MaxDepth)
+	if g.Preset != "" {
+		fmt.Fprintf(buf, "Game preset: %s.\n", g.Preset)
+	}
 	fmt.Fprintf(buf, "\n")
 	fmt.Fprintf(buf, "Last messages:\n")
 	for i := len(g.Log) - 10; i < len(g.Log); i++ {
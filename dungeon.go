@@ -10,7 +10,6 @@ import (
 	"log"
 	"math/rand"
 	"sort"
-	"time"
 
 	"github.com/anaseto/gruid"
 	"github.com/anaseto/gruid/paths"
@@ -119,6 +118,7 @@ type dgen struct {
 	spl       places
 	special   specialRoom
 	layout    maplayout
+	depth     int
 	PR        *paths.PathRange
 	rand      *rand.Rand
 	neighbors paths.Neighbors
@@ -132,9 +132,9 @@ func (dg *dgen) ConnectRoomsShortestPath(i, j int) bool {
 	r2 := dg.rooms[j]
 	var e1pos, e2pos gruid.Point
 	var e1i, e2i int
-	e1i = r1.UnusedEntry()
+	e1i = r1.UnusedEntry(dg)
 	e1pos = r1.entries[e1i].p
-	e2i = r2.UnusedEntry()
+	e2i = r2.UnusedEntry(dg)
 	e2pos = r2.entries[e2i].p
 	tp := &tunnelPath{dg: dg}
 	path := dg.PR.AstarPath(tp, e1pos, e2pos)
@@ -313,9 +313,11 @@ const (
 	PlacementRandom placement = iota
 	PlacementCenter
 	PlacementEdge
+	PlacementAny // only for room template constraints
 )
 
-func (dg *dgen) GenRooms(templates []string, n int, pl placement) (ps []gruid.Point, ok bool) {
+func (dg *dgen) GenRooms(templates []roomTemplate, n int, pl placement) (ps []gruid.Point, ok bool) {
+	templates = eligibleRoomTemplates(templates, dg.depth, pl)
 	if len(templates) == 0 {
 		return nil, false
 	}
@@ -324,7 +326,7 @@ func (dg *dgen) GenRooms(templates []string, n int, pl placement) (ps []gruid.Po
 		var r *room
 		count := 500
 		var p gruid.Point
-		var tpl string
+		var tpl roomTemplate
 		for r == nil && count > 0 {
 			count--
 			switch pl {
@@ -339,8 +341,8 @@ func (dg *dgen) GenRooms(templates []string, n int, pl placement) (ps []gruid.Po
 					p = gruid.Point{3*DungeonWidth/4 + dg.rand.Intn(DungeonWidth/4) - 1, dg.rand.Intn(DungeonHeight - 1)}
 				}
 			}
-			tpl = templates[dg.rand.Intn(len(templates))]
-			r = dg.NewRoom(p, tpl)
+			tpl = dg.RandomRoomTemplate(templates)
+			r = dg.NewRoom(p, tpl.Content)
 		}
 		if r != nil {
 			switch pl {
@@ -414,6 +416,26 @@ const (
 	NaturalCave
 )
 
+// mapLayouts lists all the map layouts.
+var mapLayouts = []maplayout{AutomataCave, RandomWalkCave, RandomWalkTreeCave, RandomSmallWalkCaveUrbanised, NaturalCave}
+
+func (ml maplayout) String() string {
+	switch ml {
+	case AutomataCave:
+		return "automata"
+	case RandomWalkCave:
+		return "walk"
+	case RandomWalkTreeCave:
+		return "tree"
+	case RandomSmallWalkCaveUrbanised:
+		return "urbanised"
+	case NaturalCave:
+		return "natural"
+	default:
+		return "unknown"
+	}
+}
+
 func (dg *dgen) GenShaedraCell(g *game) {
 	g.Objects.Story = map[gruid.Point]story{}
 	g.Places.Shaedra = dg.spl.Shaedra
@@ -436,9 +458,10 @@ func (dg *dgen) GenArtifactPlace(g *game) {
 
 func (g *game) GenRoomTunnels(ml maplayout) {
 	dg := dgen{}
-	dg.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	dg.rand = g.rand
 	dg.PR = paths.NewPathRange(gruid.NewRange(0, 0, DungeonWidth, DungeonHeight))
 	dg.layout = ml
+	dg.depth = g.Depth
 	d := &dungeon{}
 	d.Grid = rl.NewGrid(DungeonWidth, DungeonHeight)
 	dg.d = d
@@ -500,25 +523,25 @@ func (g *game) GenRoomTunnels(ml maplayout) {
 	}
 	switch ml {
 	case RandomWalkCave:
-		dg.GenRooms(roomBigTemplates, nspecial-1, PlacementRandom)
-		dg.GenRooms(roomNormalTemplates, normal, PlacementRandom)
+		dg.GenRooms(roomTemplatesOf(roomKindBig, noSpecialRoom), nspecial-1, PlacementRandom)
+		dg.GenRooms(roomTemplatesOf(roomKindNormal, noSpecialRoom), normal, PlacementRandom)
 	case RandomWalkTreeCave:
-		dg.GenRooms(roomBigTemplates, nspecial+1, PlacementRandom)
-		dg.GenRooms(roomNormalTemplates, normal+2, PlacementRandom)
+		dg.GenRooms(roomTemplatesOf(roomKindBig, noSpecialRoom), nspecial+1, PlacementRandom)
+		dg.GenRooms(roomTemplatesOf(roomKindNormal, noSpecialRoom), normal+2, PlacementRandom)
 	case RandomSmallWalkCaveUrbanised:
 		nspecial += 3
-		dg.GenRooms(roomBigTemplates, nspecial, PlacementRandom)
-		dg.GenRooms(roomNormalTemplates, normal+5, PlacementRandom)
+		dg.GenRooms(roomTemplatesOf(roomKindBig, noSpecialRoom), nspecial, PlacementRandom)
+		dg.GenRooms(roomTemplatesOf(roomKindNormal, noSpecialRoom), normal+5, PlacementRandom)
 	case NaturalCave:
 		nspecial++
 		if g.Depth == WinDepth {
 			nspecial += 2
 		}
-		dg.GenRooms(roomBigTemplates, nspecial, PlacementRandom)
-		dg.GenRooms(roomNormalTemplates, normal-3, PlacementRandom)
+		dg.GenRooms(roomTemplatesOf(roomKindBig, noSpecialRoom), nspecial, PlacementRandom)
+		dg.GenRooms(roomTemplatesOf(roomKindNormal, noSpecialRoom), normal-3, PlacementRandom)
 	default:
-		dg.GenRooms(roomBigTemplates, nspecial, PlacementRandom)
-		dg.GenRooms(roomNormalTemplates, normal+2, PlacementRandom)
+		dg.GenRooms(roomTemplatesOf(roomKindBig, noSpecialRoom), nspecial, PlacementRandom)
+		dg.GenRooms(roomTemplatesOf(roomKindNormal, noSpecialRoom), normal+2, PlacementRandom)
 	}
 	dg.ConnectRooms()
 	g.Dungeon = d
@@ -557,6 +580,7 @@ func (g *game) GenRoomTunnels(ml maplayout) {
 	if dg.rand.Intn(2) == 0 {
 		dg.GenQueenRock()
 	}
+	g.genInfo = genInfo{Layout: ml, Rooms: len(dg.rooms)}
 }
 
 func (dg *dgen) PutCavernCells(g *game) {
@@ -698,7 +722,7 @@ func (dg *dgen) PutLore(g *game) {
 		if count > 2000 {
 			panic("PutLore1")
 		}
-		p = dg.rooms[RandInt(len(dg.rooms))].RandomPlace(PlaceItem)
+		p = dg.rooms[g.randInt(len(dg.rooms))].RandomPlace(dg, PlaceItem)
 	}
 	count = 0
 	for {
@@ -741,7 +765,7 @@ func (dg *dgen) GenLight(g *game) {
 		g.Dungeon.SetCell(p, LightCell)
 	}
 	for i := 0; i < ni; i++ {
-		p := dg.rooms[RandInt(len(dg.rooms))].RandomPlaces(PlaceSpecialOrStatic)
+		p := dg.rooms[g.randInt(len(dg.rooms))].RandomPlaces(dg, PlaceSpecialOrStatic)
 		if p != invalidPos {
 			g.Dungeon.SetCell(p, LightCell)
 		} else if dg.rand.Intn(10) > 0 {
@@ -770,7 +794,7 @@ loop:
 			}
 		}
 	}
-	g.Player.P = r.RandomPlace(PlacePatrol)
+	g.Player.P = r.RandomPlace(dg, PlacePatrol)
 	switch g.Depth {
 	case 1, 4:
 	default:
@@ -779,7 +803,7 @@ loop:
 	itpos := invalidPos
 	neighbors := g.playerPassableNeighbors(g.Player.P)
 	for i := 0; i < len(neighbors); i++ {
-		j := RandInt(len(neighbors) - i)
+		j := g.randInt(len(neighbors) - i)
 		neighbors[i], neighbors[j] = neighbors[j], neighbors[i]
 	}
 loopnb:
@@ -796,10 +820,10 @@ loopnb:
 		}
 	}
 	if itpos == invalidPos {
-		itpos = r.RandomPlace(PlaceItem)
+		itpos = r.RandomPlace(dg, PlaceItem)
 	}
 	if itpos == invalidPos {
-		itpos = r.RandomPlaces(PlaceSpecialOrStatic)
+		itpos = r.RandomPlaces(dg, PlaceSpecialOrStatic)
 		if itpos == invalidPos {
 			panic("no item")
 		}
@@ -840,7 +864,7 @@ func (dg *dgen) GenPotion(g *game, ptn potion) {
 		if count > maxIterations {
 			return
 		}
-		p = dg.rooms[RandInt(len(dg.rooms))].RandomPlace(PlaceItem)
+		p = dg.rooms[g.randInt(len(dg.rooms))].RandomPlace(dg, PlaceItem)
 	}
 	dg.d.SetCell(p, PotionCell)
 	g.Objects.Potions[p] = ptn
@@ -882,6 +906,10 @@ func (dg *dgen) OutsideCavernMiddleCell(g *game) gruid.Point {
 			continue
 		}
 		c := dg.d.Cell(p)
+		if terrain(c) == BananaCell || terrain(c) == PotionCell {
+			// do not overwrite objects
+			continue
+		}
 		if terrain(c) == GroundCell && count < 400 || terrain(c) == FoliageCell && count < 350 {
 			continue
 		}
@@ -996,7 +1024,7 @@ func (dg *dgen) GenItem(g *game) {
 		if count > maxIterations {
 			panic("GenItem")
 		}
-		p = dg.rooms[RandInt(len(dg.rooms))].RandomPlace(PlaceItem)
+		p = dg.rooms[g.randInt(len(dg.rooms))].RandomPlace(dg, PlaceItem)
 	}
 	g.Dungeon.SetCell(p, ItemCell)
 	var it item
@@ -1019,7 +1047,7 @@ func (dg *dgen) GenBarrierStone(g *game) {
 		if count > maxIterations {
 			panic("GenBarrierStone")
 		}
-		p = dg.rooms[RandInt(len(dg.rooms))].RandomPlaces(PlaceSpecialOrStatic)
+		p = dg.rooms[g.randInt(len(dg.rooms))].RandomPlaces(dg, PlaceSpecialOrStatic)
 	}
 	g.Dungeon.SetCell(p, StoneCell)
 	g.Objects.Stones[p] = SealStone
@@ -1033,7 +1061,7 @@ func (dg *dgen) GenMagara(g *game) {
 		if count > maxIterations {
 			panic("GenMagara")
 		}
-		p = dg.rooms[RandInt(len(dg.rooms))].RandomPlace(PlaceItem)
+		p = dg.rooms[g.randInt(len(dg.rooms))].RandomPlace(dg, PlaceItem)
 	}
 	g.Dungeon.SetCell(p, MagaraCell)
 	mag := g.RandomMagara()
@@ -1100,7 +1128,7 @@ func (dg *dgen) GenBarrel(g *game) {
 		if count > 500 {
 			return
 		}
-		p = dg.rooms[RandInt(len(dg.rooms))].RandomPlace(PlaceSpecialStatic)
+		p = dg.rooms[g.randInt(len(dg.rooms))].RandomPlace(dg, PlaceSpecialStatic)
 	}
 	g.Dungeon.SetCell(p, BarrelCell)
 	g.Objects.Barrels[p] = true
@@ -1114,7 +1142,7 @@ func (dg *dgen) GenTable(g *game) {
 		if count > 500 {
 			return
 		}
-		p = dg.rooms[RandInt(len(dg.rooms))].RandomPlaces(PlaceSpecialOrStatic)
+		p = dg.rooms[g.randInt(len(dg.rooms))].RandomPlaces(dg, PlaceSpecialOrStatic)
 	}
 	g.Dungeon.SetCell(p, TableCell)
 }
@@ -1159,7 +1187,7 @@ func (dg *dgen) RandomInStone(g *game) stone {
 		// fog stone less often inside
 		instones = append(instones, FogStone)
 	}
-	return instones[RandInt(len(instones))]
+	return instones[g.randInt(len(instones))]
 }
 
 func (dg *dgen) RandomOutStone(g *game) stone {
@@ -1206,7 +1234,7 @@ func (dg *dgen) GenStones(g *game) {
 					p = dg.CaveGroundCell(g)
 					break
 				}
-				p = dg.rooms[RandInt(len(dg.rooms))].RandomPlace(PlaceStatic)
+				p = dg.rooms[g.randInt(len(dg.rooms))].RandomPlace(dg, PlaceStatic)
 			}
 			st = dg.RandomInStone(g)
 		} else {
@@ -1253,7 +1281,7 @@ func (dg *dgen) GenLake(c cell) {
 		}
 	}
 	count := 0
-	var bestpos = walls[RandInt(len(walls))]
+	var bestpos = walls[dg.rand.Intn(len(walls))]
 	var bestsize int
 	d := dg.d
 	passable := func(p gruid.Point) func(q gruid.Point) bool {
@@ -1262,7 +1290,7 @@ func (dg *dgen) GenLake(c cell) {
 		}
 	}
 	for {
-		p := walls[RandInt(len(walls))]
+		p := walls[dg.rand.Intn(len(walls))]
 		sp := newPather(passable(p))
 		size := len(dg.PR.CCMap(sp, p))
 		count++
@@ -1293,7 +1321,7 @@ func (dg *dgen) GenQueenRock() {
 		return
 	}
 	for i := 0; i < 1+dg.rand.Intn(2); i++ {
-		p := cavern[RandInt(len(cavern))]
+		p := cavern[dg.rand.Intn(len(cavern))]
 		passable := func(q gruid.Point) bool {
 			return valid(q) && terrain(dg.d.Cell(q)) == CavernCell && distance(q, p) < 15+dg.rand.Intn(5)
 		}
@@ -1374,7 +1402,7 @@ func (dg *dgen) DigBlock(block []gruid.Point) []gruid.Point {
 		if d.HasFreeNeighbor(&dg.neighbors, p) {
 			break
 		}
-		p = randomNeighbor(p)
+		p = dg.randomNeighbor(p)
 		if !valid(p) {
 			block = block[:0]
 			p = dg.WallCell()
@@ -1446,10 +1474,10 @@ loop:
 			break
 		}
 		for i := 0; i < 20; i++ {
-			r := dg.rooms[RandInt(len(dg.rooms)-1)]
+			r := dg.rooms[g.randInt(len(dg.rooms)-1)]
 			for _, e := range r.places {
 				if e.kind == PlaceSpecialStatic {
-					p = r.RandomPlace(pl)
+					p = r.RandomPlace(dg, pl)
 					break
 				}
 			}
@@ -1457,8 +1485,8 @@ loop:
 				break loop
 			}
 		}
-		r := dg.rooms[RandInt(len(dg.rooms)-1)]
-		p = r.RandomPlace(pl)
+		r := dg.rooms[g.randInt(len(dg.rooms)-1)]
+		p = r.RandomPlace(dg, pl)
 	}
 	bandinfo.Path = append(bandinfo.Path, p)
 	bandinfo.Beh = BehGuard
@@ -1476,7 +1504,7 @@ func (dg *dgen) BandInfoGuardSpecial(g *game, band monsterBand) bandInfo {
 			p = dg.InsideCell(g)
 			break
 		}
-		p = r.RandomPlace(PlacePatrolSpecial)
+		p = r.RandomPlace(dg, PlacePatrolSpecial)
 		if p != invalidPos && !g.MonsterAt(p).Exists() {
 			break
 		}
@@ -1496,7 +1524,7 @@ func (dg *dgen) BandInfoPatrol(g *game, band monsterBand, pl placeKind) bandInfo
 			p = dg.InsideCell(g)
 			break
 		}
-		p = dg.rooms[RandInt(len(dg.rooms)-1)].RandomPlace(pl)
+		p = dg.rooms[g.randInt(len(dg.rooms)-1)].RandomPlace(dg, pl)
 	}
 	target := invalidPos
 	count = 0
@@ -1507,7 +1535,7 @@ func (dg *dgen) BandInfoPatrol(g *game, band monsterBand, pl placeKind) bandInfo
 			target = dg.InsideCell(g)
 			break
 		}
-		target = dg.rooms[RandInt(len(dg.rooms)-1)].RandomPlace(pl)
+		target = dg.rooms[g.randInt(len(dg.rooms)-1)].RandomPlace(dg, pl)
 	}
 	bandinfo.Path = append(bandinfo.Path, p)
 	bandinfo.Path = append(bandinfo.Path, target)
@@ -1526,7 +1554,7 @@ func (dg *dgen) BandInfoPatrolSpecial(g *game, band monsterBand) bandInfo {
 			p = dg.InsideCell(g)
 			break
 		}
-		p = r.RandomPlace(PlacePatrolSpecial)
+		p = r.RandomPlace(dg, PlacePatrolSpecial)
 		if p != invalidPos && !g.MonsterAt(p).Exists() {
 			break
 		}
@@ -1540,7 +1568,7 @@ func (dg *dgen) BandInfoPatrolSpecial(g *game, band monsterBand) bandInfo {
 			p = dg.InsideCell(g)
 			break
 		}
-		target = r.RandomPlace(PlacePatrolSpecial)
+		target = r.RandomPlace(dg, PlacePatrolSpecial)
 		if target != invalidPos {
 			break
 		}
@@ -1619,8 +1647,8 @@ func (g *game) FreeCellForMonster() gruid.Point {
 		if count > maxIterations {
 			panic("FreeCellForMonster")
 		}
-		x := RandInt(DungeonWidth)
-		y := RandInt(DungeonHeight)
+		x := g.randInt(DungeonWidth)
+		y := g.randInt(DungeonHeight)
 		p := gruid.Point{x, y}
 		c := d.Cell(p)
 		if !c.IsPassable() {
@@ -1651,7 +1679,7 @@ func (g *game) FreeCellForBandMonster(p gruid.Point) gruid.Point {
 			count = maxIterations + 1
 			continue
 		}
-		r := RandInt(len(neighbors))
+		r := g.randInt(len(neighbors))
 		p = neighbors[r]
 		if g.Player != nil && distance(g.Player.P, p) < 8 {
 			continue
@@ -1722,7 +1750,7 @@ func (dg *dgen) PutMonsterBand(g *game, band monsterBand) bool {
 			mons.State = Wandering
 		}
 		g.Monsters = append(g.Monsters, mons)
-		mons.Init()
+		mons.Init(g)
 		mons.Index = len(g.Monsters) - 1
 		mons.Band = len(g.Bands) - 1
 		mons.PlaceAtStart(g, p)
@@ -1735,12 +1763,12 @@ func (dg *dgen) PutMonsterBand(g *game, band monsterBand) bool {
 }
 
 func (dg *dgen) PutRandomBand(g *game, bands []monsterBand) bool {
-	return dg.PutMonsterBand(g, bands[RandInt(len(bands))])
+	return dg.PutMonsterBand(g, bands[g.randInt(len(bands))])
 }
 
 func (dg *dgen) PutRandomBandN(g *game, bands []monsterBand, n int) {
 	for i := 0; i < n; i++ {
-		dg.PutMonsterBand(g, bands[RandInt(len(bands))])
+		dg.PutMonsterBand(g, bands[g.randInt(len(bands))])
 	}
 }
 
@@ -1751,10 +1779,10 @@ func (dg *dgen) GenMonsters(g *game) {
 	bandsGuard := []monsterBand{LoneGuard}
 	bandsButterfly := []monsterBand{LoneButterfly}
 	bandsHighGuard := []monsterBand{LoneHighGuard}
-	bandsAnimals := []monsterBand{LoneYack, LoneWorm, LoneDog, LoneBlinkingFrog, LoneExplosiveNadre, LoneHarpy, LoneAcidMound}
-	bandsPlants := []monsterBand{LoneSatowalgaPlant}
-	bandsBipeds := []monsterBand{LoneOricCelmist, LoneMirrorSpecter, LoneWingedMilfid, LoneMadNixe, LoneVampire, LoneHarmonicCelmist}
-	bandsRare := []monsterBand{LoneTreeMushroom, LoneEarthDragon, LoneHazeCat, LoneSpider}
+	bandsAnimals := groupBands(GroupAnimals)
+	bandsPlants := groupBands(GroupPlants)
+	bandsBipeds := groupBands(GroupBipeds)
+	bandsRare := groupBands(GroupRare)
 	// monster specific bands
 	bandNadre := []monsterBand{LoneExplosiveNadre}
 	bandFrog := []monsterBand{LoneBlinkingFrog}
@@ -2196,4 +2224,11 @@ func (dg *dgen) GenMonsters(g *game) {
 			dg.PutRandomBandN(g, bandsPlants, 1)
 		}
 	}
+	if n := g.Params.ExtraBands[g.Depth]; n > 0 {
+		bandsAll := []monsterBand{}
+		for _, bands := range [][]monsterBand{bandsGuard, bandsAnimals, bandsPlants, bandsBipeds, bandsRare} {
+			bandsAll = append(bandsAll, bands...)
+		}
+		dg.PutRandomBandN(g, bandsAll, n)
+	}
 }
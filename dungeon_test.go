@@ -2,8 +2,11 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"flag"
 	"fmt"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,6 +16,8 @@ import (
 
 var Rounds = 40
 
+var genSeeds = flag.Int("genseeds", 10, "number of seeds per layout and depth in TestGenInvariants")
+
 func (d *dungeon) FreePassableCell() gruid.Point {
 	count := 0
 	for {
@@ -133,3 +138,243 @@ func TestNaturalCave(t *testing.T) {
 		}
 	}
 }
+
+func TestRoomTemplates(t *testing.T) {
+	for _, tpl := range roomTemplates {
+		if err := tpl.Check(); err != nil {
+			t.Errorf("%s: %v", tpl.Name, err)
+		}
+	}
+	for sr := roomMilfids; sr <= roomArtifact; sr++ {
+		if len(sr.Templates()) == 0 {
+			t.Errorf("no templates for special room %s", specialRoomNames[sr])
+		}
+	}
+	tpl, err := parseRoomTemplate("test.room", []byte(`kind big
+mindepth 3
+maxdepth 5
+placement edge
+weight 4
+---
+?#+#?
+#_._#
++.P.+
+#_.!#
+?#+#?
+`))
+	if err != nil {
+		t.Fatalf("parsing template: %v", err)
+	}
+	if tpl.Kind != roomKindBig || tpl.MinDepth != 3 || tpl.MaxDepth != 5 || tpl.Placement != PlacementEdge || tpl.Weight != 4 {
+		t.Errorf("bad metadata: %+v", tpl)
+	}
+	tpls := []roomTemplate{tpl}
+	if len(eligibleRoomTemplates(tpls, 4, PlacementEdge)) != 1 {
+		t.Errorf("template not eligible")
+	}
+	if len(eligibleRoomTemplates(tpls, 6, PlacementEdge)) != 0 || len(eligibleRoomTemplates(tpls, 4, PlacementRandom)) != 0 {
+		t.Errorf("template eligible outside of constraints")
+	}
+	bad := []string{
+		"---\n#+#\n#.#\n",           // no kind
+		"kind big\n---\n#X#\n+.#\n", // invalid rune
+		"kind big\n---\n###\n#.#\n", // no entries
+		"kind shaedra\n---\n#+#\n#S#\n",
+		"kind normal\nweight 0\n---\n#+#\n#.#\n",
+	}
+	for _, s := range bad {
+		if _, err := parseRoomTemplate("bad.room", []byte(s)); err == nil {
+			t.Errorf("invalid template parsed:\n%s", s)
+		}
+	}
+}
+
+// genLevel generates a level at the given depth and layout, returning an
+// error if generation panics.
+func genLevel(seed int64, ml maplayout, depth int) (g *game, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("generation panic: %v", r)
+		}
+	}()
+	g = &game{Seed: seed}
+	g.initRand()
+	g.InitFirstLevel()
+	g.Depth = depth
+	g.InitLevelStructures()
+	g.GenRoomTunnels(ml)
+	return g, nil
+}
+
+// checkGenInvariants returns an error if a generated level does not satisfy
+// level generation invariants.
+func (g *game) checkGenInvariants() error {
+	d := g.Dungeon
+	if !valid(g.Player.P) || !d.Cell(g.Player.P).IsPlayerPassable() {
+		return fmt.Errorf("bad player start %v", g.Player.P)
+	}
+	it := d.Grid.Iterator()
+	for it.Next() {
+		d.SetExplored(it.P())
+	}
+	if g.Depth < MaxDepth && len(g.Objects.Stairs) == 0 {
+		return errors.New("no stairs")
+	}
+	for p := range g.Objects.Stairs {
+		if terrain(d.Cell(p)) != StairCell {
+			return fmt.Errorf("stairs at %v on %s", p, d.Cell(p).Name())
+		}
+		if g.PlayerPath(g.Player.P, p) == nil {
+			return fmt.Errorf("unreachable stairs at %v", p)
+		}
+	}
+	nmagaras := 1
+	if g.Depth == WinDepth {
+		nmagaras = 0
+	}
+	if len(g.Objects.Magaras) != nmagaras {
+		return fmt.Errorf("%d magaras instead of %d", len(g.Objects.Magaras), nmagaras)
+	}
+	var stories []gruid.Point
+	switch g.Depth {
+	case WinDepth:
+		stories = []gruid.Point{g.Places.Shaedra, g.Places.Monolith, g.Places.Marevor}
+	case MaxDepth:
+		stories = []gruid.Point{g.Places.Artifact, g.Places.Monolith, g.Places.Marevor}
+	}
+	for _, p := range stories {
+		if _, ok := g.Objects.Story[p]; !ok || !valid(p) || terrain(d.Cell(p)) != StoryCell {
+			return fmt.Errorf("story place %v not placed", p)
+		}
+	}
+	objects := []struct {
+		ps map[gruid.Point]bool
+		c  cell
+	}{
+		{g.Objects.Bananas, BananaCell},
+		{g.Objects.Barrels, BarrelCell},
+		{mapPoints(g.Objects.Items), ItemCell},
+		{mapPoints(g.Objects.Magaras), MagaraCell},
+		{mapPoints(g.Objects.Stones), StoneCell},
+		{mapPoints(g.Objects.Scrolls), ScrollCell},
+		{mapPoints(g.Objects.Potions), PotionCell},
+	}
+	for _, obj := range objects {
+		for p := range obj.ps {
+			if c := terrain(d.Cell(p)); c != obj.c {
+				return fmt.Errorf("%s at %v on %s", obj.c.Name(), p, c.Name())
+			}
+		}
+	}
+	for i, m := range g.Monsters {
+		if m.Index != i {
+			return fmt.Errorf("monster %d with index %d", i, m.Index)
+		}
+		if !valid(m.P) || !d.Cell(m.P).IsPassable() {
+			return fmt.Errorf("%v on impassable cell at %v", m.Kind, m.P)
+		}
+		if g.MonstersPosCache[idx(m.P)] != i+1 {
+			return fmt.Errorf("%v at %v not in position cache", m.Kind, m.P)
+		}
+		if m.Band < 0 || m.Band >= len(g.Bands) {
+			return fmt.Errorf("%v with invalid band %d", m.Kind, m.Band)
+		}
+		band := g.Bands[m.Band]
+		bd := MonsBands[band.Kind]
+		if bd.Band && bd.Distribution[m.Kind] == 0 || !bd.Band && bd.Monster != m.Kind {
+			return fmt.Errorf("%v in band %d of wrong kind %d", m.Kind, m.Band, band.Kind)
+		}
+		if len(band.Path) == 0 {
+			return fmt.Errorf("%v in band %d without path", m.Kind, m.Band)
+		}
+	}
+	return nil
+}
+
+func mapPoints(m interface{}) map[gruid.Point]bool {
+	ps := map[gruid.Point]bool{}
+	switch m := m.(type) {
+	case map[gruid.Point]item:
+		for p := range m {
+			ps[p] = true
+		}
+	case map[gruid.Point]magara:
+		for p := range m {
+			ps[p] = true
+		}
+	case map[gruid.Point]stone:
+		for p := range m {
+			ps[p] = true
+		}
+	case map[gruid.Point]scroll:
+		for p := range m {
+			ps[p] = true
+		}
+	case map[gruid.Point]potion:
+		for p := range m {
+			ps[p] = true
+		}
+	}
+	return ps
+}
+
+// TestGenInvariants checks level generation invariants for every layout and
+// depth. The number of seeds can be increased with the -genseeds flag, for
+// example: go test -run GenInvariants -args -genseeds 1000
+func TestGenInvariants(t *testing.T) {
+	for _, ml := range mapLayouts {
+		for depth := 1; depth <= MaxDepth; depth++ {
+			for i := 0; i < *genSeeds; i++ {
+				seed := int64(i + 1)
+				g, err := genLevel(seed, ml, depth)
+				if err == nil {
+					err = g.checkGenInvariants()
+				}
+				if err != nil {
+					msg := fmt.Sprintf("layout %s depth %d seed %d: %v", ml, depth, seed, err)
+					if g != nil && g.Dungeon != nil {
+						msg += "\n" + g.Dungeon.String()
+					}
+					t.Error(msg)
+				}
+			}
+		}
+	}
+}
+
+func TestGenStats(t *testing.T) {
+	for _, ml := range mapLayouts {
+		g, err := genLevel(1, ml, 4)
+		if err != nil {
+			t.Fatalf("layout %s: %v", ml, err)
+		}
+		ls := g.GenLevelStats()
+		if ls.Layout != ml {
+			t.Errorf("layout %s: recorded layout %s", ml, ls.Layout)
+		}
+		if ls.Rooms == 0 || ls.Doors == 0 || ls.Free == 0 || ls.Bands == 0 {
+			t.Errorf("layout %s: bad statistics %+v", ml, ls)
+		}
+		n := 0
+		for _, bn := range ls.BandKinds {
+			n += bn
+		}
+		if n != ls.Bands {
+			t.Errorf("layout %s: %d bands by kind, expected %d", ml, n, ls.Bands)
+		}
+	}
+	if LoneDog.String() != "dog" || PairGuard.String() != "guard x2" {
+		t.Errorf("bad band descriptions: %q %q", LoneDog, PairGuard)
+	}
+	var buf bytes.Buffer
+	GenStats(&buf, 2, 1)
+	out := buf.String()
+	if !strings.HasPrefix(out, "Games: 2\n") || !strings.Contains(out, "\nMonster bands (average per level):\n") {
+		t.Errorf("bad report sections: %q", out)
+	}
+	for d := 1; d <= MaxDepth; d++ {
+		if !strings.Contains(out, fmt.Sprintf("| %5d |", d)) {
+			t.Errorf("no statistics for depth %d", d)
+		}
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"compress/zlib"
 	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
 
 	"github.com/anaseto/gruid"
 )
@@ -17,6 +20,61 @@ func init() {
 	gob.Register(endTurnEvent(0))
 }
 
+// saveFormat is the current version of the save format. It has to be
+// incremented whenever a change in the game structure breaks decoding of
+// older saves or requires adjusting their data, and a migration has then to
+// be added to saveMigrations.
+const saveFormat = 2
+
+// saveEnvelope wraps the encoded game in a save file.
+type saveEnvelope struct {
+	Format   int    // save format version
+	Version  string // version of the game that wrote the save
+	Checksum uint32 // CRC-32 checksum of Data
+	Data     []byte // zlib-compressed gob encoding of the game
+}
+
+// saveMigrations contains, for each save format version n, the function
+// that converts a game decoded from a save in format n into format n+1.
+var saveMigrations = [saveFormat]func(g *game) error{
+	0: func(g *game) error {
+		// format 0 saves had no envelope, but the game is the same
+		return nil
+	},
+	1: func(g *game) error {
+		// bestiary statistics
+		g.Stats.SeenMons = map[monsterKind]int{}
+		g.Stats.SpottingMons = map[monsterKind]int{}
+		g.Stats.MonsDepths = map[monsterKind]map[int]bool{}
+		return nil
+	},
+}
+
+// saveVersionError is returned when a save was written by an incompatible
+// version of the game.
+type saveVersionError struct {
+	Version string // version that wrote the save
+	Format  int    // save format version
+}
+
+func (err saveVersionError) Error() string {
+	v := err.Version
+	if v == "" {
+		v = "unknown"
+	}
+	return fmt.Sprintf("save from incompatible version %s (format %d) cannot be loaded by version %s", v, err.Format, Version)
+}
+
+var errSaveChecksum = errors.New("corrupted save: checksum mismatch")
+
+var errSaveCorrupted = errors.New("corrupted save: invalid envelope")
+
+// zlibHeader reports whether data starts with a zlib stream header, as saves
+// without envelope do.
+func zlibHeader(data []byte) bool {
+	return len(data) >= 2 && data[0]&0x0f == 8 && data[0]>>4 <= 7 && (uint(data[0])<<8|uint(data[1]))%31 == 0
+}
+
 func (g *game) GameSave() ([]byte, error) {
 	data := bytes.Buffer{}
 	enc := gob.NewEncoder(&data)
@@ -28,7 +86,19 @@ func (g *game) GameSave() ([]byte, error) {
 	w := zlib.NewWriter(&buf)
 	w.Write(data.Bytes())
 	w.Close()
-	return buf.Bytes(), nil
+	env := saveEnvelope{
+		Format:   saveFormat,
+		Version:  g.Version,
+		Checksum: crc32.ChecksumIEEE(buf.Bytes()),
+		Data:     buf.Bytes(),
+	}
+	data.Reset()
+	enc = gob.NewEncoder(&data)
+	err = enc.Encode(&env)
+	if err != nil {
+		return nil, err
+	}
+	return data.Bytes(), nil
 }
 
 type config struct {
@@ -38,6 +108,8 @@ type config struct {
 	Tiles          bool
 	Version        string
 	ShowNumbers    bool
+	VisionOverlay  bool
+	Theme          string
 }
 
 func (c *config) ConfigSave() ([]byte, error) {
@@ -50,19 +122,45 @@ func (c *config) ConfigSave() ([]byte, error) {
 	return data.Bytes(), nil
 }
 
+// DecodeGameSave decodes a save, migrating it to the current format if
+// needed. Saves from before the introduction of save format versions are
+// handled as format 0.
 func (g *game) DecodeGameSave(data []byte) (*game, error) {
-	buf := bytes.NewReader(data)
-	r, err := zlib.NewReader(buf)
+	env := &saveEnvelope{}
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err := dec.Decode(env)
+	if err != nil || env.Format == 0 {
+		if !zlibHeader(data) {
+			return nil, errSaveCorrupted
+		}
+		// save without envelope
+		env = &saveEnvelope{Data: data}
+	} else if crc32.ChecksumIEEE(env.Data) != env.Checksum {
+		return nil, errSaveChecksum
+	}
+	if env.Format > saveFormat {
+		return nil, saveVersionError{Version: env.Version, Format: env.Format}
+	}
+	r, err := zlib.NewReader(bytes.NewReader(env.Data))
 	if err != nil {
 		return nil, err
 	}
-	dec := gob.NewDecoder(r)
+	dec = gob.NewDecoder(r)
 	lg := &game{}
 	err = dec.Decode(lg)
 	if err != nil {
+		if env.Format < saveFormat {
+			return nil, saveVersionError{Version: lg.Version, Format: env.Format}
+		}
 		return nil, err
 	}
 	r.Close()
+	for n := env.Format; n < saveFormat; n++ {
+		err := saveMigrations[n](lg)
+		if err != nil {
+			return nil, fmt.Errorf("migrating save from format %d: %v", n, err)
+		}
+	}
 	return lg, nil
 }
 
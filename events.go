@@ -39,6 +39,13 @@ func (ev *playerEvent) Handle(g *game) {
 	switch ev.Action {
 	case StorySequence:
 		g.ComputeLOS()
+		if g.md == nil {
+			// the sequence goes on at the end of the turn, as it
+			// would after the player's confirmations
+			g.StoryStep(0)
+			g.storyPending = true
+			break
+		}
 		g.md.Story()
 	case AbyssFall:
 		if terrain(g.Dungeon.Cell(g.Player.P)) == ChasmCell {
@@ -171,13 +178,13 @@ func (cev *posEvent) Handle(g *game) {
 			g.StopAuto()
 		}
 		g.PushEvent(&posEvent{Action: ObstructionProgression},
-			g.Turn+DurationObstructionProgression+RandInt(DurationObstructionProgression/4))
+			g.Turn+DurationObstructionProgression+g.randInt(DurationObstructionProgression/4))
 	case FireProgression:
 		if _, ok := g.Clouds[cev.P]; !ok {
 			break
 		}
 		for _, p := range g.playerPassableNeighbors(cev.P) {
-			if RandInt(10) == 0 {
+			if g.randInt(10) == 0 {
 				continue
 			}
 			g.Burn(p)
@@ -201,7 +208,7 @@ func (cev *posEvent) Handle(g *game) {
 		p := g.FreePassableCell()
 		g.Fog(p, 1)
 		g.PushEvent(&posEvent{Action: MistProgression},
-			g.Turn+DurationMistProgression+RandInt(DurationMistProgression/4))
+			g.Turn+DurationMistProgression+g.randInt(DurationMistProgression/4))
 	case Earthquake:
 		g.PrintStyled("The earth suddenly shakes with force!", logSpecial)
 		g.PrintStyled("Craack!", logSpecial)
@@ -215,7 +222,7 @@ func (cev *posEvent) Handle(g *game) {
 			if !c.IsDiggable() || !g.Dungeon.HasFreeNeighbor(&g.nbs, p) {
 				continue
 			}
-			if distance(cev.P, p) > RandInt(35) || RandInt(2) == 0 {
+			if distance(cev.P, p) > g.randInt(35) || g.randInt(2) == 0 {
 				continue
 			}
 			g.Dungeon.SetCell(p, RubbleCell)
@@ -295,7 +302,7 @@ func (g *game) MakeCreatureSleep(p gruid.Point) {
 		return
 	}
 	mons := g.MonsterAt(p)
-	if !mons.Exists() || (RandInt(2) == 0 && mons.Status(MonsExhausted)) {
+	if !mons.Exists() || (g.randInt(2) == 0 && mons.Status(MonsExhausted)) {
 		// do not always make already exhausted monsters sleep (they were probably awaken)
 		return
 	}
@@ -305,7 +312,7 @@ func (g *game) MakeCreatureSleep(p gruid.Point) {
 	}
 	mons.State = Resting
 	mons.Dir = ZP
-	mons.ExhaustTime(g, 4+RandInt(2))
+	mons.ExhaustTime(g, 4+g.randInt(2))
 }
 
 func (g *game) Burn(p gruid.Point) {
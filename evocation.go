@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anaseto/gruid"
+	"github.com/anaseto/gruid/ui"
+)
+
+// magaraPreview describes the expected effects of evoking a magara, as shown
+// before confirming the evocation.
+type magaraPreview struct {
+	Area    string               // description of the affected area
+	Cells   map[gruid.Point]bool // affected cells
+	Targets []*monster           // monsters that would be affected
+	Ignored []string             // monsters in range that would not be affected, with reason
+	Max     int                  // if positive, maximum number of random monsters in range targeted
+	Warning string               // possible harmful effect on the player
+}
+
+func (mp *magaraPreview) addTarget(m *monster) {
+	mp.Targets = append(mp.Targets, m)
+	mp.Cells[m.P] = true
+}
+
+func (mp *magaraPreview) ignore(m *monster, reason string) {
+	mp.Ignored = append(mp.Ignored, fmt.Sprintf("%s (%s)", m.Kind, reason))
+}
+
+func (mp *magaraPreview) addRay(ray []gruid.Point) {
+	for _, p := range ray {
+		mp.Cells[p] = true
+	}
+}
+
+// MagaraPreview returns the expected effects of evoking a magara of the given
+// kind from the player's position. It reports false for magaras without
+// area of effect, and for magaras targeting monsters when no monster would be
+// affected, as the evocation fails then anyway without spending a charge.
+//
+// The preview neither changes the game state nor uses the random number
+// generator, so that it does not alter replays.
+func (g *game) MagaraPreview(k magaraKind) (magaraPreview, bool) {
+	mp := magaraPreview{Cells: map[gruid.Point]bool{}}
+	switch k {
+	case FogMagara:
+		mp.Area = "fog around you"
+		dij := &noisePath{g: g}
+		for _, n := range g.PR.DijkstraMap(dij, []gruid.Point{g.Player.P}, 3) {
+			_, ok := g.Clouds[n.P]
+			if !ok && g.Dungeon.Cell(n.P).AllowsFog() {
+				mp.Cells[n.P] = true
+			}
+		}
+		return mp, len(mp.Cells) > 0
+	case SleepingMagara:
+		mp.Area = "monsters in cardinal directions"
+		mp.Max = 3
+		for _, m := range g.monstersInSight(true) {
+			if m.State == Resting {
+				mp.ignore(m, "resting")
+				continue
+			}
+			mp.addTarget(m)
+			mp.addRay(g.Ray(m.P))
+		}
+	case TeleportOtherMagara:
+		mp.Area = "monsters in cardinal directions"
+		mp.Max = 2
+		for _, m := range g.monstersInSight(true) {
+			mp.addTarget(m)
+			if m.Kind.ReflectsTeleport() {
+				mp.Warning = fmt.Sprintf("The %s reflects teleportation: you may be teleported too.", m.Kind)
+			}
+		}
+	case ParalysisMagara, ConfusionMagara:
+		mp.Area = "monsters in view"
+		st := MonsParalysed
+		if k == ConfusionMagara {
+			st = MonsConfused
+		}
+		for p, b := range g.Player.LOS {
+			if b {
+				mp.Cells[p] = true
+			}
+		}
+		for _, m := range g.monstersInSight(false) {
+			if m.Status(st) {
+				mp.ignore(m, fmt.Sprintf("already %s", st))
+				continue
+			}
+			mp.addTarget(m)
+		}
+	case LignificationMagara:
+		mp.Area = "monsters in view"
+		mp.Max = 2
+		for _, m := range g.monstersInSight(false) {
+			switch {
+			case m.Kind.ResistsLignification():
+				mp.ignore(m, "resists")
+			case m.Status(MonsLignified):
+				mp.ignore(m, "already lignified")
+			default:
+				mp.addTarget(m)
+				mp.addRay(g.Ray(m.P))
+			}
+		}
+	case DelayedOricExplosionMagara:
+		mp.Area = "walls around you, and monsters hearing the explosion"
+		dij := &gridPath{dungeon: g.Dungeon}
+		for _, n := range g.PR.DijkstraMap(dij, []gruid.Point{g.Player.P}, 7) {
+			c := g.Dungeon.Cell(n.P)
+			if explored(c) && c.IsDiggable() {
+				mp.Cells[n.P] = true
+			}
+		}
+		g.PR.BreadthFirstMap(&noisePath{g: g}, []gruid.Point{g.Player.P}, OricExplosionNoise)
+		for _, m := range g.Monsters {
+			q, st, ok := g.knownMonster(m)
+			if !ok {
+				continue
+			}
+			if m.HearsNoise(st, g.PR.BreadthFirstMapAt(q), OricExplosionNoise) {
+				mp.Targets = append(mp.Targets, m)
+				mp.Cells[q] = true
+			}
+		}
+		return mp, true
+	default:
+		return mp, false
+	}
+	return mp, len(mp.Targets) > 0
+}
+
+func monsterNames(ms []*monster) string {
+	names := []string{}
+	for _, m := range ms {
+		names = append(names, m.Kind.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// confirmEvocation shows the preview of the n-th magara and asks for
+// confirmation. It reports false if the magara has no preview, in which case
+// it should be evoked directly.
+func (md *model) confirmEvocation(n int) bool {
+	g := md.g
+	if g.CanUseMagara(n) != nil {
+		return false
+	}
+	mag := g.Player.Magaras[n]
+	mp, ok := g.MagaraPreview(mag.Kind)
+	if !ok {
+		return false
+	}
+	g.Highlight = mp.Cells
+	lines := []string{"@tArea:@N " + mp.Area}
+	if len(mp.Targets) > 0 {
+		lines = append(lines, "@tAffected:@N "+monsterNames(mp.Targets))
+	}
+	if len(mp.Ignored) > 0 {
+		lines = append(lines, "@tUnaffected:@N "+strings.Join(mp.Ignored, ", "))
+	}
+	if mp.Max > 0 && len(mp.Targets)+len(mp.Ignored) > mp.Max {
+		lines = append(lines, fmt.Sprintf("Only %d random monsters in range will be targeted.", mp.Max))
+	}
+	if mp.Warning != "" {
+		lines = append(lines, "@wWarning:@N "+mp.Warning)
+	}
+	stt := ui.Text(strings.Join(lines, "\n")).WithMarkups(map[rune]gruid.Style{
+		't': gruid.Style{}.WithFg(ColorYellow),
+		'w': gruid.Style{}.WithFg(ColorRed),
+	})
+	md.description.Content = stt.Format(UIWidth - 2)
+	md.description.Box = &ui.Box{Title: ui.Text(mag.String())}
+	md.evoked = n
+	g.PrintfStyled("Evoke the %s? [y/N]", logConfirm, mag)
+	md.mode = modeEvokeConfirmation
+	return true
+}
+
+// drawEvocationPreview draws the description of the evocation preview on the
+// half of the map opposite to the player.
+func (md *model) drawEvocationPreview() {
+	h := md.description.Content.Size().Y + 2
+	rg := md.gd.Range().Lines(UIHeight-1-h, UIHeight-1)
+	if md.g.Player.P.Y >= DungeonHeight/2 {
+		rg = md.gd.Range().Lines(2, 2+h)
+	}
+	md.description.Draw(md.gd.Slice(rg))
+}
@@ -0,0 +1,529 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/anaseto/gruid"
+)
+
+// stateExport is the JSON representation of a game state, as written by
+// ExportState, for use by external tools. Positions are given as {"x", "y"}
+// objects in map coordinates, (0, 0) being the top-left corner. Kinds of
+// monsters, objects and statuses are given by their names as shown in game,
+// and kinds of terrain by the names returned by cell.Name. The schema is the
+// following:
+//
+//	{
+//	  "version": "v0.4.1",          // game version
+//	  "seed": 1234,                 // game seed
+//	  "depth": 3,                   // current depth
+//	  "turn": 456,                  // current turn
+//	  "map": {
+//	    "width": 80, "height": 21,
+//	    "terrain": [["wall", "ground", ...], ...], // rows of actual terrain
+//	    "explored": [[false, true, ...], ...]      // rows of explored flags
+//	  },
+//	  "terrain_knowledge": [        // terrain as remembered by the player,
+//	    {"pos": {"x": 4, "y": 5}, "terrain": "door"}, // when it changed since
+//	    ...
+//	  ],
+//	  "player": {
+//	    "pos": {...}, "dir": {...},
+//	    "hp": 4, "hp_max": 5, "mp": 3, "mp_max": 3, "bananas": 1,
+//	    "magaras": [{"kind": "blink magara", "charges": 3}, ...],
+//	    "inventory": {"body": "...", "neck": "...", "misc": "..."},
+//	    "statuses": {"Swiftness": 3, ...} // remaining turns (or count)
+//	  },
+//	  "monsters": [
+//	    {
+//	      "index": 0, "kind": "guard", "letter": "G", "band": 2,
+//	      "pos": {...}, "dir": {...}, "dead": false,
+//	      "state": "resting", "alerted": false, "seen": true,
+//	      "statuses": {"confused": 2}, // remaining turns
+//	      "target": {...}, "last_known_pos": {...},
+//	      "path": [{...}, ...]          // current planned path
+//	    },
+//	    ...
+//	  ],
+//	  "objects": {
+//	    "stairs": [{"pos": {...}, "kind": "..."}, ...],
+//	    "fake_stairs": [{...}, ...],
+//	    "stones": [{"pos": {...}, "kind": "..."}, ...],
+//	    "magaras": [{"pos": {...}, "kind": "...", "charges": 2}, ...],
+//	    "items": [{"pos": {...}, "kind": "..."}, ...],
+//	    "scrolls": [...], "potions": [...], "story": [...],
+//	    "bananas": [{...}, ...],
+//	    "barrels": [{...}, ...],
+//	    "lights": [{"pos": {...}, "on": true}, ...]
+//	  }
+//	}
+//
+// Invalid positions (like the target of a monster without target) are given
+// as {"x": -1, "y": -1}. Lists of objects are sorted by line, then column.
+type stateExport struct {
+	Version          string          `json:"version"`
+	Seed             int64           `json:"seed"`
+	Depth            int             `json:"depth"`
+	Turn             int             `json:"turn"`
+	Map              mapExport       `json:"map"`
+	TerrainKnowledge []terrainExport `json:"terrain_knowledge"`
+	Player           playerExport    `json:"player"`
+	Monsters         []monsterExport `json:"monsters"`
+	Objects          objectsExport   `json:"objects"`
+}
+
+type pointExport struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func exportPoint(p gruid.Point) pointExport {
+	return pointExport{X: p.X, Y: p.Y}
+}
+
+func exportPoints(ps []gruid.Point) []pointExport {
+	eps := []pointExport{}
+	for _, p := range ps {
+		eps = append(eps, exportPoint(p))
+	}
+	return eps
+}
+
+type mapExport struct {
+	Width    int        `json:"width"`
+	Height   int        `json:"height"`
+	Terrain  [][]string `json:"terrain"`
+	Explored [][]bool   `json:"explored"`
+}
+
+type terrainExport struct {
+	Pos     pointExport `json:"pos"`
+	Terrain string      `json:"terrain"`
+}
+
+type magaraExport struct {
+	Kind    string `json:"kind"`
+	Charges int    `json:"charges"`
+}
+
+type inventoryExport struct {
+	Body string `json:"body"`
+	Neck string `json:"neck"`
+	Misc string `json:"misc"`
+}
+
+type playerExport struct {
+	Pos       pointExport     `json:"pos"`
+	Dir       pointExport     `json:"dir"`
+	HP        int             `json:"hp"`
+	HPMax     int             `json:"hp_max"`
+	MP        int             `json:"mp"`
+	MPMax     int             `json:"mp_max"`
+	Bananas   int             `json:"bananas"`
+	Magaras   []magaraExport  `json:"magaras"`
+	Inventory inventoryExport `json:"inventory"`
+	Statuses  map[string]int  `json:"statuses"`
+}
+
+type monsterExport struct {
+	Index        int            `json:"index"`
+	Kind         string         `json:"kind"`
+	Letter       string         `json:"letter"`
+	Band         int            `json:"band"`
+	Pos          pointExport    `json:"pos"`
+	Dir          pointExport    `json:"dir"`
+	Dead         bool           `json:"dead"`
+	State        string         `json:"state"`
+	Alerted      bool           `json:"alerted"`
+	Seen         bool           `json:"seen"`
+	Statuses     map[string]int `json:"statuses"`
+	Target       pointExport    `json:"target"`
+	LastKnownPos pointExport    `json:"last_known_pos"`
+	Path         []pointExport  `json:"path"`
+}
+
+type objectExport struct {
+	Pos     pointExport `json:"pos"`
+	Kind    string      `json:"kind"`
+	Charges int         `json:"charges,omitempty"`
+}
+
+type lightExport struct {
+	Pos pointExport `json:"pos"`
+	On  bool        `json:"on"`
+}
+
+type objectsExport struct {
+	Stairs     []objectExport `json:"stairs"`
+	FakeStairs []pointExport  `json:"fake_stairs"`
+	Stones     []objectExport `json:"stones"`
+	Magaras    []objectExport `json:"magaras"`
+	Items      []objectExport `json:"items"`
+	Scrolls    []objectExport `json:"scrolls"`
+	Potions    []objectExport `json:"potions"`
+	Story      []objectExport `json:"story"`
+	Bananas    []pointExport  `json:"bananas"`
+	Barrels    []pointExport  `json:"barrels"`
+	Lights     []lightExport  `json:"lights"`
+}
+
+// ExportState returns the JSON representation of the game state, as
+// described in stateExport.
+func (g *game) ExportState() ([]byte, error) {
+	st := &stateExport{
+		Version:  g.Version,
+		Seed:     g.Seed,
+		Depth:    g.Depth,
+		Turn:     g.Turn,
+		Map:      g.exportMap(),
+		Player:   g.exportPlayer(),
+		Monsters: []monsterExport{},
+		Objects:  g.exportObjects(),
+	}
+	ps := []gruid.Point{}
+	for p := range g.TerrainKnowledge {
+		ps = append(ps, p)
+	}
+	sortPoints(ps)
+	st.TerrainKnowledge = []terrainExport{}
+	for _, p := range ps {
+		st.TerrainKnowledge = append(st.TerrainKnowledge, terrainExport{Pos: exportPoint(p), Terrain: g.TerrainKnowledge[p].Name()})
+	}
+	for _, m := range g.Monsters {
+		st.Monsters = append(st.Monsters, exportMonster(m))
+	}
+	return json.MarshalIndent(st, "", "  ")
+}
+
+func (g *game) exportMap() mapExport {
+	max := g.Dungeon.Grid.Size()
+	mp := mapExport{Width: max.X, Height: max.Y}
+	for y := 0; y < max.Y; y++ {
+		terrains := make([]string, max.X)
+		expl := make([]bool, max.X)
+		for x := 0; x < max.X; x++ {
+			c := g.Dungeon.Cell(gruid.Point{X: x, Y: y})
+			terrains[x] = c.Name()
+			expl[x] = explored(c)
+		}
+		mp.Terrain = append(mp.Terrain, terrains)
+		mp.Explored = append(mp.Explored, expl)
+	}
+	return mp
+}
+
+func (g *game) exportPlayer() playerExport {
+	pl := g.Player
+	pe := playerExport{
+		Pos:     exportPoint(pl.P),
+		Dir:     exportPoint(pl.Dir),
+		HP:      pl.HP,
+		HPMax:   pl.HPMax(),
+		MP:      pl.MP,
+		MPMax:   pl.MPMax(),
+		Bananas: pl.Bananas,
+		Magaras: []magaraExport{},
+		Inventory: inventoryExport{
+			Body: pl.Inventory.Body.String(),
+			Neck: pl.Inventory.Neck.String(),
+			Misc: pl.Inventory.Misc.String(),
+		},
+		Statuses: map[string]int{},
+	}
+	for _, mag := range pl.Magaras {
+		pe.Magaras = append(pe.Magaras, magaraExport{Kind: mag.String(), Charges: mag.Charges})
+	}
+	for st, n := range pl.Statuses {
+		if n > 0 {
+			pe.Statuses[st.String()] = n
+		}
+	}
+	return pe
+}
+
+func exportMonster(m *monster) monsterExport {
+	me := monsterExport{
+		Index:        m.Index,
+		Kind:         m.Kind.String(),
+		Letter:       string(m.Kind.Letter()),
+		Band:         m.Band,
+		Pos:          exportPoint(m.P),
+		Dir:          exportPoint(m.Dir),
+		Dead:         m.Dead,
+		State:        m.State.String(),
+		Alerted:      m.Alerted,
+		Seen:         m.Seen,
+		Statuses:     map[string]int{},
+		Target:       exportPoint(m.Target),
+		LastKnownPos: exportPoint(m.LastKnownPos),
+		Path:         exportPoints(m.Path),
+	}
+	for st, n := range m.Statuses {
+		if n > 0 {
+			me.Statuses[monsterStatus(st).String()] = n
+		}
+	}
+	return me
+}
+
+func (g *game) exportObjects() objectsExport {
+	obj := g.Objects
+	oe := objectsExport{
+		Stairs:     []objectExport{},
+		FakeStairs: exportPoints(sortedPoints(obj.FakeStairs)),
+		Stones:     []objectExport{},
+		Magaras:    []objectExport{},
+		Items:      []objectExport{},
+		Scrolls:    []objectExport{},
+		Potions:    []objectExport{},
+		Story:      []objectExport{},
+		Bananas:    exportPoints(sortedPoints(obj.Bananas)),
+		Barrels:    exportPoints(sortedPoints(obj.Barrels)),
+		Lights:     []lightExport{},
+	}
+	for p, o := range obj.Stairs {
+		oe.Stairs = append(oe.Stairs, objectExport{Pos: exportPoint(p), Kind: o.String()})
+	}
+	sortObjectExports(oe.Stairs)
+	for p, o := range obj.Stones {
+		oe.Stones = append(oe.Stones, objectExport{Pos: exportPoint(p), Kind: o.String()})
+	}
+	sortObjectExports(oe.Stones)
+	for p, mag := range obj.Magaras {
+		oe.Magaras = append(oe.Magaras, objectExport{Pos: exportPoint(p), Kind: mag.String(), Charges: mag.Charges})
+	}
+	sortObjectExports(oe.Magaras)
+	for p, o := range obj.Items {
+		oe.Items = append(oe.Items, objectExport{Pos: exportPoint(p), Kind: o.String()})
+	}
+	sortObjectExports(oe.Items)
+	for p, o := range obj.Scrolls {
+		oe.Scrolls = append(oe.Scrolls, objectExport{Pos: exportPoint(p), Kind: o.String()})
+	}
+	sortObjectExports(oe.Scrolls)
+	for p, o := range obj.Potions {
+		oe.Potions = append(oe.Potions, objectExport{Pos: exportPoint(p), Kind: o.String()})
+	}
+	sortObjectExports(oe.Potions)
+	for p, o := range obj.Story {
+		oe.Story = append(oe.Story, objectExport{Pos: exportPoint(p), Kind: o.String()})
+	}
+	sortObjectExports(oe.Story)
+	for _, p := range sortedPoints(obj.Lights) {
+		oe.Lights = append(oe.Lights, lightExport{Pos: exportPoint(p), On: obj.Lights[p]})
+	}
+	return oe
+}
+
+// sortObjectExports sorts objects in dungeon index order.
+func sortObjectExports(objs []objectExport) {
+	sort.Slice(objs, func(i, j int) bool {
+		return idx(gruid.Point{objs[i].Pos.X, objs[i].Pos.Y}) < idx(gruid.Point{objs[j].Pos.X, objs[j].Pos.Y})
+	})
+}
+
+// dumpExport is the JSON character dump, written by WriteDump next to the
+// text dump, for use by external tools. It uses the same conventions as
+// stateExport, and contains the following:
+//
+//	{
+//	  "version": "v0.4.1", "seed": 1234,
+//	  "wizard": false, "preset": "", "daily": "",
+//	  "outcome": "death",           // "death", "escape" or "exploring"
+//	  "depth": 3,                   // current depth (-1 after escaping)
+//	  "max_depth": 3,               // deepest explored depth
+//	  "turn": 456,
+//	  "shaedra": false, "artifact": false, // rescued, recovered
+//	  "player": {...},              // as in stateExport
+//	  "killed_monsters": {"guard": 2, ...},
+//	  "bestiary": {...},            // as in the bestiary file
+//	  "story": ["...", ...],        // timeline
+//	  "stats": {...},               // see statsExport
+//	  "dungeon": {...}              // final level, as "map" in stateExport
+//	}
+type dumpExport struct {
+	Version        string         `json:"version"`
+	Seed           int64          `json:"seed"`
+	Wizard         bool           `json:"wizard"`
+	Preset         string         `json:"preset"`
+	Daily          string         `json:"daily"`
+	Outcome        string         `json:"outcome"`
+	Depth          int            `json:"depth"`
+	MaxDepth       int            `json:"max_depth"`
+	Turn           int            `json:"turn"`
+	Shaedra        bool           `json:"shaedra"`
+	Artifact       bool           `json:"artifact"`
+	Player         playerExport   `json:"player"`
+	KilledMonsters map[string]int `json:"killed_monsters"`
+	Bestiary       bestiary       `json:"bestiary"`
+	Story          []string       `json:"story"`
+	Stats          statsExport    `json:"stats"`
+	Dungeon        mapExport      `json:"dungeon"`
+}
+
+// statsExport is the JSON representation of the game statistics. Per-depth
+// statistics (fields with a "d_" prefix) are arrays whose first element is
+// for depth 1. Kinds of magaras and statuses are given by their names, and
+// lore messages by the depths at which they were read.
+type statsExport struct {
+	Killed            int            `json:"killed"`
+	Moves             int            `json:"moves"`
+	Waits             int            `json:"waits"`
+	Jumps             int            `json:"jumps"`
+	WallJumps         int            `json:"wall_jumps"`
+	ReceivedHits      int            `json:"received_hits"`
+	Dodges            int            `json:"dodges"`
+	MagarasUsed       int            `json:"magaras_used"`
+	DMagaraUses       []int          `json:"d_magara_uses"`
+	UsedStones        int            `json:"used_stones"`
+	UsedMagaras       map[string]int `json:"used_magaras"`
+	Damage            int            `json:"damage"`
+	DDamage           []int          `json:"d_damage"`
+	DExplPerc         []int          `json:"d_expl_perc"`
+	DSleepingPerc     []int          `json:"d_sleeping_perc"`
+	DKilledPerc       []int          `json:"d_killed_perc"`
+	Burns             int            `json:"burns"`
+	Digs              int            `json:"digs"`
+	Rest              int            `json:"rest"`
+	DRests            []int          `json:"d_rests"`
+	Turns             int            `json:"turns"`
+	TWounded          int            `json:"t_wounded"`
+	TMWounded         int            `json:"t_m_wounded"`
+	TMonsLOS          int            `json:"t_mons_los"`
+	NSpotted          int            `json:"n_spotted"`
+	NUSpotted         int            `json:"n_u_spotted"`
+	DSpotted          []int          `json:"d_spotted"`
+	DUSpotted         []int          `json:"d_u_spotted"`
+	DUSpottedPerc     []int          `json:"d_u_spotted_perc"`
+	Achievements      map[string]int `json:"achievements"` // turn earned
+	HarmonicMagUse    int            `json:"harmonic_mag_use"`
+	OricMagUse        int            `json:"oric_mag_use"`
+	FireUse           int            `json:"fire_use"`
+	DestructionUse    int            `json:"destruction_use"`
+	OricTelUse        int            `json:"oric_tel_use"`
+	ClimbedTree       int            `json:"climbed_tree"`
+	TableHides        int            `json:"table_hides"`
+	HoledWallsCrawled int            `json:"holed_walls_crawled"`
+	DoorsOpened       int            `json:"doors_opened"`
+	BarrelHides       int            `json:"barrel_hides"`
+	Extinguishments   int            `json:"extinguishments"`
+	Lore              []int          `json:"lore"`
+	Statuses          map[string]int `json:"statuses"`
+	StolenBananas     int            `json:"stolen_bananas"`
+	TimesPushed       int            `json:"times_pushed"`
+	TimesBlinked      int            `json:"times_blinked"`
+	TimesBlocked      int            `json:"times_blocked"`
+}
+
+// DumpJSON returns the JSON character dump, as described in dumpExport.
+func (g *game) DumpJSON() ([]byte, error) {
+	de := &dumpExport{
+		Version:        Version,
+		Seed:           g.Seed,
+		Wizard:         g.Wizard,
+		Preset:         g.Preset,
+		Daily:          g.Daily,
+		Outcome:        "exploring",
+		Depth:          g.Depth,
+		MaxDepth:       max(g.Depth, g.ExploredLevels),
+		Turn:           g.Turn,
+		Shaedra:        g.LiberatedShaedra,
+		Artifact:       g.LiberatedArtifact,
+		Player:         g.exportPlayer(),
+		KilledMonsters: map[string]int{},
+		Bestiary:       g.Bestiary(),
+		Story:          append([]string{}, g.Stats.Story...),
+		Stats:          g.exportStats(),
+		Dungeon:        g.exportMap(),
+	}
+	if g.Player.HP <= 0 {
+		de.Outcome = "death"
+	} else if g.Depth == -1 {
+		de.Outcome = "escape"
+	}
+	for mk, n := range g.Stats.KilledMons {
+		if n > 0 {
+			de.KilledMonsters[mk.String()] = n
+		}
+	}
+	return json.MarshalIndent(de, "", "  ")
+}
+
+// exportDepths returns per-depth statistics, starting from depth 1.
+func exportDepths(a [MaxDepth + 1]int) []int {
+	return append([]int{}, a[1:]...)
+}
+
+func (g *game) exportStats() statsExport {
+	st := &g.Stats
+	se := statsExport{
+		Killed:            st.Killed,
+		Moves:             st.Moves,
+		Waits:             st.Waits,
+		Jumps:             st.Jumps,
+		WallJumps:         st.WallJumps,
+		ReceivedHits:      st.ReceivedHits,
+		Dodges:            st.Dodges,
+		MagarasUsed:       st.MagarasUsed,
+		DMagaraUses:       exportDepths(st.DMagaraUses),
+		UsedStones:        st.UsedStones,
+		UsedMagaras:       map[string]int{},
+		Damage:            st.Damage,
+		DDamage:           exportDepths(st.DDamage),
+		DExplPerc:         exportDepths(st.DExplPerc),
+		DSleepingPerc:     exportDepths(st.DSleepingPerc),
+		DKilledPerc:       exportDepths(st.DKilledPerc),
+		Burns:             st.Burns,
+		Digs:              st.Digs,
+		Rest:              st.Rest,
+		DRests:            exportDepths(st.DRests),
+		Turns:             st.Turns,
+		TWounded:          st.TWounded,
+		TMWounded:         st.TMWounded,
+		TMonsLOS:          st.TMonsLOS,
+		NSpotted:          st.NSpotted,
+		NUSpotted:         st.NUSpotted,
+		DSpotted:          exportDepths(st.DSpotted),
+		DUSpotted:         exportDepths(st.DUSpotted),
+		DUSpottedPerc:     exportDepths(st.DUSpottedPerc),
+		Achievements:      map[string]int{},
+		HarmonicMagUse:    st.HarmonicMagUse,
+		OricMagUse:        st.OricMagUse,
+		FireUse:           st.FireUse,
+		DestructionUse:    st.DestructionUse,
+		OricTelUse:        st.OricTelUse,
+		ClimbedTree:       st.ClimbedTree,
+		TableHides:        st.TableHides,
+		HoledWallsCrawled: st.HoledWallsCrawled,
+		DoorsOpened:       st.DoorsOpened,
+		BarrelHides:       st.BarrelHides,
+		Extinguishments:   st.Extinguishments,
+		Lore:              []int{},
+		Statuses:          map[string]int{},
+		StolenBananas:     st.StolenBananas,
+		TimesPushed:       st.TimesPushed,
+		TimesBlinked:      st.TimesBlinked,
+		TimesBlocked:      st.TimesBlocked,
+	}
+	for mk, n := range st.UsedMagaras {
+		if n > 0 {
+			se.UsedMagaras[magara{Kind: mk}.String()] = n
+		}
+	}
+	for ach, turn := range st.Achievements {
+		se.Achievements[string(ach)] = turn
+	}
+	for d, read := range st.Lore {
+		if read {
+			se.Lore = append(se.Lore, d)
+		}
+	}
+	sort.Ints(se.Lore)
+	for sts, n := range st.Statuses {
+		if n > 0 {
+			se.Statuses[sts.String()] = n
+		}
+	}
+	return se
+}
@@ -52,10 +52,14 @@ type game struct {
 	Autoexploring         bool
 	AutoexploreMapRebuild bool
 	AutoTarget            gruid.Point
+	AutoSafe              bool // travel to AutoTarget by a safe path
 	AutoDir               gruid.Point
 	autoDirNeighbors      dirNeighbors
 	autoDirChanged        bool
 	AutoHalt              bool
+	AutoStealth           bool // stealthy autoexplore
+	autoNoiseWait         int  // turns left waiting behind a door
+	autoNoiseWaited       int  // turns waited behind doors
 	Log                   []logEntry
 	LogIndex              int
 	LogNextTick           int
@@ -64,6 +68,12 @@ type game struct {
 	Wizard                bool
 	WizardMode            wizardMode
 	Version               string
+	Seed                  int64       // seed of the game's random source
+	Custom                bool        // game uses hand-made levels or custom data, not recorded by replays
+	Preset                string      // name of the game preset, if any
+	Daily                 string      // date of the daily challenge, if any
+	Actions               []simAction // player actions since the start, for replays
+	TurnIndex             []turnMark  // first action of each turn, for replays
 	Places                places
 	Params                startParams
 	//Opts                startOpts
@@ -77,6 +87,10 @@ type game struct {
 	autosources       []gruid.Point // cache
 	nbs               paths.Neighbors
 	rand              *rand.Rand
+	storyPending      bool        // headless story sequence waiting for the end of the turn
+	level             *level      // hand-made starting level, if any
+	preset            *gamePreset // game preset of a new game, if any
+	genInfo           genInfo     // generation information of the current level
 }
 
 type specialEvent int
@@ -103,6 +117,7 @@ type startParams struct {
 	NoMagara     map[int]bool
 	FakeStair    map[int]bool
 	ExtraBanana  map[int]int
+	ExtraBands   map[int]int
 	HealthPotion map[int]bool
 	MappingStone map[int]bool
 	CrazyImp     int
@@ -124,8 +139,8 @@ func (g *game) FreePassableCell() gruid.Point {
 		if count > maxIterations {
 			panic("FreePassableCell")
 		}
-		x := RandInt(DungeonWidth)
-		y := RandInt(DungeonHeight)
+		x := g.randInt(DungeonWidth)
+		y := g.randInt(DungeonHeight)
 		p := gruid.Point{x, y}
 		c := d.Cell(p)
 		if !c.IsPassable() {
@@ -156,27 +171,27 @@ func (g *game) GenDungeon() {
 	switch g.Depth {
 	case 2, 6, 7:
 		ml = RandomWalkCave
-		if RandInt(3) == 0 {
+		if g.randInt(3) == 0 {
 			ml = NaturalCave
 		}
 	case 4, 10, 11:
 		ml = RandomWalkTreeCave
-		if RandInt(4) == 0 && g.Depth < 11 {
+		if g.randInt(4) == 0 && g.Depth < 11 {
 			ml = RandomSmallWalkCaveUrbanised
-		} else if g.Depth == 11 && RandInt(2) == 0 {
+		} else if g.Depth == 11 && g.randInt(2) == 0 {
 			ml = RandomSmallWalkCaveUrbanised
 		}
 	case 9:
-		switch RandInt(4) {
+		switch g.randInt(4) {
 		case 0:
 			ml = NaturalCave
 		case 1:
 			ml = RandomWalkCave
 		}
 	default:
-		if RandInt(10) == 0 {
+		if g.randInt(10) == 0 {
 			ml = RandomSmallWalkCaveUrbanised
-		} else if RandInt(10) == 0 {
+		} else if g.randInt(10) == 0 {
 			ml = NaturalCave
 		}
 	}
@@ -218,9 +233,9 @@ const (
 	GenCloak
 )
 
-func PutRandomLevels(m map[int]bool, n int) {
+func (g *game) PutRandomLevels(m map[int]bool, n int) {
 	for i := 0; i < n; i++ {
-		j := 1 + RandInt(MaxDepth)
+		j := 1 + g.randInt(MaxDepth)
 		if !m[j] {
 			m[j] = true
 		} else {
@@ -237,6 +252,9 @@ func (g *game) InitFirstLevel() {
 	g.RaysCache = rayMap{}
 	g.GeneratedLore = map[int]bool{}
 	g.Stats.KilledMons = map[monsterKind]int{}
+	g.Stats.SeenMons = map[monsterKind]int{}
+	g.Stats.SpottingMons = map[monsterKind]int{}
+	g.Stats.MonsDepths = map[monsterKind]map[int]bool{}
 	g.Stats.UsedMagaras = map[magaraKind]int{}
 	g.Stats.Achievements = map[achievement]int{}
 	g.Stats.Lore = map[int]bool{}
@@ -255,18 +273,18 @@ func (g *game) InitFirstLevel() {
 		11: GenNothing,
 	}
 	g.Params.Lore = map[int]bool{}
-	PutRandomLevels(g.Params.Lore, 8)
+	g.PutRandomLevels(g.Params.Lore, 8)
 	g.Params.HealthPotion = map[int]bool{}
-	PutRandomLevels(g.Params.HealthPotion, 5)
+	g.PutRandomLevels(g.Params.HealthPotion, 5)
 	g.Params.MappingStone = map[int]bool{}
-	PutRandomLevels(g.Params.MappingStone, 3)
+	g.PutRandomLevels(g.Params.MappingStone, 3)
 	g.Params.Blocked = map[int]bool{}
-	if RandInt(10) > 0 {
-		g.Params.Blocked[2+RandInt(WinDepth-2)] = true
+	if g.randInt(10) > 0 {
+		g.Params.Blocked[2+g.randInt(WinDepth-2)] = true
 	}
-	if RandInt(10) == 0 {
+	if g.randInt(10) == 0 {
 		// a second one sometimes!
-		g.Params.Blocked[2+RandInt(WinDepth-2)] = true
+		g.Params.Blocked[2+g.randInt(WinDepth-2)] = true
 	}
 	g.Params.Special = []specialRoom{
 		noSpecialRoom, // unused (depth 0)
@@ -282,104 +300,105 @@ func (g *game) InitFirstLevel() {
 		roomMirrorSpecters,
 		roomArtifact,
 	}
-	if RandInt(2) == 0 {
+	if g.randInt(2) == 0 {
 		g.Params.Special[5] = roomNixes
 	}
-	if RandInt(4) == 0 {
+	if g.randInt(4) == 0 {
 		if g.Params.Special[5] == roomNixes {
 			g.Params.Special[9] = roomVampires
 		} else {
 			g.Params.Special[9] = roomNixes
 		}
 	}
-	if RandInt(4) == 0 {
-		if RandInt(2) == 0 {
+	if g.randInt(4) == 0 {
+		if g.randInt(2) == 0 {
 			g.Params.Special[3] = roomFrogs
 		} else {
 			g.Params.Special[7] = roomFrogs
 		}
 	}
-	if RandInt(4) == 0 {
+	if g.randInt(4) == 0 {
 		g.Params.Special[10], g.Params.Special[5] = g.Params.Special[5], g.Params.Special[10]
 	}
-	if RandInt(4) == 0 {
+	if g.randInt(4) == 0 {
 		g.Params.Special[6], g.Params.Special[7] = g.Params.Special[7], g.Params.Special[6]
 	}
-	if RandInt(4) == 0 {
+	if g.randInt(4) == 0 {
 		g.Params.Special[3], g.Params.Special[4] = g.Params.Special[4], g.Params.Special[3]
 	}
 	g.Params.Event = map[int]specialEvent{}
 	for i := 0; i < 2; i++ {
-		g.Params.Event[2+5*i+RandInt(5)] = specialEvent(1 + RandInt(spEvMax))
+		g.Params.Event[2+5*i+g.randInt(5)] = specialEvent(1 + g.randInt(spEvMax))
 	}
-	g.Params.Event[2+RandInt(MaxDepth-1)] = NormalLevel
+	g.Params.Event[2+g.randInt(MaxDepth-1)] = NormalLevel
 	g.Params.FakeStair = map[int]bool{}
-	if RandInt(MaxDepth) > 0 {
-		g.Params.FakeStair[2+RandInt(MaxDepth-2)] = true
-		if RandInt(MaxDepth) > MaxDepth/2 {
-			g.Params.FakeStair[2+RandInt(MaxDepth-2)] = true
-			if RandInt(MaxDepth) == 0 {
-				g.Params.FakeStair[2+RandInt(MaxDepth-2)] = true
+	if g.randInt(MaxDepth) > 0 {
+		g.Params.FakeStair[2+g.randInt(MaxDepth-2)] = true
+		if g.randInt(MaxDepth) > MaxDepth/2 {
+			g.Params.FakeStair[2+g.randInt(MaxDepth-2)] = true
+			if g.randInt(MaxDepth) == 0 {
+				g.Params.FakeStair[2+g.randInt(MaxDepth-2)] = true
 			}
 		}
 	}
+	g.Params.ExtraBands = map[int]int{}
 	g.Params.ExtraBanana = map[int]int{}
 	for i := 0; i < 2; i++ {
-		g.Params.ExtraBanana[1+5*i+RandInt(5)]++
+		g.Params.ExtraBanana[1+5*i+g.randInt(5)]++
 	}
 	for i := 0; i < 2; i++ {
-		g.Params.ExtraBanana[1+5*i+RandInt(5)]--
+		g.Params.ExtraBanana[1+5*i+g.randInt(5)]--
 	}
 
 	g.Params.Windows = map[int]bool{}
-	if RandInt(MaxDepth) > MaxDepth/2 {
-		g.Params.Windows[2+RandInt(MaxDepth-1)] = true
-		if RandInt(MaxDepth) == 0 {
-			g.Params.Windows[2+RandInt(MaxDepth-1)] = true
+	if g.randInt(MaxDepth) > MaxDepth/2 {
+		g.Params.Windows[2+g.randInt(MaxDepth-1)] = true
+		if g.randInt(MaxDepth) == 0 {
+			g.Params.Windows[2+g.randInt(MaxDepth-1)] = true
 		}
 	}
 	g.Params.Holes = map[int]bool{}
-	if RandInt(MaxDepth) > MaxDepth/2 {
-		g.Params.Holes[2+RandInt(MaxDepth-1)] = true
-		if RandInt(MaxDepth) == 0 {
-			g.Params.Holes[2+RandInt(MaxDepth-1)] = true
+	if g.randInt(MaxDepth) > MaxDepth/2 {
+		g.Params.Holes[2+g.randInt(MaxDepth-1)] = true
+		if g.randInt(MaxDepth) == 0 {
+			g.Params.Holes[2+g.randInt(MaxDepth-1)] = true
 		}
 	}
 	g.Params.Trees = map[int]bool{}
-	if RandInt(MaxDepth) > MaxDepth/2 {
-		g.Params.Trees[2+RandInt(MaxDepth-1)] = true
-		if RandInt(MaxDepth) == 0 {
-			g.Params.Trees[2+RandInt(MaxDepth-1)] = true
+	if g.randInt(MaxDepth) > MaxDepth/2 {
+		g.Params.Trees[2+g.randInt(MaxDepth-1)] = true
+		if g.randInt(MaxDepth) == 0 {
+			g.Params.Trees[2+g.randInt(MaxDepth-1)] = true
 		}
 	}
 	g.Params.Tables = map[int]bool{}
-	if RandInt(MaxDepth) > MaxDepth/2 {
-		g.Params.Tables[2+RandInt(MaxDepth-1)] = true
-		if RandInt(MaxDepth) == 0 {
-			g.Params.Tables[2+RandInt(MaxDepth-1)] = true
+	if g.randInt(MaxDepth) > MaxDepth/2 {
+		g.Params.Tables[2+g.randInt(MaxDepth-1)] = true
+		if g.randInt(MaxDepth) == 0 {
+			g.Params.Tables[2+g.randInt(MaxDepth-1)] = true
 		}
 	}
 	g.Params.NoMagara = map[int]bool{}
 	g.Params.NoMagara[WinDepth] = true
 	g.Params.Stones = map[int]bool{}
-	if RandInt(MaxDepth) > MaxDepth/2 {
-		g.Params.Stones[2+RandInt(MaxDepth-1)] = true
-		if RandInt(MaxDepth) == 0 {
-			g.Params.Stones[2+RandInt(MaxDepth-1)] = true
+	if g.randInt(MaxDepth) > MaxDepth/2 {
+		g.Params.Stones[2+g.randInt(MaxDepth-1)] = true
+		if g.randInt(MaxDepth) == 0 {
+			g.Params.Stones[2+g.randInt(MaxDepth-1)] = true
 		}
 	}
-	permi := RandInt(WinDepth - 1)
+	permi := g.randInt(WinDepth - 1)
 	switch permi {
 	case 0, 1, 2, 3:
 		g.GenPlan[permi+1], g.GenPlan[permi+2] = g.GenPlan[permi+2], g.GenPlan[permi+1]
 	}
-	if RandInt(4) == 0 {
+	if g.randInt(4) == 0 {
 		g.GenPlan[6], g.GenPlan[7] = g.GenPlan[7], g.GenPlan[6]
 	}
-	if RandInt(4) == 0 {
+	if g.randInt(4) == 0 {
 		g.GenPlan[MaxDepth-1], g.GenPlan[MaxDepth] = g.GenPlan[MaxDepth], g.GenPlan[MaxDepth-1]
 	}
-	g.Params.CrazyImp = 2 + RandInt(MaxDepth-2)
+	g.Params.CrazyImp = 2 + g.randInt(MaxDepth-2)
 	g.PR = paths.NewPathRange(gruid.NewRange(0, 0, DungeonWidth, DungeonHeight))
 	g.PRauto = paths.NewPathRange(gruid.NewRange(0, 0, DungeonWidth, DungeonHeight))
 }
@@ -411,20 +430,35 @@ var Testing = false
 
 func (g *game) InitLevel() {
 	if g.rand == nil {
-		g.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+		g.initRand()
 	}
 	// Starting data
 	if g.Depth == 0 {
 		g.InitFirstLevel()
+		if g.preset != nil {
+			g.ApplyPreset(g.preset)
+		}
+		if g.level != nil {
+			g.Depth = g.level.Depth
+		}
+		g.Custom = g.level != nil || CustomRoomTemplates || CustomMonsters || g.preset != nil && g.preset.Custom
+		g.preset = nil
 	}
 
 	g.InitLevelStructures()
 
 	// Dungeon terrain
-	g.GenDungeon()
+	if g.level != nil {
+		g.GenLevel(g.level)
+		g.level = nil
+		// no special events on hand-made levels
+		delete(g.Params.Event, g.Depth)
+	} else {
+		g.GenDungeon()
+	}
 
 	// Events
-	if g.Depth == 1 {
+	if g.Events == nil {
 		g.StoryPrintf("Started with %s", g.Player.Magaras[0])
 		g.Events = rl.NewEventQueue()
 		//g.PushEvent(&simpleEvent{ERank: 0, EAction: PlayerTurn})
@@ -438,7 +472,7 @@ func (g *game) InitLevel() {
 	}
 	monsters := make([]*monster, len(g.Monsters))
 	copy(monsters, g.Monsters)
-	rand.Shuffle(len(monsters), func(i, j int) {
+	g.rand.Shuffle(len(monsters), func(i, j int) {
 		monsters[i], monsters[j] = monsters[j], monsters[i]
 	})
 	for _, m := range monsters {
@@ -450,18 +484,18 @@ func (g *game) InitLevel() {
 		g.StoryPrint("Special event: magically unstable level")
 		for i := 0; i < 7; i++ {
 			g.PushEvent(&posEvent{Action: ObstructionProgression},
-				g.Turn+DurationObstructionProgression+RandInt(DurationObstructionProgression/2))
+				g.Turn+DurationObstructionProgression+g.randInt(DurationObstructionProgression/2))
 		}
 	case MistLevel:
 		g.PrintStyled("The air seems dense on this level.", logSpecial)
 		g.StoryPrint("Special event: mist level")
 		for i := 0; i < 20; i++ {
 			g.PushEvent(&posEvent{Action: MistProgression},
-				g.Turn+DurationMistProgression+RandInt(DurationMistProgression/2))
+				g.Turn+DurationMistProgression+g.randInt(DurationMistProgression/2))
 		}
 	case EarthquakeLevel:
-		g.PushEvent(&posEvent{P: gruid.Point{DungeonWidth/2 - 15 + RandInt(30), DungeonHeight/2 - 5 + RandInt(10)}, Action: Earthquake},
-			g.Turn+10+RandInt(50))
+		g.PushEvent(&posEvent{P: gruid.Point{DungeonWidth/2 - 15 + g.randInt(30), DungeonHeight/2 - 5 + g.randInt(10)}, Action: Earthquake},
+			g.Turn+10+g.randInt(50))
 
 	}
 
@@ -477,7 +511,7 @@ func (g *game) InitLevel() {
 	g.ComputeLOS()
 	g.MakeMonstersAware()
 	g.ComputeMonsterLOS()
-	if !Testing { // disable when testing
+	if g.md != nil && !Testing { // disable when testing
 		g.md.updateStatusInfo()
 	}
 }
@@ -598,7 +632,12 @@ func (g *game) Descend(style descendstyle) bool {
 	g.Depth++
 	g.DepthPlayerTurn = 0
 	g.InitLevel()
-	g.Save()
+	if g.md != nil && g.Daily == "" {
+		// daily challenge saves are removed when loaded, and only
+		// written when quitting, so that the challenge cannot be
+		// rewound by killing the game
+		g.Save()
+	}
 	return false
 }
 
@@ -608,6 +647,16 @@ func (g *game) EnterWizardMode() {
 	g.StoryPrint("Entered wizard mode.")
 }
 
+// WizardDescend descends to the next level in wizard mode, rescuing Shaedra
+// first if needed. It reports whether the player escaped.
+func (g *game) WizardDescend() bool {
+	if g.Depth == WinDepth && !g.LiberatedShaedra {
+		g.RescuedShaedra()
+	}
+	g.StoryPrint("Descended wizardly")
+	return g.Descend(DescendNormal)
+}
+
 func (g *game) ApplyRest() {
 	g.Player.HP = g.Player.HPMax()
 	g.Player.HPbonus = 0
@@ -637,6 +686,11 @@ func (g *game) AutoPlayer() bool {
 		switch {
 		case g.AutoHalt:
 			// stop exploring
+		case g.autoNoiseWait > 0:
+			g.autoNoiseWait--
+			g.autoNoiseWaited++
+			g.WaitTurn()
+			return true
 		default:
 			var n *gruid.Point
 			var finished bool
@@ -735,6 +789,41 @@ func (g *game) checks() {
 	}
 }
 
+// initRand initializes the game's random source from g.Seed, choosing a
+// time-based seed first if none was given. Every random decision affecting the
+// game state uses this source, so that two games with the same seed and the
+// same player actions are identical.
+func (g *game) initRand() {
+	if g.Seed == 0 {
+		g.Seed = time.Now().UnixNano()
+	}
+	g.rand = rand.New(rand.NewSource(g.Seed))
+}
+
+// reloadRand initializes the random source of a loaded game. The state of the
+// previous source is lost with saving, so a new one is derived from the seed
+// and current turn, allowing replays to reproduce it.
+func (g *game) reloadRand() {
+	g.rand = rand.New(rand.NewSource(g.Seed + int64(g.Turn)))
+}
+
+// turnMark marks the first action of a turn in the game's replay.
+type turnMark struct {
+	Action int // index of the action in the replay
+	Turn   int
+	Depth  int
+}
+
+// record adds a player action to the game's replay, and updates the turn
+// index if it is the first action of the turn.
+func (g *game) record(a simAction) {
+	n := len(g.TurnIndex)
+	if n == 0 || g.TurnIndex[n-1].Turn != g.Turn || g.TurnIndex[n-1].Depth != g.Depth {
+		g.TurnIndex = append(g.TurnIndex, turnMark{Action: len(g.Actions), Turn: g.Turn, Depth: g.Depth})
+	}
+	g.Actions = append(g.Actions, a)
+}
+
 func (g *game) randInt(n int) int {
 	if n <= 0 {
 		return 0
@@ -1,8 +1,17 @@
 package main
 
-//import "log"
-import "testing"
-import "github.com/anaseto/gruid"
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anaseto/gruid"
+)
 
 func init() {
 	Testing = true
@@ -144,3 +153,1035 @@ func BenchmarkEndTurnPlayer(b *testing.B) {
 		}
 	}
 }
+
+func (g *game) fingerprint() string {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "depth %d turn %d player %v hp %d mp %d\n", g.Depth, g.Turn, g.Player.P, g.Player.HP, g.Player.MP)
+	it := g.Dungeon.Grid.Iterator()
+	for it.Next() {
+		fmt.Fprintf(b, "%d,", it.Cell())
+	}
+	fmt.Fprint(b, "\n")
+	for _, m := range g.Monsters {
+		fmt.Fprintf(b, "%v %v %v %v %v\n", m.Kind, m.P, m.State, m.Dead, m.Target)
+	}
+	for _, e := range g.Log {
+		fmt.Fprintf(b, "%s\n", e.Text)
+	}
+	return b.String()
+}
+
+func TestSeedDeterminism(t *testing.T) {
+	dirs := []gruid.Point{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	play := func(seed int64) string {
+		s := newSim(seed)
+		g := s.Game()
+		b := &bytes.Buffer{}
+		for depth := 1; depth < MaxDepth; depth++ {
+			for j := 0; j < 30; j++ {
+				s.Do(simAction{Kind: SimMove, Dir: dirs[(j/3)%len(dirs)]})
+				if g.Player.HP <= 0 {
+					g.Player.HP = 10
+				}
+			}
+			fmt.Fprint(b, g.fingerprint())
+			g.Depth++
+			g.InitLevel()
+		}
+		return b.String()
+	}
+	for i := 0; i < 5; i++ {
+		seed := int64(1 + i)
+		if s1, s2 := play(seed), play(seed); s1 != s2 {
+			t.Errorf("games with seed %d differ", seed)
+		}
+	}
+}
+
+func TestHeadless(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		s := newSim(int64(100 + i))
+		g := s.Game()
+		if g.md != nil {
+			t.Fatalf("headless game with model")
+		}
+		for j := 0; j < 1000 && !s.Finished(); j++ {
+			var a simAction
+			switch g.randInt(10) {
+			case 0:
+				a = simAction{Kind: SimEvoke, N: g.randInt(len(g.Player.Magaras))}
+			case 1:
+				a = simAction{Kind: SimExplore}
+			case 2:
+				a = simAction{Kind: SimInteract}
+			case 3:
+				a = simAction{Kind: SimJump, Dir: randomPlayerNeighbor(ZP)}
+			default:
+				a = simAction{Kind: SimMove, Dir: randomPlayerNeighbor(ZP)}
+			}
+			turn := g.Turn
+			err := s.Do(a)
+			if err != nil && g.Turn != turn {
+				t.Errorf("time passed after failed action %v: %v", a, err)
+			}
+			obs := s.Observe()
+			if obs.P != g.Player.P && !s.Won() {
+				t.Errorf("bad observed position: %v vs %v", obs.P, g.Player.P)
+			}
+			for _, m := range obs.Monsters {
+				if !g.Player.Sees(m.P) {
+					t.Errorf("observed monster not in view: %v", m)
+				}
+			}
+		}
+	}
+	s := newSim(1)
+	s.Observe()
+	for i := 1; i <= 3; i++ {
+		s.Game().Print("You hear something.")
+		if obs := s.Observe(); len(obs.Messages) != 1 {
+			t.Errorf("repeated message %d: bad observed messages: %v", i, obs.Messages)
+		}
+	}
+}
+
+func TestBot(t *testing.T) {
+	const n = 8
+	depths := 0
+	for i := int64(1); i <= n; i++ {
+		res := PlayBot(newExplorerBot(i), i)
+		if res.Depth < 2 || res.Turns <= 0 {
+			t.Errorf("bad bot result for seed %d: %+v", i, res)
+		}
+		if i == 1 && res != PlayBot(newExplorerBot(i), i) {
+			t.Errorf("bot game with seed %d is not deterministic", i)
+		}
+		depths += res.Depth
+	}
+	if avg := float64(depths) / n; avg < 2.5 {
+		t.Errorf("bot average depth %.2f, expected at least 2.5", avg)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	for i := int64(1); i <= 3; i++ {
+		b := newExplorerBot(i)
+		s := newSim(i)
+		var err error
+		for j := 0; j < 3000 && !s.Finished(); j++ {
+			err = s.Do(b.Action(s, err))
+		}
+		g := s.Game()
+		data := g.Replay(s.Finished()).Encode()
+		r, err := DecodeReplay(data)
+		if err != nil {
+			t.Fatalf("decoding replay for seed %d: %v", i, err)
+		}
+		if !bytes.Equal(r.Encode(), data) {
+			t.Errorf("replay for seed %d changed after decoding", i)
+		}
+		rs, err := r.Simulate(-1, nil)
+		if err != nil {
+			t.Fatalf("simulating replay for seed %d: %v", i, err)
+		}
+		if rs.Game().fingerprint() != g.fingerprint() {
+			t.Errorf("replay for seed %d does not reproduce the game", i)
+		}
+		if s.Finished() {
+			if err := r.Verify(); err != nil {
+				t.Errorf("verifying replay for seed %d: %v", i, err)
+			}
+		}
+	}
+}
+
+func TestReplayViewer(t *testing.T) {
+	b := newExplorerBot(1)
+	s := newSim(1)
+	var err error
+	for j := 0; j < 500 && !s.Finished(); j++ {
+		err = s.Do(b.Action(s, err))
+	}
+	g := s.Game()
+	v, err := newReplayViewer(g.Replay(false).Encode())
+	if err != nil {
+		t.Fatalf("replay viewer: %v", err)
+	}
+	if len(v.times) != len(g.Actions)+1 {
+		t.Fatalf("bad number of frames: %d for %d actions", len(v.times), len(g.Actions))
+	}
+	v.setFrame(1)
+	turn := g.TurnIndex[len(g.TurnIndex)/2].Turn
+	v.goToTurn(turn)
+	if m := v.index[v.mark()]; m.Turn != turn {
+		t.Errorf("bad turn after seeking: %d instead of %d", m.Turn, turn)
+	}
+	v.nextTurn()
+	v.previousTurn()
+	if m := v.index[v.mark()]; m.Turn != turn {
+		t.Errorf("bad turn after stepping: %d instead of %d", m.Turn, turn)
+	}
+	if g.Depth > 1 {
+		v.goToDepth(g.Depth)
+		if m := v.index[v.mark()]; m.Depth != g.Depth {
+			t.Errorf("bad depth after seeking: %d instead of %d", m.Depth, g.Depth)
+		}
+		v.previousDepth()
+		if m := v.index[v.mark()]; m.Depth != g.Depth-1 {
+			t.Errorf("bad depth after previous depth: %d", m.Depth)
+		}
+	}
+}
+
+func TestGameSave(t *testing.T) {
+	s := newSim(1)
+	g := s.Game()
+	for j := 0; j < 50; j++ {
+		s.Do(simAction{Kind: SimExplore})
+	}
+	data, err := g.GameSave()
+	if err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+	lg, err := g.DecodeGameSave(data)
+	if err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+	if lg.fingerprint() != g.fingerprint() {
+		t.Errorf("loaded game differs from saved one")
+	}
+	env := saveEnvelope{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&env); err != nil {
+		t.Fatalf("decoding envelope: %v", err)
+	}
+	if env.Format != saveFormat || env.Version != Version {
+		t.Errorf("bad envelope: format %d version %s", env.Format, env.Version)
+	}
+	encode := func(env saveEnvelope) []byte {
+		buf := &bytes.Buffer{}
+		if err := gob.NewEncoder(buf).Encode(&env); err != nil {
+			t.Fatalf("encoding envelope: %v", err)
+		}
+		return buf.Bytes()
+	}
+	// save without envelope
+	if _, err := g.DecodeGameSave(env.Data); err != nil {
+		t.Errorf("loading format 0 save: %v", err)
+	}
+	future := env
+	future.Format = saveFormat + 1
+	if _, err := g.DecodeGameSave(encode(future)); err == nil {
+		t.Errorf("save from future format loaded")
+	} else if _, ok := err.(saveVersionError); !ok {
+		t.Errorf("bad error for future format: %v", err)
+	}
+	corrupted := env
+	corrupted.Data = append([]byte{}, env.Data...)
+	corrupted.Data[len(corrupted.Data)/2]++
+	if _, err := g.DecodeGameSave(encode(corrupted)); err != errSaveChecksum {
+		t.Errorf("bad error for corrupted save: %v", err)
+	}
+	if _, err := g.DecodeGameSave(data[:len(data)/2]); err != errSaveCorrupted {
+		t.Errorf("bad error for truncated save: %v", err)
+	}
+}
+
+func TestExportState(t *testing.T) {
+	s := newSim(1)
+	g := s.Game()
+	for j := 0; j < 20; j++ {
+		s.Do(simAction{Kind: SimExplore})
+	}
+	data, err := g.ExportState()
+	if err != nil {
+		t.Fatalf("exporting state: %v", err)
+	}
+	st := &stateExport{}
+	if err := json.Unmarshal(data, st); err != nil {
+		t.Fatalf("decoding exported state: %v", err)
+	}
+	if st.Map.Width != DungeonWidth || st.Map.Height != DungeonHeight || len(st.Map.Terrain) != DungeonHeight {
+		t.Errorf("bad map size: %dx%d", st.Map.Width, st.Map.Height)
+	}
+	if p := st.Player.Pos; p.X != g.Player.P.X || p.Y != g.Player.P.Y {
+		t.Errorf("bad player position: %v", p)
+	}
+	if len(st.Monsters) != len(g.Monsters) {
+		t.Errorf("bad number of monsters: %d", len(st.Monsters))
+	}
+	if st.Map.Terrain[g.Player.P.Y][g.Player.P.X] == "" {
+		t.Errorf("unnamed terrain at player position")
+	}
+	if len(st.Objects.Stairs) != len(g.Objects.Stairs) {
+		t.Errorf("bad number of stairs: %d", len(st.Objects.Stairs))
+	}
+}
+
+func TestDumpJSON(t *testing.T) {
+	s := newSim(1)
+	g := s.Game()
+	for j := 0; j < 20; j++ {
+		s.Do(simAction{Kind: SimExplore})
+	}
+	g.Stats.KilledMons[MonsGuard] = 2
+	g.Stats.DExplPerc[1] = 42
+	g.Stats.Lore[1] = true
+	data, err := g.DumpJSON()
+	if err != nil {
+		t.Fatalf("exporting dump: %v", err)
+	}
+	de := &dumpExport{}
+	if err := json.Unmarshal(data, de); err != nil {
+		t.Fatalf("decoding JSON dump: %v", err)
+	}
+	if de.Seed != g.Seed || de.Turn != g.Turn || de.Outcome != "exploring" || de.Depth != g.Depth {
+		t.Errorf("bad dump header: %+v", de)
+	}
+	if len(de.Stats.DExplPerc) != MaxDepth || de.Stats.DExplPerc[0] != 42 || de.Stats.Moves != g.Stats.Moves {
+		t.Errorf("bad dump statistics: %+v", de.Stats)
+	}
+	if de.KilledMonsters[MonsGuard.String()] != 2 || len(de.Stats.Lore) != 1 || de.Stats.Lore[0] != 1 {
+		t.Errorf("bad killed monsters or lore: %v %v", de.KilledMonsters, de.Stats.Lore)
+	}
+	if len(de.Story) != len(g.Stats.Story) || len(de.Player.Magaras) != len(g.Player.Magaras) {
+		t.Errorf("bad story or magaras")
+	}
+	if len(de.Dungeon.Terrain) != DungeonHeight {
+		t.Errorf("bad dungeon height: %d", len(de.Dungeon.Terrain))
+	}
+}
+
+func TestLevel(t *testing.T) {
+	data := []byte(`depth 3
+legend X monster tree mushroom
+legend / magara magara of blinking
+---
+##########
+#@..g...>#
+#.&..X.+.#
+#/..l..."#
+##########
+`)
+	lv, err := parseLevel(data)
+	if err != nil {
+		t.Fatalf("parsing level: %v", err)
+	}
+	s := newLevelSim(1, lv)
+	g := s.Game()
+	if g.Depth != 3 {
+		t.Errorf("bad depth: %d", g.Depth)
+	}
+	if g.Player.P != (gruid.Point{1, 1}) {
+		t.Errorf("bad player position: %v", g.Player.P)
+	}
+	if len(g.Monsters) != 2 || g.Monsters[0].Kind != MonsGuard || g.Monsters[1].Kind != MonsTreeMushroom {
+		t.Fatalf("bad monsters: %v", g.Monsters)
+	}
+	if m := g.MonsterAt(gruid.Point{5, 2}); !m.Exists() || m.Kind != MonsTreeMushroom {
+		t.Errorf("no tree mushroom at (5,2)")
+	}
+	if g.Objects.Stairs[gruid.Point{8, 1}] != NormalStair || !g.Objects.Barrels[gruid.Point{2, 2}] {
+		t.Errorf("missing stairs or barrel")
+	}
+	if g.Objects.Magaras[gruid.Point{1, 3}].Kind != BlinkMagara {
+		t.Errorf("missing blink magara")
+	}
+	if c := terrain(g.Dungeon.Cell(gruid.Point{7, 2})); c != DoorCell {
+		t.Errorf("bad door terrain: %v", c)
+	}
+	if c := terrain(g.Dungeon.Cell(gruid.Point{20, 10})); c != WallCell {
+		t.Errorf("bad padding terrain: %v", c)
+	}
+	s.Do(simAction{Kind: SimMove, Dir: gruid.Point{1, 0}})
+	if _, err := parseLevel([]byte("#.#\n")); err == nil {
+		t.Errorf("level without player parsed")
+	}
+	if _, err := parseLevel([]byte("#@?X#\n")); err == nil {
+		t.Errorf("level with unknown rune parsed")
+	}
+	if e, err := parseLevelEntity("magara", []string{"Magara", "of", "Blinking"}); err != nil || e.Magara != BlinkMagara {
+		t.Errorf("magara name not matched ignoring case: %v", err)
+	}
+}
+
+func TestSafeTravel(t *testing.T) {
+	data := []byte(`---
+##########
+#@.......#
+#.######.#
+#."""""".#
+##########
+`)
+	lv, err := parseLevel(data)
+	if err != nil {
+		t.Fatalf("parsing level: %v", err)
+	}
+	s := newLevelSim(1, lv)
+	g := s.Game()
+	it := g.Dungeon.Grid.Iterator()
+	for it.Next() {
+		g.Dungeon.SetExplored(it.P())
+	}
+	for x := 2; x <= 8; x++ {
+		g.MonsterLOS[gruid.Point{x, 1}] = true
+	}
+	to := gruid.Point{8, 1}
+	if path := g.PlayerPath(g.Player.P, to); len(path) != 8 {
+		t.Errorf("bad shortest path length: %d", len(path))
+	}
+	path := g.SafePlayerPath(g.Player.P, to)
+	if len(path) != 12 {
+		t.Fatalf("bad safe path length: %d", len(path))
+	}
+	for _, p := range path[:len(path)-1] {
+		if g.MonsterLOS[p] {
+			t.Errorf("safe path through monster view at %v", p)
+		}
+	}
+	if err := s.Do(simAction{Kind: SimSafeTravel, Target: to}); err != nil {
+		t.Fatalf("safe travel: %v", err)
+	}
+	if !g.AutoSafe || g.Player.P != to {
+		t.Errorf("safe travel did not reach destination: %v", g.Player.P)
+	}
+	a, err := parseSimAction(strings.Fields(simAction{Kind: SimSafeTravel, Target: to}.String()))
+	if err != nil || a.Kind != SimSafeTravel || a.Target != to {
+		t.Errorf("bad parsed action: %v (%v)", a, err)
+	}
+}
+
+func TestStealthAutoexplore(t *testing.T) {
+	lv, err := parseLevel([]byte("---\n#######\n#@+.\".#\n#######\n"))
+	if err != nil {
+		t.Fatalf("parsing level: %v", err)
+	}
+	s := newLevelSim(1, lv)
+	g := s.Game()
+	if g.stealthCost(gruid.Point{4, 1}) >= g.stealthCost(gruid.Point{3, 1}) {
+		t.Errorf("foliage not preferred")
+	}
+	g.MonsterLOS[gruid.Point{3, 1}] = true
+	if g.stealthCost(gruid.Point{3, 1}) < safeCostMonsterLOS {
+		t.Errorf("cell in monster view not avoided")
+	}
+	g.Autoexploring = true
+	g.AutoStealth = true
+	g.BuildAutoexploreMap(g.AutoexploreSources())
+	if !g.autoWaitBehindDoor() || g.autoNoiseWait != stealthNoiseWait {
+		t.Fatalf("no wait behind door")
+	}
+	p := g.Player.P
+	if !g.AutoPlayer() || g.Player.P != p || g.autoNoiseWait != stealthNoiseWait-1 {
+		t.Errorf("bad wait behind door: %v", g.Player.P)
+	}
+	g.autoNoiseWait = 0
+	g.autoNoiseWaited = stealthMaxNoiseWait
+	if g.autoWaitBehindDoor() {
+		t.Errorf("waited too long behind door")
+	}
+	g.Autoexploring = false
+	if err := s.Do(simAction{Kind: SimStealthExplore}); err != nil {
+		t.Fatalf("stealthy autoexplore: %v", err)
+	}
+	if !g.AllExplored() {
+		t.Errorf("level not explored")
+	}
+	lv, err = parseLevel([]byte("---\n###########\n#@..+...g.#\n###########\n"))
+	if err != nil {
+		t.Fatalf("parsing level: %v", err)
+	}
+	g = newLevelSim(1, lv).Game()
+	m := g.Monsters[0]
+	q := gruid.Point{3, 1}
+	cost := g.stealthCost(q)
+	m.Seen = true
+	m.Path = []gruid.Point{q}
+	m.Dir = gruid.Point{-1, 0}
+	g.LastMonsterKnownAt[gruid.Point{8, 1}] = m.Index
+	m.LastKnownPos = gruid.Point{8, 1}
+	if g.stealthCost(q) != cost {
+		t.Errorf("hidden facing or path of monster out of view used")
+	}
+}
+
+func TestMonsterData(t *testing.T) {
+	if !MonsGuard.Patrolling() || !MonsTinyHarpy.CanFly() || !MonsBlinkingFrog.ReflectsTeleport() {
+		t.Errorf("bad built-in flags")
+	}
+	if !MonsHighGuard.Ranged() || MonsHighGuard.Smiting() || !MonsMirrorSpecter.Smiting() {
+		t.Errorf("bad built-in abilities")
+	}
+	for mk := range MonsData {
+		if b := monsterKind(mk).loneBand(); MonsBands[b].Monster != monsterKind(mk) {
+			t.Errorf("bad lone band for %v", monsterKind(mk))
+		}
+	}
+	defs, err := parseMonsterData([]byte(`# tuning
+monster guard
+dangerousness 4
+flags doors
+
+monster ogre
+letter O
+size large
+dangerousness 7
+flags doors patrol
+ability javelins
+group bipeds
+desc Ogres are big clunky humanoids
+desc that can hit really hard.
+`), MonsData)
+	if err != nil {
+		t.Fatalf("parsing monster data: %v", err)
+	}
+	if len(defs) != len(MonsData)+1 {
+		t.Fatalf("bad number of definitions: %d", len(defs))
+	}
+	guard := defs[MonsGuard]
+	if guard.Dangerousness != 4 || guard.Flags != MonsFlagOpensDoors || guard.Letter != 'g' {
+		t.Errorf("bad guard changes: %+v", guard)
+	}
+	ogre := defs[len(defs)-1]
+	if ogre.Letter != 'O' || ogre.Size != MonsLarge || ogre.Ability != AbilityJavelins || ogre.Group != GroupBipeds ||
+		ogre.Flags != MonsFlagOpensDoors|MonsFlagPatrols || ogre.Desc != "Ogres are big clunky humanoids that can hit really hard." {
+		t.Errorf("bad ogre: %+v", ogre)
+	}
+	if MonsData[MonsGuard].Dangerousness != 3 {
+		t.Errorf("built-in definitions modified")
+	}
+	data, bands := MonsData, MonsBands
+	MonsData = defs
+	addLoneBands()
+	if n := len(MonsBands) - len(bands); n != 1 {
+		t.Errorf("bad number of added lone bands: %d", n)
+	}
+	if b := monsterKind(len(defs) - 1).loneBand(); MonsBands[b].Monster != monsterKind(len(defs)-1) || len(groupBands(GroupBipeds)) == 0 {
+		t.Errorf("bad ogre lone band")
+	}
+	MonsData, MonsBands = data, bands
+	bad := []string{
+		"letter x\n",                            // outside of block
+		"monster lich\nletter L\n",              // no description
+		"monster lich\nletter g\ndesc Lich.\n",  // letter already used
+		"monster guard\nability fireball\n",     // unknown ability
+		"monster guard\nflags doors teleport\n", // unknown flag
+	}
+	for _, s := range bad {
+		if _, err := parseMonsterData([]byte(s), MonsData); err == nil {
+			t.Errorf("invalid definitions parsed:\n%s", s)
+		}
+	}
+}
+
+// magaraSandbox is a small level for evoking magaras, with flammable foliage
+// next to the player, and monsters in sight, some in cardinal directions.
+const magaraSandbox = `legend Z monster guard asleep
+---
+########################################
+#......................................#
+#.........Z............................#
+#......................................#
+#........."............................#
+#...g.....@.......g....................#
+#......................................#
+#......................................#
+#..........y...........................#
+########################################
+`
+
+func TestMagaras(t *testing.T) {
+	for _, mk := range magaraKinds() {
+		data := mk.data()
+		if data.Effect == nil || data.Charges <= 0 || data.Desc == "" {
+			t.Errorf("incomplete magara definition: %v", magara{Kind: mk})
+		}
+		lv, err := parseLevel([]byte(magaraSandbox))
+		if err != nil {
+			t.Fatalf("parsing sandbox level: %v", err)
+		}
+		s := newLevelSim(1, lv)
+		g := s.Game()
+		g.Player.Magaras[0] = magara{Kind: mk, Charges: mk.DefaultCharges()}
+		g.Player.HP = 1 // for energy
+		if err := s.Do(simAction{Kind: SimEvoke, N: 0}); err != nil {
+			t.Errorf("evoking %v: %v", g.Player.Magaras[0], err)
+			continue
+		}
+		if g.Player.Magaras[0].Charges != mk.DefaultCharges()-1 {
+			t.Errorf("evoking %v: bad charges %d", g.Player.Magaras[0], g.Player.Magaras[0].Charges)
+		}
+	}
+}
+
+func TestPresets(t *testing.T) {
+	for _, name := range []string{"short run", "all mist levels", "no magaras", "monster zoo"} {
+		if p := presetByName(name); p == nil || p.Custom || p.Desc == "" {
+			t.Errorf("bad built-in preset %q: %+v", name, p)
+		}
+	}
+	presets, err := parseGamePresets([]byte(`# practice
+preset no magaras
+desc Still no magaras, and no events.
+nomagara all
+event normal all
+
+preset calm
+desc No blocked stairs,
+desc and cloaks everywhere.
+depths 3
+blocked none
+plan cloak 9-11
+special frogs 2 3
+bands 2 10
+crazyimp 0
+`), gamePresets)
+	if err != nil {
+		t.Fatalf("parsing presets: %v", err)
+	}
+	if len(presets) != len(gamePresets)+1 {
+		t.Fatalf("bad number of presets: %d", len(presets))
+	}
+	calm := presets[len(presets)-1]
+	if calm.Name != "calm" || calm.Depths != 3 || len(calm.Rules) != 5 || calm.Desc != "No blocked stairs, and cloaks everywhere." {
+		t.Errorf("bad preset: %+v", calm)
+	}
+	g := &game{Seed: 1, preset: calm}
+	g.InitLevel()
+	if g.Depth != MaxDepth-2 || g.Preset != "calm" {
+		t.Errorf("bad start: depth %d, preset %q", g.Depth, g.Preset)
+	}
+	if len(g.Params.Blocked) > 0 || g.GenPlan[9] != GenCloak || g.Params.Special[3] != roomFrogs ||
+		g.Params.ExtraBands[10] != 2 || g.Params.CrazyImp != 0 {
+		t.Errorf("bad start parameters: %+v", g.Params)
+	}
+	for d := g.Depth; d < MaxDepth; d++ {
+		g.Depth++
+		g.InitLevel()
+	}
+	zoo := &game{Seed: 1, preset: presetByName("monster zoo")}
+	zoo.InitLevel()
+	if normal := newSim(1).Game(); len(zoo.Bands) != len(normal.Bands)+6 {
+		t.Errorf("monster zoo: %d bands instead of %d", len(zoo.Bands), len(normal.Bands)+6)
+	}
+	bad := []string{
+		"depths 3\n",                    // outside of block
+		"preset x\nevent fog all\n",     // unknown event
+		"preset x\nspecial shaedra 3\n", // story room
+		"preset x\nspecial frogs 8\n",   // story depth
+		"preset x\nblocked 0-4\n",       // depth out of range
+		"preset x\ndepths 0\n",          // no depths
+		"preset x\nteleport all\n",      // unknown rule
+	}
+	for _, s := range bad {
+		if _, err := parseGamePresets([]byte(s), gamePresets); err == nil {
+			t.Errorf("invalid presets parsed:\n%s", s)
+		}
+	}
+	s := newPresetSim(3, presetByName("all mist levels"))
+	b := newExplorerBot(3)
+	for j := 0; j < 500 && !s.Finished(); j++ {
+		err = s.Do(b.Action(s, err))
+	}
+	r, err := DecodeReplay(s.Game().Replay(false).Encode())
+	if err != nil {
+		t.Fatalf("decoding preset replay: %v", err)
+	}
+	rs, err := r.Simulate(-1, nil)
+	if err != nil {
+		t.Fatalf("simulating preset replay: %v", err)
+	}
+	if r.Preset != "all mist levels" || rs.Game().fingerprint() != s.Game().fingerprint() {
+		t.Errorf("preset replay does not reproduce the game")
+	}
+}
+
+func TestDailyChallenge(t *testing.T) {
+	date := dailyDate(time.Date(2021, 4, 3, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*3600)))
+	if date != "2021-04-04" {
+		t.Errorf("bad date: %s", date)
+	}
+	if dailySeed(date) != dailySeed("2021-04-04") || dailySeed(date) == dailySeed("2021-04-05") || dailySeed(date) <= 0 {
+		t.Errorf("bad daily seeds")
+	}
+	os.Setenv("XDG_DATA_HOME", t.TempDir())
+	defer os.Unsetenv("XDG_DATA_HOME")
+	g := &game{Seed: dailySeed(date), Daily: date}
+	if err := g.StartDaily(); err != nil {
+		t.Fatalf("starting daily challenge: %v", err)
+	}
+	g.InitLevel()
+	if err := g.ResumeDaily(); err != nil {
+		t.Errorf("resuming daily challenge: %v", err)
+	}
+	if err := (&game{Daily: date}).StartDaily(); err == nil {
+		t.Errorf("daily challenge started twice")
+	}
+	g.Turn = 1234
+	g.LiberatedShaedra = true
+	g.Stats.NSpotted = 5
+	if err := AppendScore(g.Score(ChallengeDeath)); err != nil {
+		t.Fatalf("appending score: %v", err)
+	}
+	if err := g.ResumeDaily(); err != errDailyOver {
+		t.Errorf("resumed finished daily challenge: %v", err)
+	}
+	if err := AppendScore(score{Date: "2021-04-01", Outcome: ChallengeEscape, Depth: 11}); err != nil {
+		t.Fatalf("appending score: %v", err)
+	}
+	scores, err := LoadScoreboard()
+	if err != nil {
+		t.Fatalf("loading scoreboard: %v", err)
+	}
+	if len(scores) != 3 {
+		t.Fatalf("bad number of entries: %d", len(scores))
+	}
+	daily := dailyScores(scores)
+	want := score{Date: date, Outcome: ChallengeDeath, Turns: 1234, Depth: 1, Shaedra: true, Spotted: 5}
+	if len(daily) != 2 || daily[0] != want || daily[1].Date != "2021-04-01" {
+		t.Errorf("bad daily scores: %+v", daily)
+	}
+	if _, err := parseScoreboard([]byte("2021-04-01 victory turns 1\n")); err == nil {
+		t.Errorf("invalid scoreboard parsed")
+	}
+}
+
+func TestDailyKillAndReload(t *testing.T) {
+	os.Setenv("XDG_DATA_HOME", t.TempDir())
+	defer os.Unsetenv("XDG_DATA_HOME")
+	date := "2021-04-04"
+	start := func() *game {
+		md := &model{gd: gruid.NewGrid(UIWidth, UIHeight), g: &game{Seed: dailySeed(date), Daily: date}}
+		md.init()
+		return md.g
+	}
+	g := start()
+	if g.Daily != date || g.Depth != 1 {
+		t.Fatalf("daily challenge not started: %q depth %d", g.Daily, g.Depth)
+	}
+	g.Descend(DescendNormal)
+	// the game is killed: there should be no save to resume from
+	if g := start(); g.Daily != "" {
+		t.Errorf("daily challenge resumed after being killed on depth %d", g.Depth)
+	}
+	g = start()
+	g.Daily = date
+	if err := g.Save(); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+	if g := start(); g.Daily != date {
+		t.Errorf("saved daily challenge not resumed")
+	}
+	// killed again after reloading
+	if g := start(); g.Daily != "" {
+		t.Errorf("daily challenge resumed twice from the same save")
+	}
+}
+
+func TestRunHistory(t *testing.T) {
+	os.Setenv("XDG_DATA_HOME", t.TempDir())
+	defer os.Unsetenv("XDG_DATA_HOME")
+	g := &game{}
+	g.InitLevel()
+	g.Turn = 321
+	g.Stats.Killed = 2
+	g.Stats.UsedMagaras[BlinkMagara] = 3
+	AchTree.Get(g)
+	g.Player.HP = 0
+	dump := g.Dump()
+	if err := g.recordRun("2021-04-03 18:12"); err != nil {
+		t.Fatalf("recording run: %v", err)
+	}
+	g.Wizard = true
+	if err := g.recordRun("2021-04-03 18:13"); err != nil {
+		t.Fatalf("recording run: %v", err)
+	}
+	if err := AppendRun(runRecord{Date: "2021-04-01 10:00", Outcome: "escape", Depth: 11, Turns: 5000}); err != nil {
+		t.Fatalf("recording run: %v", err)
+	}
+	if err := AppendRun(runRecord{Date: "2021-04-02 10:00", Outcome: "death", Depth: 11, Turns: 9000}); err != nil {
+		t.Fatalf("recording run: %v", err)
+	}
+	runs, err := LoadRuns()
+	if err != nil {
+		t.Fatalf("loading runs: %v", err)
+	}
+	if len(runs) != 3 {
+		t.Fatalf("bad number of runs: %d", len(runs))
+	}
+	r := runs[0]
+	if r.Outcome != "death" || r.Turns != 321 || r.Kills != 2 || r.Depth != 1 || r.Version != Version ||
+		len(r.SpottedPerc) != 1 || r.Magaras[(magara{Kind: BlinkMagara}).String()] != 3 || r.Dump != dump {
+		t.Errorf("bad run record: %+v", r)
+	}
+	if earned := earnedAchievements(runs); earned[AchTree] != 1 || len(earned) != 1 {
+		t.Errorf("bad earned achievements: %v", earned)
+	}
+	orders := map[runSort][]string{
+		RunsByDate:         {"2021-04-03 18:12", "2021-04-02 10:00", "2021-04-01 10:00"},
+		RunsByDepth:        {"2021-04-01 10:00", "2021-04-02 10:00", "2021-04-03 18:12"},
+		RunsByTurns:        {"2021-04-02 10:00", "2021-04-01 10:00", "2021-04-03 18:12"},
+		RunsByAchievements: {"2021-04-03 18:12", "2021-04-02 10:00", "2021-04-01 10:00"},
+	}
+	for by, dates := range orders {
+		sortRuns(runs, by)
+		for i, r := range runs {
+			if r.Date != dates[i] {
+				t.Errorf("bad order by %v: %s at %d", by, r.Date, i)
+			}
+		}
+	}
+	if _, err := parseRuns([]byte("{\"date\": 3}\n")); err == nil {
+		t.Errorf("invalid run history parsed")
+	}
+}
+
+func TestBestiary(t *testing.T) {
+	os.Setenv("XDG_DATA_HOME", t.TempDir())
+	defer os.Unsetenv("XDG_DATA_HOME")
+	lv, err := parseLevel([]byte("---\n#######\n#@...G#\n#######\n"))
+	if err != nil {
+		t.Fatalf("parsing level: %v", err)
+	}
+	s := newLevelSim(1, lv)
+	g := s.Game()
+	mons := g.MonsterAt(gruid.Point{5, 1})
+	if !mons.Exists() || !mons.Seen {
+		t.Fatalf("monster not seen")
+	}
+	mk := mons.Kind
+	if g.Stats.SeenMons[mk] != 1 || !g.Stats.MonsDepths[mk][1] {
+		t.Errorf("bad bestiary statistics: %v %v", g.Stats.SeenMons, g.Stats.MonsDepths)
+	}
+	g.Stats.SpottingMons[mk] = 2
+	g.Stats.KilledMons[MonsGuard] = 1
+	for i := 0; i < 2; i++ {
+		if err := g.recordBestiary(); err != nil {
+			t.Fatalf("recording bestiary: %v", err)
+		}
+	}
+	g.Depth = 3
+	g.Wizard = true
+	mons.markSeen(g)
+	if err := g.recordBestiary(); err != nil {
+		t.Fatalf("recording bestiary: %v", err)
+	}
+	b, err := LoadBestiary()
+	if err != nil {
+		t.Fatalf("loading bestiary: %v", err)
+	}
+	e := b[mk.String()]
+	if e == nil || e.Seen != 2 || e.Spotted != 4 || len(e.Depths) != 1 || e.Depths[0] != 1 {
+		t.Errorf("bad bestiary entry: %+v", e)
+	}
+	if e := b[MonsGuard.String()]; e == nil || e.Killed != 2 || e.Seen != 0 {
+		t.Errorf("bad bestiary entry for killed monster: %+v", e)
+	}
+	b.merge(g.Bestiary())
+	if e := b[mk.String()]; e.Seen != 4 || len(e.Depths) != 2 || e.Depths[1] != 3 {
+		t.Errorf("bad merged bestiary entry: %+v", e)
+	}
+	if _, err := parseBestiary([]byte(`{"guard": 3}`)); err == nil {
+		t.Errorf("invalid bestiary parsed")
+	}
+}
+
+func TestMonsterVision(t *testing.T) {
+	lv, err := parseLevel([]byte("---\n##########\n#@......G#\n##########\n"))
+	if err != nil {
+		t.Fatalf("parsing level: %v", err)
+	}
+	s := newLevelSim(1, lv)
+	g := s.Game()
+	m := g.MonsterAt(gruid.Point{8, 1})
+	m.Dir = gruid.Point{-1, 0}
+	m.State = Wandering
+	m.ComputeLOS(g)
+	p := gruid.Point{6, 1}
+	if hunting, ok := g.MonsterVision([]*monster{m})[p]; !ok || hunting {
+		t.Fatalf("bad vision of wandering monster: %v %v", ok, hunting)
+	}
+	m.State = Hunting
+	if !g.MonsterVision([]*monster{m})[p] {
+		t.Errorf("bad vision of hunting monster")
+	}
+	m.Dir = gruid.Point{1, 0}
+	m.ComputeLOS(g)
+	if _, ok := g.MonsterVision([]*monster{m})[p]; ok {
+		t.Errorf("cell behind monster seen")
+	}
+	m.Dir = gruid.Point{-1, 0}
+	m.ComputeLOS(g)
+	md := &model{g: g}
+	md.targ.ex = &examination{p: invalidPos}
+	defer func(overlay bool) { GameConfig.VisionOverlay = overlay }(GameConfig.VisionOverlay)
+	GameConfig.VisionOverlay = true
+	md.computeVision()
+	if _, _, bg := md.positionDrawing(p); bg != ColorBgHuntingLOS {
+		t.Errorf("bad overlay background: %v", bg)
+	}
+	GameConfig.VisionOverlay = false
+	md.computeVision()
+	if _, _, bg := md.positionDrawing(p); bg == ColorBgHuntingLOS || md.vision != nil {
+		t.Errorf("overlay shown while disabled")
+	}
+}
+
+func TestNoisePreview(t *testing.T) {
+	lv, err := parseLevel([]byte("legend Z monster high guard asleep\n---\n###########\n#@q.....Z.#\n###########\n"))
+	if err != nil {
+		t.Fatalf("parsing level: %v", err)
+	}
+	s := newLevelSim(1, lv)
+	g := s.Game()
+	it := g.Dungeon.Grid.Iterator()
+	for it.Next() {
+		g.Dungeon.SetExplored(it.P())
+	}
+	m := g.MonsterAt(gruid.Point{8, 1})
+	if !m.Exists() || m.State != Resting || !g.Player.Sees(m.P) {
+		t.Fatalf("bad monster: %+v", m)
+	}
+	if preview := g.NoisePreview(NoiseSrcFootsteps, g.Player.P); len(preview) != 0 {
+		t.Errorf("noisy footsteps on ground: %v", preview)
+	}
+	q := gruid.Point{2, 1}
+	preview := g.NoisePreview(NoiseSrcFootsteps, q)
+	if disturbed, ok := preview[m.P]; !ok || disturbed || len(preview) != 9 {
+		t.Errorf("bad footsteps preview: %v", preview)
+	}
+	if !g.NoisePreview(NoiseSrcDelayed, q)[m.P] {
+		t.Errorf("resting monster not disturbed by delayed noise")
+	}
+	g.MakeNoise(QueenRockFootstepNoise, q)
+	if m.State != Resting {
+		t.Errorf("resting monster disturbed by footsteps")
+	}
+	g.MakeNoise(DelayedHarmonicNoise, q)
+	if m.State == Resting {
+		t.Errorf("resting monster not disturbed by delayed noise")
+	}
+}
+
+func TestMagaraPreview(t *testing.T) {
+	lv, err := parseLevel([]byte("legend Z monster high guard asleep\n---\n#########\n#@..Z..F#\n#.......#\n#..P....#\n#########\n"))
+	if err != nil {
+		t.Fatalf("parsing level: %v", err)
+	}
+	s := newLevelSim(1, lv)
+	g := s.Game()
+	guard := g.MonsterAt(gruid.Point{4, 1})
+	frog := g.MonsterAt(gruid.Point{7, 1})
+	plant := g.MonsterAt(gruid.Point{3, 3})
+	for _, m := range []*monster{guard, frog, plant} {
+		if !m.Exists() || !g.Player.Sees(m.P) {
+			t.Fatalf("bad monster: %+v", m)
+		}
+	}
+	mp, ok := g.MagaraPreview(SleepingMagara)
+	if !ok || len(mp.Targets) != 1 || mp.Targets[0] != frog || len(mp.Ignored) != 1 || !mp.Cells[frog.P] {
+		t.Errorf("bad sleeping preview: %+v", mp)
+	}
+	mp, ok = g.MagaraPreview(TeleportOtherMagara)
+	if !ok || len(mp.Targets) != 2 || mp.Warning == "" {
+		t.Errorf("bad teleport other preview: %+v", mp)
+	}
+	mp, ok = g.MagaraPreview(LignificationMagara)
+	if !ok || len(mp.Targets) != 2 || len(mp.Ignored) != 1 || mp.Cells[plant.P] {
+		t.Errorf("bad lignification preview: %+v", mp)
+	}
+	mp, ok = g.MagaraPreview(ParalysisMagara)
+	if !ok || len(mp.Targets) != 3 || !mp.Cells[g.Player.P] {
+		t.Errorf("bad paralysis preview: %+v", mp)
+	}
+	if _, ok := g.MagaraPreview(BlinkMagara); ok {
+		t.Errorf("preview for magara of blinking")
+	}
+	if err := g.EvokeSleeping(); err != nil {
+		t.Fatalf("evoking sleeping: %v", err)
+	}
+	if frog.State != Resting {
+		t.Errorf("previewed target not asleep: %+v", frog)
+	}
+	if _, ok := g.MagaraPreview(SleepingMagara); ok {
+		t.Errorf("sleeping preview without targets")
+	}
+}
+
+func TestOverview(t *testing.T) {
+	lv, err := parseLevel([]byte("---\n##########\n#@..>...G#\n##########\n"))
+	if err != nil {
+		t.Fatalf("parsing level: %v", err)
+	}
+	s := newLevelSim(1, lv)
+	g := s.Game()
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 3; y++ {
+			g.Dungeon.SetExplored(gruid.Point{x, y})
+		}
+	}
+	for q, p := range map[gruid.Point]gruid.Point{
+		{0, 0}: g.Player.P,
+		{2, 0}: {4, 1},
+		{4, 0}: {8, 1},
+	} {
+		if cp := g.overviewCell(q); cp != p {
+			t.Errorf("bad overview cell for %v: %v (expected %v)", q, cp, p)
+		}
+	}
+	if p := g.overviewCell(gruid.Point{10, 5}); g.overviewPriority(p) >= 0 {
+		t.Errorf("unknown overview cell %v shown", p)
+	}
+	md := &model{g: g}
+	md.targ.ex = &examination{p: invalidPos}
+	lines := strings.Split(md.OverviewText(), "\n")
+	if len(lines) != DungeonHeight+2 || lines[2] != "#@..>...G#" {
+		t.Errorf("bad overview text: %q", lines)
+	}
+}
+
+func TestThemes(t *testing.T) {
+	defer func(ths []*theme, dark bool) {
+		themes = ths
+		GameConfig.DarkLOS = dark
+		themes[0].apply()
+	}(themes, GameConfig.DarkLOS)
+	if len(themes) != 3 || themeByName("red-green") == nil || themeByName("blue-yellow") == nil {
+		t.Fatalf("bad built-in themes: %v", themes)
+	}
+	data := "# custom themes\ntheme calm\ndesc No red.\ncolor fg-hp-critical magenta\ndark magenta #CC79A7 175\n"
+	if err := LoadThemes([]byte(data)); err != nil {
+		t.Fatalf("loading themes: %v", err)
+	}
+	th := themeByName("calm")
+	if th == nil || !th.Custom || th.Desc != "No red." || len(themes) != 4 {
+		t.Fatalf("bad custom theme: %+v", th)
+	}
+	th.apply()
+	if ColorFgHPcritical != ColorMagenta || ColorFgHPok != ColorGreen || CurrentTheme != th {
+		t.Errorf("theme not applied: %v %v", ColorFgHPcritical, ColorFgHPok)
+	}
+	GameConfig.DarkLOS = true
+	if pc, ok := th.palette(ColorMagenta, true); !ok || pc.X256 != 175 || pc.RGB.R != 0xCC {
+		t.Errorf("bad dark palette color: %+v", pc)
+	}
+	GameConfig.DarkLOS = false
+	if _, ok := th.palette(ColorMagenta, true); ok {
+		t.Errorf("dark palette color used in light mode")
+	}
+	themes[0].apply()
+	if ColorFgHPcritical != ColorRed {
+		t.Errorf("default colors not restored")
+	}
+	for _, bad := range []string{
+		"color fg-hp-ok blue\n",
+		"theme x\ncolor fg-unknown blue\n",
+		"theme x\ncolor fg-hp-ok pink\n",
+		"theme x\ndark red #D55E0 166\n",
+		"theme x\nlight red #D55E00 256\n",
+		"theme x\nfoo bar\n",
+	} {
+		if _, err := parseThemes([]byte(bad), nil); err == nil {
+			t.Errorf("no error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// genInfo records information about the generation of a level that cannot
+// be recovered from the resulting map.
+type genInfo struct {
+	Layout maplayout
+	Rooms  int
+}
+
+// levelStats are statistics about a generated level, used for tuning
+// dungeon generation.
+type levelStats struct {
+	Layout        maplayout
+	Rooms         int
+	Doors         int
+	Windows       int
+	HoledWalls    int
+	Foliage       int
+	Free          int // non-wall cells, as for exploration statistics
+	Barrels       int
+	Tables        int
+	Trees         int
+	Bands         int
+	BandKinds     map[monsterBand]int
+	Monsters      map[monsterKind]int
+	Dangerousness int
+	Event         specialEvent
+}
+
+// GenLevelStats returns generation statistics about the current level.
+func (g *game) GenLevelStats() levelStats {
+	ls := levelStats{
+		Layout:    g.genInfo.Layout,
+		Rooms:     g.genInfo.Rooms,
+		Bands:     len(g.Bands),
+		BandKinds: map[monsterBand]int{},
+		Monsters:  map[monsterKind]int{},
+		Event:     g.Params.Event[g.Depth],
+	}
+	for _, bd := range g.Bands {
+		ls.BandKinds[bd.Kind]++
+	}
+	it := g.Dungeon.Grid.Iterator()
+	for it.Next() {
+		c := cell(it.Cell())
+		switch terrain(c) {
+		case DoorCell:
+			ls.Doors++
+		case WindowCell:
+			ls.Windows++
+		case HoledWallCell:
+			ls.HoledWalls++
+		case FoliageCell:
+			ls.Foliage++
+		case BarrelCell:
+			ls.Barrels++
+		case TableCell:
+			ls.Tables++
+		case TreeCell:
+			ls.Trees++
+		}
+		if !c.IsWall() && terrain(c) != ChasmCell {
+			ls.Free++
+		}
+	}
+	for _, mons := range g.Monsters {
+		ls.Monsters[mons.Kind]++
+		ls.Dangerousness += mons.Kind.Dangerousness()
+	}
+	return ls
+}
+
+// genDepthStats accumulates level statistics for a given depth.
+type genDepthStats struct {
+	levels     int
+	sum        levelStats
+	minRooms   int
+	maxRooms   int
+	minDanger  int
+	maxDanger  int
+	foliage    float64 // sum of foliage percentages
+	layouts    map[maplayout]int
+	events     [spEvMax + 1]int
+	monsterSum map[monsterKind]int
+	bandSum    map[string]int // by band composition
+}
+
+func (ds *genDepthStats) add(ls levelStats) {
+	if ds.levels == 0 || ls.Rooms < ds.minRooms {
+		ds.minRooms = ls.Rooms
+	}
+	if ls.Rooms > ds.maxRooms {
+		ds.maxRooms = ls.Rooms
+	}
+	if ds.levels == 0 || ls.Dangerousness < ds.minDanger {
+		ds.minDanger = ls.Dangerousness
+	}
+	if ls.Dangerousness > ds.maxDanger {
+		ds.maxDanger = ls.Dangerousness
+	}
+	ds.levels++
+	ds.sum.Rooms += ls.Rooms
+	ds.sum.Doors += ls.Doors
+	ds.sum.Windows += ls.Windows
+	ds.sum.HoledWalls += ls.HoledWalls
+	ds.sum.Barrels += ls.Barrels
+	ds.sum.Tables += ls.Tables
+	ds.sum.Trees += ls.Trees
+	ds.sum.Bands += ls.Bands
+	ds.sum.Dangerousness += ls.Dangerousness
+	if ls.Free > 0 {
+		ds.foliage += float64(100*ls.Foliage) / float64(ls.Free)
+	}
+	ds.layouts[ls.Layout]++
+	ds.events[ls.Event]++
+	for mk, n := range ls.Monsters {
+		ds.monsterSum[mk] += n
+	}
+	for b, n := range ls.BandKinds {
+		ds.bandSum[b.String()] += n
+	}
+}
+
+// String describes the composition of the band, for example "dog" for a
+// lone dog, and "dog x2" for a pair of dogs.
+func (b monsterBand) String() string {
+	bd := MonsBands[b]
+	if !bd.Band {
+		return bd.Monster.String()
+	}
+	parts := []string{}
+	for mk := range MonsData {
+		if n := bd.Distribution[monsterKind(mk)]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s x%d", monsterKind(mk), n))
+		}
+	}
+	return strings.Join(parts, " + ")
+}
+
+// avg returns the average of a sum over the levels of the depth.
+func (ds *genDepthStats) avg(sum int) float64 {
+	if ds.levels == 0 {
+		return 0
+	}
+	return float64(sum) / float64(ds.levels)
+}
+
+// GenStats generates n full games of all depths, without playing them, and
+// writes per-depth statistics about the generated levels to w.
+func GenStats(w io.Writer, n int, seed int64) {
+	var stats [MaxDepth + 1]genDepthStats
+	for d := range stats {
+		stats[d].layouts = map[maplayout]int{}
+		stats[d].monsterSum = map[monsterKind]int{}
+		stats[d].bandSum = map[string]int{}
+	}
+	for i := 0; i < n; i++ {
+		gseed := int64(0)
+		if seed != 0 {
+			gseed = seed + int64(i)
+		}
+		g := &game{Seed: gseed}
+		g.InitLevel()
+		for {
+			stats[g.Depth].add(g.GenLevelStats())
+			if g.Depth == MaxDepth {
+				break
+			}
+			g.Depth++
+			g.InitLevel()
+		}
+	}
+	if n <= 0 {
+		return
+	}
+	fmt.Fprintf(w, "Games: %d\n", n)
+	fmt.Fprintf(w, "\nAverages per level:\n")
+	fmt.Fprintf(w, "\n| Depth | Rooms (range) | Doors | Windows | Holed walls | Foliage | Barrels | Tables | Trees | Bands | Dangerousness (range) |\n")
+	for d := 1; d <= MaxDepth; d++ {
+		ds := &stats[d]
+		fmt.Fprintf(w, "| %5d | %5.1f (%2d-%2d) | %5.1f | %7.1f | %11.1f | %6.1f%% | %7.1f | %6.1f | %5.1f | %5.1f | %11.1f (%3d-%3d) |\n",
+			d, ds.avg(ds.sum.Rooms), ds.minRooms, ds.maxRooms, ds.avg(ds.sum.Doors), ds.avg(ds.sum.Windows),
+			ds.avg(ds.sum.HoledWalls), ds.foliage/float64(ds.levels), ds.avg(ds.sum.Barrels),
+			ds.avg(ds.sum.Tables), ds.avg(ds.sum.Trees), ds.avg(ds.sum.Bands),
+			ds.avg(ds.sum.Dangerousness), ds.minDanger, ds.maxDanger)
+	}
+	fmt.Fprintf(w, "\nLayouts and special events (%% of levels):\n")
+	fmt.Fprintf(w, "\n| Depth |")
+	for _, ml := range mapLayouts {
+		fmt.Fprintf(w, " %9s |", ml)
+	}
+	fmt.Fprintf(w, " Unstable | Earthquake |  Mist |\n")
+	for d := 1; d <= MaxDepth; d++ {
+		ds := &stats[d]
+		fmt.Fprintf(w, "| %5d |", d)
+		for _, ml := range mapLayouts {
+			fmt.Fprintf(w, " %8.1f%% |", 100*ds.avg(ds.layouts[ml]))
+		}
+		fmt.Fprintf(w, " %7.1f%% | %9.1f%% | %4.1f%% |\n", 100*ds.avg(ds.events[UnstableLevel]),
+			100*ds.avg(ds.events[EarthquakeLevel]), 100*ds.avg(ds.events[MistLevel]))
+	}
+	fmt.Fprintf(w, "\nMonsters (average per level):\n\n")
+	for d := 1; d <= MaxDepth; d++ {
+		ds := &stats[d]
+		mons := []string{}
+		for mk := range MonsData {
+			if n := ds.monsterSum[monsterKind(mk)]; n > 0 {
+				mons = append(mons, fmt.Sprintf("%s %.2f", monsterKind(mk), ds.avg(n)))
+			}
+		}
+		fmt.Fprintf(w, "%2d: %s\n", d, strings.Join(mons, ", "))
+	}
+	fmt.Fprintf(w, "\nMonster bands (average per level):\n\n")
+	for d := 1; d <= MaxDepth; d++ {
+		ds := &stats[d]
+		bands := []string{}
+		seen := map[string]bool{}
+		for b := range MonsBands {
+			desc := monsterBand(b).String()
+			if n := ds.bandSum[desc]; n > 0 && !seen[desc] {
+				bands = append(bands, fmt.Sprintf("%s %.2f", desc, ds.avg(n)))
+				seen[desc] = true
+			}
+		}
+		fmt.Fprintf(w, "%2d: %s\n", d, strings.Join(bands, ", "))
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/anaseto/gruid"
+)
+
+// sim drives a game without any gruid driver, model or animations. It is
+// meant for scripts, bots and tests: a player action is applied with Do, and
+// the resulting state can be queried with Observe or directly through the
+// game.
+type sim struct {
+	g        *game
+	logCount int // number of log entries already observed
+	logDups  int // number of repeats of the last observed log entry
+	won      bool
+	replay   bool // auto-actions progress only with SimAuto, as in interactive games
+}
+
+// simActionKind represents the kind of a player action in a headless game.
+type simActionKind int
+
+const (
+	SimWait           simActionKind = iota
+	SimMove                         // move (or bump) in direction Dir
+	SimJump                         // like SimMove, but jumping into chasms too
+	SimEvoke                        // evoke magara in slot N
+	SimEquip                        // take magara on the ground, leaving the one in slot N
+	SimInteract                     // interact with current position
+	SimDescend                      // take the stairs at current position
+	SimExplore                      // auto-explore until something interesting happens
+	SimTravel                       // auto-travel to position Target
+	SimRun                          // run in direction Dir
+	SimExclude                      // exclude area around Target from auto-travel
+	SimClearExclude                 // clear exclusions around Target
+	SimWizard                       // enter wizard mode
+	SimWizardDescend                // descend wizardly
+	SimAuto                         // continue auto-action by one step
+	SimReload                       // save and load the game (replays only)
+	SimSafeTravel                   // auto-travel to position Target by a safe path
+	SimStealthExplore               // like SimExplore, but stealthily
+)
+
+func (k simActionKind) String() (s string) {
+	switch k {
+	case SimWait:
+		s = "wait"
+	case SimMove:
+		s = "move"
+	case SimJump:
+		s = "jump"
+	case SimEvoke:
+		s = "evoke"
+	case SimEquip:
+		s = "equip"
+	case SimInteract:
+		s = "interact"
+	case SimDescend:
+		s = "descend"
+	case SimExplore:
+		s = "explore"
+	case SimTravel:
+		s = "travel"
+	case SimRun:
+		s = "run"
+	case SimExclude:
+		s = "exclude"
+	case SimClearExclude:
+		s = "clear-exclude"
+	case SimWizard:
+		s = "wizard"
+	case SimWizardDescend:
+		s = "wizard-descend"
+	case SimAuto:
+		s = "auto"
+	case SimReload:
+		s = "reload"
+	case SimSafeTravel:
+		s = "safe-travel"
+	case SimStealthExplore:
+		s = "stealth-explore"
+	}
+	return s
+}
+
+// simAction describes a player action in a headless game.
+type simAction struct {
+	Kind   simActionKind
+	Dir    gruid.Point // direction for moves, jumps and runs
+	N      int         // magara slot for evocation and equipment
+	Target gruid.Point // destination for travel, center for exclusions
+}
+
+func (a simAction) String() string {
+	switch a.Kind {
+	case SimMove, SimJump, SimRun:
+		return fmt.Sprintf("%v %s", a.Kind, dirString(a.Dir))
+	case SimEvoke, SimEquip:
+		return fmt.Sprintf("%v %d", a.Kind, a.N)
+	case SimTravel, SimSafeTravel, SimExclude, SimClearExclude:
+		return fmt.Sprintf("%v %d,%d", a.Kind, a.Target.X, a.Target.Y)
+	default:
+		return a.Kind.String()
+	}
+}
+
+// newSim returns a new headless game using the given seed (0 means a random
+// seed), already initialized at the first depth.
+func newSim(seed int64) *sim {
+	g := &game{Seed: seed}
+	g.InitLevel()
+	g.ComputeMapInfo()
+	return &sim{g: g}
+}
+
+// newLevelSim returns a new headless game using the given seed, started on
+// the given hand-made level.
+func newLevelSim(seed int64, lv *level) *sim {
+	g := &game{Seed: seed, level: lv}
+	g.InitLevel()
+	g.ComputeMapInfo()
+	return &sim{g: g}
+}
+
+// newPresetSim returns a new headless game using the given seed and game
+// preset (nil means none).
+func newPresetSim(seed int64, p *gamePreset) *sim {
+	g := &game{Seed: seed, preset: p}
+	g.InitLevel()
+	g.ComputeMapInfo()
+	return &sim{g: g}
+}
+
+// Game returns the underlying game state.
+func (s *sim) Game() *game {
+	return s.g
+}
+
+// Finished reports whether the game is over, either by death or escape.
+func (s *sim) Finished() bool {
+	return s.won || s.g.Player.HP <= 0
+}
+
+// Won reports whether the player escaped.
+func (s *sim) Won() bool {
+	return s.won
+}
+
+// Do applies a player action and records it in the game's replay. If the
+// action takes time, monsters and other events are then run until the
+// player's next turn, including any auto-actions (like auto-explore) started
+// by the action. An error is returned, and printed in the log, if the action
+// could not be performed, in which case no time passes.
+func (s *sim) Do(a simAction) error {
+	if s.Finished() {
+		return errors.New("The game is over.")
+	}
+	s.g.record(a)
+	again, err := s.do(a)
+	if err != nil {
+		// as in interactive games, the player is told about the failure
+		s.g.Print(err.Error())
+		return err
+	}
+	if !again {
+		s.endTurn()
+	}
+	s.story()
+	return nil
+}
+
+func (s *sim) do(a simAction) (again bool, err error) {
+	g := s.g
+	switch a.Kind {
+	case SimWait:
+		g.WaitTurn()
+	case SimMove:
+		again, err = g.PlayerBump(g.Player.P.Add(a.Dir))
+	case SimJump:
+		p := g.Player.P.Add(a.Dir)
+		if valid(p) && terrain(g.Dungeon.Cell(p)) == ChasmCell && !g.Player.HasStatus(StatusLevitation) {
+			if g.DeepChasmDepth() {
+				return true, errors.New("You cannot jump into deep chasm.")
+			}
+			again = true
+			g.FallAbyss(DescendJump)
+			break
+		}
+		again, err = g.PlayerBump(p)
+	case SimEvoke:
+		if a.N < 0 || a.N >= len(g.Player.Magaras) {
+			return true, errors.New("Invalid magara slot.")
+		}
+		err = g.UseMagara(a.N)
+	case SimEquip:
+		if terrain(g.Dungeon.Cell(g.Player.P)) != MagaraCell {
+			return true, errors.New("There is no magara here.")
+		}
+		if a.N < 0 || a.N >= len(g.Player.Magaras) {
+			return true, errors.New("Invalid magara slot.")
+		}
+		err = g.EquipMagara(a.N)
+	case SimDescend:
+		again, err = s.descend()
+	case SimInteract:
+		again, err = s.interact()
+	case SimExplore:
+		again, err = g.Autoexplore()
+	case SimStealthExplore:
+		again, err = g.StealthAutoexplore()
+	case SimTravel:
+		if !valid(a.Target) {
+			return true, errors.New("You do not know this place.")
+		}
+		err = g.SetAutoTarget(a.Target)
+		if err == nil && !g.MoveToTarget() {
+			err = errors.New("You could not move toward this place.")
+		}
+	case SimSafeTravel:
+		if !valid(a.Target) {
+			return true, errors.New("You do not know this place.")
+		}
+		err = g.SetSafeAutoTarget(a.Target)
+		if err == nil && !g.MoveToTarget() {
+			err = errors.New("You could not move toward this place.")
+		}
+	case SimRun:
+		again, err = g.GoToDir(a.Dir)
+	case SimExclude, SimClearExclude:
+		again = true
+		if !valid(a.Target) {
+			return true, errors.New("Invalid exclusion center.")
+		}
+		if a.Kind == SimExclude {
+			g.ExcludeZone(a.Target)
+		} else {
+			g.ClearExcludeZone(a.Target)
+		}
+	case SimWizard:
+		again = true
+		if !g.Wizard {
+			g.EnterWizardMode()
+		}
+	case SimWizardDescend:
+		again = true
+		if !g.Wizard || g.Depth >= MaxDepth {
+			return true, errors.New("You cannot descend wizardly.")
+		}
+		if g.WizardDescend() {
+			s.won = true
+		}
+	case SimAuto:
+		if !s.replay {
+			return true, errors.New("Auto-actions continue automatically.")
+		}
+	case SimReload:
+		again = true
+		if !s.replay {
+			return true, errors.New("Reloading is only done in replays.")
+		}
+		err = s.reload()
+	default:
+		err = errors.New("unknown action")
+	}
+	if err != nil {
+		again = true
+	}
+	return again, err
+}
+
+func (s *sim) descend() (again bool, err error) {
+	g := s.g
+	if terrain(g.Dungeon.Cell(g.Player.P)) != StairCell {
+		return true, errors.New("No stairs here.")
+	}
+	st := g.Objects.Stairs[g.Player.P]
+	if st == BlockedStair {
+		return true, errors.New("The stairs are blocked by a magical stone barrier energies.")
+	}
+	if err := g.checkShaedra(st); err != nil {
+		return true, err
+	}
+	if g.Descend(DescendNormal) {
+		s.won = true
+	}
+	return true, nil
+}
+
+func (s *sim) interact() (again bool, err error) {
+	g := s.g
+	switch terrain(g.Dungeon.Cell(g.Player.P)) {
+	case StairCell:
+		again, err = s.descend()
+	case BarrelCell:
+		err = g.Rest()
+	case StoneCell:
+		err = g.ActivateStone()
+	case ItemCell:
+		err = g.EquipItem()
+	case LightCell:
+		err = g.ExtinguishFire()
+	case StoryCell:
+		err = g.InteractStory()
+	case ScrollCell:
+		again = true
+		g.Print("You read the message.")
+		if g.Objects.Scrolls[g.Player.P] == ScrollLore {
+			g.ReadLore()
+		}
+	default:
+		err = errors.New("You cannot interact with anything here.")
+	}
+	return again, err
+}
+
+// reload saves and loads back the game, as happens when an interactive game
+// is resumed.
+func (s *sim) reload() error {
+	data, err := s.g.GameSave()
+	if err != nil {
+		return err
+	}
+	lg, err := s.g.DecodeGameSave(data)
+	if err != nil {
+		return err
+	}
+	*s.g = *lg
+	s.g.reloadRand()
+	s.g.ComputeMapInfo()
+	return nil
+}
+
+// endTurn ends the player's turn, like the model's EndTurn, but without
+// delays: auto-actions are run until they stop, each step being recorded as
+// a SimAuto action. When replaying, they go on with the replay's SimAuto
+// actions instead.
+func (s *sim) endTurn() {
+	g := s.g
+	for {
+		g.EndTurn()
+		auto := g.AutoPlayer()
+		g.TurnStats()
+		g.ComputeMapInfo()
+		if g.Player.HP <= 0 {
+			g.LevelStats()
+			if len(g.Stats.Achievements) == 0 {
+				NoAchievement.Get(g)
+			}
+			return
+		}
+		if !auto || s.replay || g.storyPending {
+			return
+		}
+		g.record(simAction{Kind: SimAuto})
+	}
+}
+
+// story finishes any story sequence started during the last action, as
+// happens in interactive games after the player's confirmations.
+func (s *sim) story() {
+	g := s.g
+	if !g.storyPending {
+		return
+	}
+	g.storyPending = false
+	for i := 1; !g.StoryStep(i); i++ {
+	}
+}
+
+// simMonster contains information about a monster seen by the player.
+type simMonster struct {
+	Kind  monsterKind
+	P     gruid.Point
+	State monsterState
+	Dir   gruid.Point
+}
+
+// simObs contains the information available to the player after an action.
+type simObs struct {
+	Depth    int
+	Turn     int
+	P        gruid.Point
+	HP       int
+	MP       int
+	Bananas  int
+	Spotted  bool         // whether the player is seen by a monster
+	Monsters []simMonster // monsters in view
+	Messages []string     // log messages since last observation
+}
+
+// Observe returns the current observations of the player.
+func (s *sim) Observe() simObs {
+	g := s.g
+	obs := simObs{
+		Depth:   g.Depth,
+		Turn:    g.Turn,
+		P:       g.Player.P,
+		HP:      g.Player.HP,
+		MP:      g.Player.MP,
+		Bananas: g.Player.Bananas,
+		Spotted: g.MonsterLOS[g.Player.P],
+	}
+	for _, mons := range g.Monsters {
+		if mons.Exists() && g.Player.Sees(mons.P) {
+			obs.Monsters = append(obs.Monsters, simMonster{Kind: mons.Kind, P: mons.P, State: mons.State, Dir: mons.Dir})
+		}
+	}
+	if len(g.Log) == 0 {
+		return obs
+	}
+	// repeated messages are merged into a single log entry, so new messages
+	// are found using the count of log entries and of their repeats
+	n := g.LogIndex - s.logCount
+	if n > len(g.Log) {
+		// old entries have been dropped
+		n = len(g.Log)
+	}
+	start := len(g.Log) - n
+	if start > 0 {
+		e := g.Log[start-1]
+		for i := s.logDups; i < e.Dups; i++ {
+			obs.Messages = append(obs.Messages, e.Text)
+		}
+	}
+	for _, e := range g.Log[start:] {
+		for i := 0; i <= e.Dups; i++ {
+			obs.Messages = append(obs.Messages, e.Text)
+		}
+	}
+	s.logCount = g.LogIndex
+	s.logDups = g.Log[len(g.Log)-1].Dups
+	return obs
+}
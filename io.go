@@ -75,8 +75,20 @@ func RemoveSaveFile() error {
 	return RemoveDataFile("save")
 }
 
-func RemoveReplay() {
-	RemoveDataFile("replay.part")
+// BackupSaveFile moves the save file aside, so that a save that could not be
+// loaded can still be loaded by the version that wrote it.
+func BackupSaveFile() error {
+	dataDir, err := DataDir()
+	if err != nil {
+		return err
+	}
+	saveFile := filepath.Join(dataDir, "save")
+	return os.Rename(saveFile, saveFile+".bak")
+}
+
+// WriteReplay writes the replay of the finished game in the data directory.
+func (g *game) WriteReplay() error {
+	return SaveFile("replay", g.Replay(true).Encode())
 }
 
 func (g *game) Load() (bool, error) {
@@ -98,9 +110,6 @@ func (g *game) Load() (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	if lg.Version != Version {
-		return false, nil
-	}
 	*g = *lg
 	return true, nil
 }
@@ -155,6 +164,184 @@ func RemoveDataFile(file string) error {
 	return nil
 }
 
+// WriteState writes the JSON representation of the game state in the data
+// directory, returning the file's path.
+func (g *game) WriteState() (string, error) {
+	data, err := g.ExportState()
+	if err != nil {
+		return "", err
+	}
+	dataDir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	file := filepath.Join(dataDir, "state.json")
+	err = ioutil.WriteFile(file, data, 0644)
+	if err != nil {
+		return "", fmt.Errorf("writing game state: %v", err)
+	}
+	return file, nil
+}
+
+// WriteOverview writes the text snapshot of the level overview in the data
+// directory, returning the file's path.
+func WriteOverview(data []byte) (string, error) {
+	dataDir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	file := filepath.Join(dataDir, "overview")
+	err = ioutil.WriteFile(file, data, 0644)
+	if err != nil {
+		return "", fmt.Errorf("writing level overview: %v", err)
+	}
+	return file, nil
+}
+
+// LoadRoomTemplates loads the room templates in the .room files of the given
+// directory, adding them to the built-in ones.
+func LoadRoomTemplates(dir string) error {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	tpls := []roomTemplate{}
+	for _, fi := range fis {
+		if fi.IsDir() || filepath.Ext(fi.Name()) != ".room" {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return err
+		}
+		tpl, err := parseRoomTemplate(fi.Name(), data)
+		if err != nil {
+			return fmt.Errorf("%s: %v", fi.Name(), err)
+		}
+		tpls = append(tpls, tpl)
+	}
+	AddRoomTemplates(tpls)
+	return nil
+}
+
+// AppendScore appends an entry to the daily challenges scoreboard file in
+// the data directory.
+func AppendScore(s score) error {
+	dataDir, err := DataDir()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dataDir, "scoreboard"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f, s)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadScoreboard returns the entries of the daily challenges scoreboard
+// file in the data directory.
+func LoadScoreboard() ([]score, error) {
+	dataDir, err := DataDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dataDir, "scoreboard"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parseScoreboard(data)
+}
+
+// AppendRun appends a record to the run history file in the data directory.
+func AppendRun(r runRecord) error {
+	data, err := encodeRun(r)
+	if err != nil {
+		return err
+	}
+	dataDir, err := DataDir()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dataDir, "runs"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadRuns returns the records of the run history file in the data
+// directory.
+func LoadRuns() ([]runRecord, error) {
+	dataDir, err := DataDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dataDir, "runs"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parseRuns(data)
+}
+
+// LoadThemesFile adds the themes of the themes file in the data directory,
+// if any, to the available ones.
+func LoadThemesFile() error {
+	dataDir, err := DataDir()
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dataDir, "themes"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return LoadThemes(data)
+}
+
+// SaveBestiary writes the bestiary file in the data directory.
+func SaveBestiary(b bestiary) error {
+	data, err := encodeBestiary(b)
+	if err != nil {
+		return err
+	}
+	return SaveFile("bestiary", data)
+}
+
+// LoadBestiary returns the contents of the bestiary file in the data
+// directory.
+func LoadBestiary() (bestiary, error) {
+	dataDir, err := DataDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dataDir, "bestiary"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return bestiary{}, nil
+		}
+		return nil, err
+	}
+	return parseBestiary(data)
+}
+
 func (g *game) WriteDump() error {
 	dataDir, err := DataDir()
 	if err != nil {
@@ -164,5 +351,13 @@ func (g *game) WriteDump() error {
 	if err != nil {
 		return fmt.Errorf("writing dump statistics: %v", err)
 	}
+	data, err := g.DumpJSON()
+	if err != nil {
+		return fmt.Errorf("encoding JSON dump: %v", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dataDir, "dump.json"), data, 0644)
+	if err != nil {
+		return fmt.Errorf("writing JSON dump: %v", err)
+	}
 	return nil
 }
@@ -3,13 +3,13 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"log"
 	"syscall/js"
+	"time"
 
 	"github.com/anaseto/gruid"
 	jsd "github.com/anaseto/gruid-js"
@@ -43,6 +43,9 @@ func main() {
 	if err != nil {
 		mainMenu.err = err
 	}
+	if err := LoadThemesFile(); err != nil {
+		mainMenu.err = err
+	}
 	applyThemeConf()
 	for {
 		app := gruid.NewApp(gruid.AppConfig{
@@ -54,9 +57,14 @@ func main() {
 		}
 		switch mainMenu.action {
 		case MainPlayGame:
-			mainMenu.err = RunGame()
+			mainMenu.err = RunGame(&game{preset: mainMenu.Preset()})
+		case MainDailyGame:
+			date := dailyDate(time.Now())
+			mainMenu.err = RunGame(&game{Seed: dailySeed(date), Daily: date})
 		case MainReplayGame:
 			mainMenu.err = RunReplay()
+		case MainScoreboard:
+			mainMenu.err = RunScoreboard()
 		}
 		mainMenu.action = MainMenuDefault
 	}
@@ -67,15 +75,19 @@ type mainMenuAction int
 const (
 	MainMenuDefault mainMenuAction = iota
 	MainPlayGame
+	MainDailyGame
 	MainReplayGame
+	MainScoreboard
 )
 
 type mainMenu struct {
 	grid   gruid.Grid
 	menu   *ui.Menu
 	errs   *ui.Label
+	desc   *ui.Label
 	err    error
 	action mainMenuAction
+	preset int // index of chosen preset in gamePresets, plus one (0 means none)
 }
 
 func newMainMenu() *mainMenu {
@@ -85,17 +97,37 @@ func newMainMenu() *mainMenu {
 		Active: gruid.Style{}.WithFg(ColorYellow),
 	}
 	md.menu = ui.NewMenu(ui.MenuConfig{
-		Grid: gruid.NewGrid(UIWidth/2, 3),
-		Entries: []ui.MenuEntry{
-			{Text: ui.Text("- (P)lay"), Keys: []gruid.Key{"p", "P"}},
-			{Text: ui.Text("- (R)eplay"), Keys: []gruid.Key{"r", "R"}},
-		},
-		Style: style,
+		Grid:    gruid.NewGrid(UIWidth/2, 5),
+		Entries: md.entries(),
+		Style:   style,
 	})
 	md.errs = ui.NewLabel(ui.StyledText{}.WithStyle(gruid.Style{}.WithFg(ColorRed)))
+	md.desc = ui.NewLabel(ui.StyledText{})
 	return md
 }
 
+func (md *mainMenu) entries() []ui.MenuEntry {
+	mode := "default"
+	if p := md.Preset(); p != nil {
+		mode = p.Name
+	}
+	return []ui.MenuEntry{
+		{Text: ui.Text("- (P)lay"), Keys: []gruid.Key{"p", "P"}},
+		{Text: ui.Text("- (D)aily challenge"), Keys: []gruid.Key{"d", "D"}},
+		{Text: ui.Text("- (R)eplay"), Keys: []gruid.Key{"r", "R"}},
+		{Text: ui.Text("- (S)coreboard"), Keys: []gruid.Key{"s", "S"}},
+		{Text: ui.Text("- (M)ode: " + mode), Keys: []gruid.Key{"m", "M"}},
+	}
+}
+
+// Preset returns the game preset chosen for new games, if any.
+func (md *mainMenu) Preset() *gamePreset {
+	if md.preset == 0 {
+		return nil
+	}
+	return gamePresets[md.preset-1]
+}
+
 func menuRange() gruid.Range {
 	return gruid.NewRange(20, 18, UIWidth, UIHeight)
 }
@@ -113,8 +145,18 @@ func (md *mainMenu) Update(msg gruid.Msg) gruid.Effect {
 			md.action = MainPlayGame
 			return gruid.End()
 		case 1:
+			md.action = MainDailyGame
+			return gruid.End()
+		case 2:
 			md.action = MainReplayGame
 			return gruid.End()
+		case 3:
+			md.action = MainScoreboard
+			return gruid.End()
+		case 4:
+			md.preset = (md.preset + 1) % (len(gamePresets) + 1)
+			md.menu.SetEntries(md.entries())
+			md.menu.SetActive(4)
 		}
 	}
 	return nil
@@ -124,6 +166,10 @@ func (md *mainMenu) Draw() gruid.Grid {
 	md.grid.Fill(gruid.Cell{Rune: ' '})
 	drawWelcome(md.grid)
 	md.grid.Slice(menuRange()).Copy(md.menu.Draw())
+	if p := md.Preset(); p != nil {
+		md.desc.SetText(p.Desc)
+		md.desc.Draw(md.grid.Slice(gruid.NewRange(20, 23, UIWidth, 24)))
+	}
 	if md.err != nil {
 		md.errs.SetText(md.err.Error())
 		md.errs.Draw(md.grid.Slice(gruid.NewRange(10, 4, UIWidth, 6)))
@@ -132,73 +178,54 @@ func (md *mainMenu) Draw() gruid.Grid {
 }
 
 const repit = "harmonistreplay"
-const replock = "harmonistreplock"
 
-func RunGame() error {
+// RunGame plays the given new game, unless there is a saved game, which is
+// continued instead.
+func RunGame(g *game) error {
 	gd := gruid.NewGrid(UIWidth, UIHeight)
-	m := &model{gd: gd, g: &game{}}
-	repw := &bytes.Buffer{}
-	defer func() {
-		if m.finished {
-			SetItem(repit, repw.Bytes())
-			RemoveItem(replock)
-			RemoveSaveFile()
-		} else {
-			rl, err := GetItem(replock)
-			if err != nil {
-				log.Printf("replock: %v", err)
-			}
-			if rl != nil {
-				// save ongoing game replay
-				SetItem(repit, repw.Bytes())
-			}
-		}
-	}()
-	rl, err := GetItem(replock)
-	if rl == nil {
-		if err != nil {
-			log.Printf("replock: %v", err)
-		}
-		// new game: remove old replay
-		RemoveReplay()
-	}
-	replay, err := GetItem(repit)
-	if err != nil {
-		log.Printf("get replay: %v", err)
-	} else if replay != nil {
-		repw.Write(replay)
-	}
+	m := &model{gd: gd, g: g}
 	app := gruid.NewApp(gruid.AppConfig{
-		Driver:      driver,
-		Model:       m,
-		FrameWriter: repw,
+		Driver: driver,
+		Model:  m,
 	})
-	SetItem(replock, []byte("1"))
-	return app.Start(context.Background())
+	err := app.Start(context.Background())
+	if m.finished {
+		RemoveSaveFile()
+	}
+	if m.err != nil {
+		return m.err
+	}
+	return err
 }
 
 func RunReplay() error {
-	replay, err := GetItem(repit)
+	data, err := GetItem(repit)
 	if err != nil {
 		return fmt.Errorf("replay loading: %v", err)
 	}
-	if replay == nil {
+	if data == nil {
 		return errors.New("no replay found")
 	}
-	repr := &bytes.Buffer{}
-	repr.Write(replay)
-	fd, err := gruid.NewFrameDecoder(repr)
+	v, err := newReplayViewer(data)
 	if err != nil {
-		return fmt.Errorf("frame decoder: %v", err)
+		return fmt.Errorf("replay: %v", err)
 	}
-	gd := gruid.NewGrid(UIWidth, UIHeight)
-	rep := ui.NewReplay(ui.ReplayConfig{
-		Grid:         gd,
-		FrameDecoder: fd,
+	app := gruid.NewApp(gruid.AppConfig{
+		Driver: driver,
+		Model:  v,
 	})
+	return app.Start(context.Background())
+}
+
+// RunScoreboard shows the daily challenges scoreboard.
+func RunScoreboard() error {
+	v, err := newScoreboardViewer()
+	if err != nil {
+		return fmt.Errorf("scoreboard: %v", err)
+	}
 	app := gruid.NewApp(gruid.AppConfig{
 		Driver: driver,
-		Model:  rep,
+		Model:  v,
 	})
 	return app.Start(context.Background())
 }
@@ -282,9 +309,98 @@ func RemoveSaveFile() error {
 	return nil
 }
 
-func RemoveReplay() {
-	RemoveItem(replock)
-	RemoveItem(repit)
+// BackupSaveFile keeps a copy of the save, so that a save that could not be
+// loaded can still be loaded by the version that wrote it.
+func BackupSaveFile() error {
+	s, err := GetItem(harmonistsave)
+	if err != nil || s == nil {
+		return err
+	}
+	return SetItem(harmonistsave+"bak", s)
+}
+
+// WriteReplay stores the replay of the finished game.
+func (g *game) WriteReplay() error {
+	return SetItem(repit, g.Replay(true).Encode())
+}
+
+const harmonistscoreboard = "harmonistscoreboard"
+
+// AppendScore appends an entry to the daily challenges scoreboard.
+func AppendScore(s score) error {
+	data, err := GetItem(harmonistscoreboard)
+	if err != nil {
+		return err
+	}
+	data = append(data, []byte(s.String()+"\n")...)
+	return SetItem(harmonistscoreboard, data)
+}
+
+// LoadScoreboard returns the entries of the daily challenges scoreboard.
+func LoadScoreboard() ([]score, error) {
+	data, err := GetItem(harmonistscoreboard)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return parseScoreboard(data)
+}
+
+const harmonistruns = "harmonistruns"
+
+// AppendRun appends a record to the run history.
+func AppendRun(r runRecord) error {
+	line, err := encodeRun(r)
+	if err != nil {
+		return err
+	}
+	data, err := GetItem(harmonistruns)
+	if err != nil {
+		return err
+	}
+	return SetItem(harmonistruns, append(data, line...))
+}
+
+// LoadRuns returns the records of the run history.
+func LoadRuns() ([]runRecord, error) {
+	data, err := GetItem(harmonistruns)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return parseRuns(data)
+}
+
+const harmonistbestiary = "harmonistbestiary"
+
+const harmonistthemes = "harmonistthemes"
+
+// LoadThemesFile adds the stored themes, if any, to the available ones.
+func LoadThemesFile() error {
+	data, err := GetItem(harmonistthemes)
+	if err != nil || data == nil {
+		return err
+	}
+	return LoadThemes(data)
+}
+
+// SaveBestiary stores the bestiary.
+func SaveBestiary(b bestiary) error {
+	data, err := encodeBestiary(b)
+	if err != nil {
+		return err
+	}
+	return SetItem(harmonistbestiary, data)
+}
+
+// LoadBestiary returns the stored bestiary.
+func LoadBestiary() (bestiary, error) {
+	data, err := GetItem(harmonistbestiary)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return bestiary{}, nil
+	}
+	return parseBestiary(data)
 }
 
 func (g *game) Load() (bool, error) {
@@ -296,9 +412,6 @@ func (g *game) Load() (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	if lg.Version != Version {
-		return false, nil
-	}
 	*g = *lg
 	return true, nil
 }
@@ -319,6 +432,26 @@ func LoadConfig() (bool, error) {
 	return true, nil
 }
 
+// WriteState writes the JSON representation of the game state in the
+// page's dump element.
+func (g *game) WriteState() (string, error) {
+	data, err := g.ExportState()
+	if err != nil {
+		return "", err
+	}
+	pre := js.Global().Get("document").Call("getElementById", "dump")
+	pre.Set("innerHTML", string(data))
+	return "", nil
+}
+
+// WriteOverview writes the text snapshot of the level overview in the page's
+// dump element.
+func WriteOverview(data []byte) (string, error) {
+	pre := js.Global().Get("document").Call("getElementById", "dump")
+	pre.Set("innerHTML", string(data))
+	return "", nil
+}
+
 func (g *game) WriteDump() error {
 	pre := js.Global().Get("document").Call("getElementById", "dump")
 	pre.Set("innerHTML", g.Dump())
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/anaseto/gruid"
+	"github.com/anaseto/gruid/rl"
+)
+
+// level is a hand-made level, parsed from a text file by parseLevel. Such a
+// level replaces the generated one at its depth when starting a new game.
+//
+// A level file is made of an optional header, followed by a line containing
+// only "---", and then the map. Header lines can be:
+//
+//	depth N                      depth of the level (default 1)
+//	legend R monster NAME [asleep]
+//	legend R magara NAME
+//	legend R stone NAME
+//	legend R item NAME
+//
+// The legend lines define rune R in the map as a monster, magara, stone or
+// item, with NAME as shown in game, ignoring case (for example "legend X tree
+// mushroom" or "legend / magara magara of blinking"). Monsters are awake
+// unless asleep is given.
+//
+// The map has at most DungeonHeight lines of at most DungeonWidth runes,
+// missing cells being walls. It uses the same vocabulary as room templates
+// ('#' wall, '.' ground, 'T' tree, 'π' table, 'l' light, 'W' window, '"'
+// foliage, ',' cavern ground, '~' water, 'c' chasm, 'q' queen rock, with the
+// place markers '_', '!', '|' and '-' as ground), as well as the game's
+// display runes ('+' door, '&' barrel, ')' banana, '>' stairs, '♣' tree, '☼'
+// light, '○' extinguished light, '◊' chasm, '≈' water, 'Θ' window, 'Π' holed
+// wall, '‗' queen rock, '^' rubble). Monster letters, as shown in game, place
+// monsters, except for letters already used for terrain ('T', 'W' and 'c'),
+// which need a legend line. Exactly one '@' gives the player's position.
+type level struct {
+	Depth  int
+	Map    []string
+	Legend map[rune]levelEntity
+	player gruid.Point
+}
+
+// levelEntity describes what a rune represents in a level map.
+type levelEntity struct {
+	Terrain cell
+	Monster monsterKind
+	Magara  magaraKind
+	Stone   stone
+	Item    item
+	Asleep  bool
+	kind    levelEntityKind
+}
+
+type levelEntityKind int
+
+const (
+	levelTerrain levelEntityKind = iota
+	levelMonster
+	levelMagara
+	levelStone
+	levelItem
+	levelPlayer
+)
+
+// levelTerrains is the default terrain vocabulary of level maps.
+var levelTerrains = map[rune]cell{
+	'#': WallCell,
+	'.': GroundCell,
+	'_': GroundCell,
+	'!': GroundCell,
+	'|': GroundCell,
+	'-': GroundCell,
+	'T': TreeCell,
+	'♣': TreeCell,
+	'π': TableCell,
+	'l': LightCell,
+	'☼': LightCell,
+	'○': ExtinguishedLightCell,
+	'W': WindowCell,
+	'Θ': WindowCell,
+	'"': FoliageCell,
+	',': CavernCell,
+	'~': WaterCell,
+	'≈': WaterCell,
+	'c': ChasmCell,
+	'◊': ChasmCell,
+	'q': QueenRockCell,
+	'‗': QueenRockCell,
+	'+': DoorCell,
+	'&': BarrelCell,
+	')': BananaCell,
+	'>': StairCell,
+	'Π': HoledWallCell,
+	'^': RubbleCell,
+}
+
+// defaultLevelLegend returns the default legend of level maps.
+func defaultLevelLegend() map[rune]levelEntity {
+	legend := map[rune]levelEntity{'@': {kind: levelPlayer, Terrain: GroundCell}}
+	for r, c := range levelTerrains {
+		legend[r] = levelEntity{kind: levelTerrain, Terrain: c}
+	}
+	for mk, data := range MonsData {
+		if _, ok := legend[data.Letter]; ok {
+			continue
+		}
+		legend[data.Letter] = levelEntity{kind: levelMonster, Monster: monsterKind(mk), Terrain: GroundCell}
+	}
+	return legend
+}
+
+// parseLevel parses a level file, as described in level.
+func parseLevel(data []byte) (*level, error) {
+	lv := &level{Depth: 1, Legend: defaultLevelLegend(), player: invalidPos}
+	lines := []string{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		lines = append(lines, strings.TrimRight(sc.Text(), "\r"))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for i, l := range lines {
+		if l != "---" {
+			continue
+		}
+		for j, hl := range lines[:i] {
+			if err := lv.parseHeaderLine(hl); err != nil {
+				return nil, fmt.Errorf("line %d: %v", j+1, err)
+			}
+		}
+		lines = lines[i+1:]
+		break
+	}
+	if len(lines) > DungeonHeight {
+		return nil, fmt.Errorf("map has %d lines (maximum %d)", len(lines), DungeonHeight)
+	}
+	for y, l := range lines {
+		x := 0
+		for _, r := range l {
+			if x >= DungeonWidth {
+				return nil, fmt.Errorf("map line %d longer than %d", y+1, DungeonWidth)
+			}
+			e, ok := lv.Legend[r]
+			if !ok {
+				return nil, fmt.Errorf("map line %d, column %d: unknown rune %q", y+1, x+1, r)
+			}
+			if e.kind == levelPlayer {
+				if lv.player != invalidPos {
+					return nil, fmt.Errorf("map line %d, column %d: more than one player position", y+1, x+1)
+				}
+				lv.player = gruid.Point{x, y}
+			}
+			x++
+		}
+	}
+	if lv.player == invalidPos {
+		return nil, errors.New("no player position '@' in map")
+	}
+	lv.Map = lines
+	return lv, nil
+}
+
+func (lv *level) parseHeaderLine(l string) error {
+	fields := strings.Fields(l)
+	if len(fields) == 0 {
+		return nil
+	}
+	switch fields[0] {
+	case "depth":
+		if len(fields) != 2 {
+			return errors.New("bad depth line")
+		}
+		depth, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return err
+		}
+		if depth < 1 || depth > MaxDepth {
+			return fmt.Errorf("depth out of range: %d", depth)
+		}
+		lv.Depth = depth
+	case "legend":
+		if len(fields) < 4 || len([]rune(fields[1])) != 1 {
+			return errors.New("bad legend line")
+		}
+		r := []rune(fields[1])[0]
+		e, err := parseLevelEntity(fields[2], fields[3:])
+		if err != nil {
+			return err
+		}
+		lv.Legend[r] = e
+	default:
+		return fmt.Errorf("unknown header line: %s", fields[0])
+	}
+	return nil
+}
+
+func parseLevelEntity(kind string, words []string) (levelEntity, error) {
+	e := levelEntity{Terrain: GroundCell}
+	if kind == "monster" && words[len(words)-1] == "asleep" && len(words) > 1 {
+		e.Asleep = true
+		words = words[:len(words)-1]
+	}
+	name := strings.Join(words, " ")
+	switch kind {
+	case "monster":
+		e.kind = levelMonster
+		for mk, data := range MonsData {
+			if strings.EqualFold(data.Name, name) {
+				e.Monster = monsterKind(mk)
+				return e, nil
+			}
+		}
+	case "magara":
+		e.kind = levelMagara
+		e.Terrain = MagaraCell
+		for _, k := range magaraKinds() {
+			if strings.EqualFold((magara{Kind: k}).String(), name) {
+				e.Magara = k
+				return e, nil
+			}
+		}
+	case "stone":
+		e.kind = levelStone
+		e.Terrain = StoneCell
+		for stn := InertStone; stn <= SealStone; stn++ {
+			if strings.EqualFold(stn.String(), name) {
+				e.Stone = stn
+				return e, nil
+			}
+		}
+	case "item":
+		e.kind = levelItem
+		e.Terrain = ItemCell
+		for it := CloakMagic; it <= AmuletObstruction; it++ {
+			if strings.EqualFold(it.String(), name) {
+				e.Item = it
+				return e, nil
+			}
+		}
+	default:
+		return e, fmt.Errorf("unknown legend kind: %s", kind)
+	}
+	return e, fmt.Errorf("unknown %s: %s", kind, name)
+}
+
+// GenLevel builds the dungeon level from a hand-made level, instead of
+// generating it.
+func (g *game) GenLevel(lv *level) {
+	d := &dungeon{}
+	d.Grid = rl.NewGrid(DungeonWidth, DungeonHeight)
+	d.Grid.Fill(rl.Cell(WallCell))
+	g.Dungeon = d
+	g.Monsters = []*monster{}
+	g.Bands = []bandInfo{}
+	g.Objects.Stones = map[gruid.Point]stone{}
+	g.Objects.Story = map[gruid.Point]story{}
+	g.Player.P = lv.player
+	for y, l := range lv.Map {
+		x := 0
+		for _, r := range l {
+			p := gruid.Point{x, y}
+			x++
+			e := lv.Legend[r]
+			d.SetCell(p, e.Terrain)
+			switch e.kind {
+			case levelTerrain:
+				switch e.Terrain {
+				case StairCell:
+					st := NormalStair
+					if g.Depth == MaxDepth {
+						st = WinStair
+					}
+					g.Objects.Stairs[p] = st
+				case BarrelCell:
+					g.Objects.Barrels[p] = true
+				case BananaCell:
+					g.Objects.Bananas[p] = true
+				case LightCell:
+					g.Objects.Lights[p] = true
+				case ExtinguishedLightCell:
+					g.Objects.Lights[p] = false
+				}
+			case levelMagara:
+				g.Objects.Magaras[p] = magara{Kind: e.Magara, Charges: e.Magara.DefaultCharges()}
+			case levelStone:
+				g.Objects.Stones[p] = e.Stone
+			case levelItem:
+				g.Objects.Items[p] = e.Item
+			case levelMonster:
+				g.putLevelMonster(e, p)
+			}
+		}
+	}
+	g.ComputeLights()
+}
+
+// putLevelMonster places a monster of a hand-made level, as a lone guard
+// band.
+func (g *game) putLevelMonster(e levelEntity, p gruid.Point) {
+	g.Bands = append(g.Bands, bandInfo{Kind: e.Monster.loneBand(), Path: []gruid.Point{p}, Beh: BehGuard})
+	mons := &monster{Kind: e.Monster}
+	if !e.Asleep {
+		mons.State = Wandering
+	}
+	g.Monsters = append(g.Monsters, mons)
+	mons.Init(g)
+	mons.Index = len(g.Monsters) - 1
+	mons.Band = len(g.Bands) - 1
+	mons.PlaceAtStart(g, p)
+	mons.Target = mons.NextTarget(g)
+}
@@ -114,7 +114,7 @@ func (g *game) StoryPrintf(format string, a ...interface{}) {
 }
 
 func (g *game) CrackSound() (text string) {
-	switch RandInt(4) {
+	switch g.randInt(4) {
 	case 0:
 		text = "Crack!"
 	case 1:
@@ -128,7 +128,7 @@ func (g *game) CrackSound() (text string) {
 }
 
 func (g *game) ExplosionSound() (text string) {
-	switch RandInt(3) {
+	switch g.randInt(3) {
 	case 0:
 		text = "Bang!"
 	case 1:
@@ -232,7 +232,7 @@ func (g *game) ComputeLOS() {
 			g.Player.LOS[n.P] = true
 		}
 	}
-	for p := range g.Player.LOS {
+	for _, p := range sortedPoints(g.Player.LOS) {
 		if g.Player.Sees(p) {
 			g.SeePosition(p)
 		}
@@ -245,7 +245,7 @@ func (g *game) ComputeLOS() {
 				g.StopAuto()
 				continue
 			}
-			mons.Seen = true
+			mons.markSeen(g)
 			g.Printf("You see %s (%v).", mons.Kind.Indefinite(false), mons.State)
 			if mons.Kind.Notable() {
 				g.StoryPrintf("Saw %s", mons.Kind)
@@ -450,7 +450,7 @@ func (g *game) ComputeNoise() {
 		}
 		mons := g.MonsterAt(n.P)
 		if mons.Exists() && mons.State != Resting && mons.State != Watching &&
-			(RandInt(rmax) > 0 || terrain(g.Dungeon.Cell(mons.P)) == QueenRockCell) {
+			(g.randInt(rmax) > 0 || terrain(g.Dungeon.Cell(mons.P)) == QueenRockCell) {
 			switch mons.Kind {
 			case MonsMirrorSpecter, MonsSatowalgaPlant, MonsButterfly:
 				if mons.Kind == MonsMirrorSpecter && g.Player.Inventory.Body == CloakHear {
@@ -481,7 +481,7 @@ func (g *game) ComputeNoise() {
 			}
 		}
 	}
-	if count > 0 {
+	if count > 0 && !g.autoWaitBehindDoor() {
 		g.StopAuto()
 	}
 }
@@ -571,8 +571,8 @@ func (g *game) ComputeLights() {
 		g.LightFOV = rl.NewFOV(gruid.NewRange(0, 0, DungeonWidth, DungeonHeight))
 	}
 	sources := []gruid.Point{}
-	for lpos, on := range g.Objects.Lights {
-		if !on {
+	for _, lpos := range sortedPoints(g.Objects.Lights) {
+		if !g.Objects.Lights[lpos] {
 			continue
 		}
 		if distance(lpos, g.Player.P) > DefaultLOSRange+LightRange && terrain(g.Dungeon.Cell(g.Player.P)) != TreeCell {
@@ -593,6 +593,14 @@ func (g *game) ComputeLights() {
 	g.LightFOV.LightMap(lt, sources)
 }
 
+// ComputeMapInfo updates noise, player's LOS and monsters' LOS information
+// after a player's turn.
+func (g *game) ComputeMapInfo() {
+	g.ComputeNoise()
+	g.ComputeLOS()
+	g.ComputeMonsterLOS()
+}
+
 func (g *game) ComputeMonsterCone(m *monster) {
 	g.MonsterTargLOS = make(map[gruid.Point]bool)
 	for p := range g.Player.LOS {
@@ -605,6 +613,24 @@ func (g *game) ComputeMonsterCone(m *monster) {
 	}
 }
 
+// MonsterVision returns the cells in the player's field of view that are seen
+// by the given monsters. The value of a cell is true if one of the monsters
+// seeing it is hunting.
+func (g *game) MonsterVision(ms []*monster) map[gruid.Point]bool {
+	vision := map[gruid.Point]bool{}
+	for p := range g.Player.LOS {
+		if !g.Player.Sees(p) {
+			continue
+		}
+		for _, m := range ms {
+			if m.Sees(g, p) {
+				vision[p] = vision[p] || m.State == Hunting
+			}
+		}
+	}
+	return vision
+}
+
 func (m *monster) UpdateKnowledge(g *game, p gruid.Point) {
 	if mons := g.lastMonsterKnownAt(p); mons.Exists() {
 		if mons.Index != m.Index {
@@ -617,6 +643,14 @@ func (m *monster) UpdateKnowledge(g *game, p gruid.Point) {
 	g.LastMonsterKnownAt[p] = m.Index
 	m.LastKnownState = m.State
 	m.LastKnownPos = p
+	if g.Player.Sees(m.P) {
+		m.LastKnownDir = m.Dir
+		m.LastKnownPath = append(m.LastKnownPath[:0], m.Path...)
+	} else {
+		// facing and path are unknown for monsters out of view
+		m.LastKnownDir = ZP
+		m.LastKnownPath = nil
+	}
 }
 
 func (g *game) lastMonsterKnownAt(p gruid.Point) *monster {
@@ -41,79 +41,368 @@ const (
 	//BarrierMagara
 )
 
-func (mag magara) Harmonic() bool {
-	switch mag.Kind {
-	case FogMagara, ShadowsMagara, NoiseMagara, ConfusionMagara,
-		SleepingMagara, ParalysisMagara, TransparencyMagara, DisguiseMagara,
-		DelayedNoiseMagara:
-		return true
-	default:
-		return false
+// magaraSchool is the kind of magic energy used by a magara.
+type magaraSchool int
+
+const (
+	NoSchool magaraSchool = iota
+	HarmonicSchool
+	OricSchool
+)
+
+// magaraTargeting describes what a magara affects.
+type magaraTargeting int
+
+const (
+	TargetSelf             magaraTargeting = iota // the player or its position
+	TargetAdjacent                                // cells adjacent to the player
+	TargetArea                                    // cells or monsters in an area around the player
+	TargetMonstersInSight                         // monsters in sight
+	TargetCardinalMonsters                        // monsters in sight in cardinal directions
+)
+
+// magaraData is the definition of a magara kind, as registered with
+// registerMagara.
+type magaraData struct {
+	Name        string
+	School      magaraSchool
+	Charges     int // default charges
+	MPCost      int
+	Targeting   magaraTargeting
+	Duration    int  // duration of the effect, if any
+	Delayed     bool // the duration is a delay before the effect
+	Teleport    bool // counts as a teleportation magara for achievements
+	Weight      int  // relative frequency in the dungeon (0 means never)
+	StartWeight int  // relative frequency as starting magara (0 means never)
+	Effect      func(*game) error
+	Desc        string // description, following "The <name> "
+}
+
+// magaraRegistry contains the definitions of magaras, indexed by kind.
+var magaraRegistry []magaraData
+
+// registerMagara registers the definition of a magara kind. New magaras
+// only need a new magaraKind value and a call to registerMagara in init.
+func registerMagara(kind magaraKind, data magaraData) {
+	for int(kind) >= len(magaraRegistry) {
+		magaraRegistry = append(magaraRegistry, magaraData{})
 	}
+	magaraRegistry[kind] = data
 }
 
-func (mag magara) Oric() bool {
-	switch mag.Kind {
-	case BlinkMagara, DigMagara, TeleportMagara, LevitationMagara,
-		TeleportOtherMagara, SwappingMagara, ObstructionMagara,
-		DispersalMagara, DelayedOricExplosionMagara:
-		return true
-	default:
-		return false
+func init() {
+	registerMagara(NoMagara, magaraData{
+		Name: "empty slot",
+		Desc: "can be used for a new magara.",
+	})
+	registerMagara(BlinkMagara, magaraData{
+		Name:        "magara of blinking",
+		School:      OricSchool,
+		Charges:     4,
+		MPCost:      1,
+		Teleport:    true,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeBlink,
+		Desc:        "makes you blink away within your line of sight by using an oric energy disturbance. The magara is more susceptible to send you to the cells that are most far from you.",
+	})
+	registerMagara(DigMagara, magaraData{
+		Name:        "magara of digging",
+		School:      OricSchool,
+		Charges:     4,
+		MPCost:      1,
+		Duration:    DurationDigging,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeDig,
+		Desc:        "makes you dig walls by walking into them like an earth dragon thanks to destructive oric magic.",
+	})
+	registerMagara(TeleportMagara, magaraData{
+		Name:        "magara of teleportation",
+		School:      OricSchool,
+		Charges:     4,
+		MPCost:      1,
+		Teleport:    true,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeTeleport,
+		Desc:        "creates an oric energy disturbance, making you teleport far away on the same level.",
+	})
+	registerMagara(SwiftnessMagara, magaraData{
+		Name:        "magara of swiftness",
+		Charges:     4,
+		MPCost:      1,
+		Duration:    DurationSwiftness,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeSwiftness,
+		Desc:        "makes you able to move several times in a row for free.",
+	})
+	registerMagara(LevitationMagara, magaraData{
+		Name:        "magara of levitation",
+		School:      OricSchool,
+		Charges:     6,
+		MPCost:      1,
+		Duration:    DurationLevitation,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeLevitation,
+		Desc:        "makes you levitate with oric energies, allowing you to move over chasms, as well as through oric barriers.",
+	})
+	registerMagara(FireMagara, magaraData{
+		Name:        "magara of fire",
+		Charges:     6,
+		MPCost:      1,
+		Targeting:   TargetAdjacent,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeFire,
+		Desc:        "throws small magical sparks at flammable terrain adjacent to you. Flammable terrain is first consumed by magical flames that are by themselves harmless to creatures. Then smoke will produce night clouds inducing sleep and confusion in monsters. As a gawalt monkey, you resist sleepiness, but you will still feel confused. The fire does often expand to other adjacent flammable terrain.",
+	})
+	registerMagara(FogMagara, magaraData{
+		Name:        "magara of fog",
+		School:      HarmonicSchool,
+		Charges:     6,
+		MPCost:      1,
+		Targeting:   TargetArea,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeFog,
+		Desc:        "creates a dense fog in a 2-range radius using harmonic energies. The fog will dissipate with time.",
+	})
+	registerMagara(ShadowsMagara, magaraData{
+		Name:        "magara of shadows",
+		School:      HarmonicSchool,
+		Charges:     5,
+		MPCost:      1,
+		Duration:    DurationShadows,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeShadows,
+		Desc:        "surrounds you by harmonic shadows. While standing on a dark cell, only adjacent monsters will be able to see you. It does not affect your visibility on lighted cells.",
+	})
+	registerMagara(NoiseMagara, magaraData{
+		Name:        "magara of noise",
+		School:      HarmonicSchool,
+		Charges:     6,
+		MPCost:      1,
+		Targeting:   TargetArea,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeNoise,
+		Desc:        "tricks monsters in a 12-range area with harmonic magical sounds, making them go away from you for a few turns. The possible monster destinations will be marked with noise symbols in the map. It only works on monsters that are not already seeing you.",
+	})
+	registerMagara(ConfusionMagara, magaraData{
+		Name:        "magara of confusion",
+		School:      HarmonicSchool,
+		Charges:     4,
+		MPCost:      1,
+		Targeting:   TargetMonstersInSight,
+		Duration:    DurationConfusionMonster,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeConfusion,
+		Desc:        "confuses monsters in sight with harmonic light and sounds, leaving them unable to attack you.",
+	})
+	registerMagara(SleepingMagara, magaraData{
+		Name:        "magara of sleeping",
+		School:      HarmonicSchool,
+		Charges:     4,
+		MPCost:      1,
+		Targeting:   TargetCardinalMonsters,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeSleeping,
+		Desc:        "induces deep sleeping and exhaustion for up to three random monsters you see in cardinal directions using hypnotic illusions.",
+	})
+	registerMagara(TeleportOtherMagara, magaraData{
+		Name:        "magara of teleport other",
+		School:      OricSchool,
+		Charges:     4,
+		MPCost:      1,
+		Targeting:   TargetCardinalMonsters,
+		Teleport:    true,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeTeleportOther,
+		Desc:        "creates oric energy disturbances, teleporting up to two random monsters you see in cardinal directions.",
+	})
+	registerMagara(SwappingMagara, magaraData{
+		Name:        "magara of swapping",
+		School:      OricSchool,
+		Charges:     4,
+		MPCost:      1,
+		Targeting:   TargetMonstersInSight,
+		Teleport:    true,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeSwapping,
+		Desc:        "makes you swap positions with the farthest monster in sight. If there is more than one at the same distance, it will be chosen randomly.",
+	})
+	registerMagara(ParalysisMagara, magaraData{
+		Name:        "magara of paralysis",
+		School:      HarmonicSchool,
+		Charges:     5,
+		MPCost:      1,
+		Targeting:   TargetMonstersInSight,
+		Duration:    DurationParalysisMonster,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeParalysis,
+		Desc:        "makes monsters in sight unable to act by disturbing their senses with sound and light illusions.",
+	})
+	registerMagara(ObstructionMagara, magaraData{
+		Name:        "magara of obstruction",
+		School:      OricSchool,
+		Charges:     5,
+		MPCost:      1,
+		Targeting:   TargetMonstersInSight,
+		Duration:    DurationMagicalBarrier,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeObstruction,
+		Desc:        "creates temporal barriers with oric energy between you and monsters in sight.",
+	})
+	registerMagara(LignificationMagara, magaraData{
+		Name:        "magara of lignification",
+		Charges:     4,
+		MPCost:      1,
+		Targeting:   TargetMonstersInSight,
+		Duration:    DurationLignificationMonster,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeLignification,
+		Desc:        "liberates magical spores that lignify up to 2 monsters in view, so that they cannot move. The monsters can still fight.",
+	})
+	registerMagara(EnergyMagara, magaraData{
+		Name:    "magara of energy",
+		Charges: 1,
+		Weight:  1,
+		Effect:  (*game).EvokeEnergyMagara,
+		Desc:    "replenishes your MP and HP.",
+	})
+	registerMagara(TransparencyMagara, magaraData{
+		Name:        "magara of transparency",
+		School:      HarmonicSchool,
+		Charges:     5,
+		MPCost:      1,
+		Duration:    DurationTransparency,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeTransparencyMagara,
+		Desc:        "feeds surrounding light to harmonic magic to make you transparent. When standing on a lighted cell, only adjacent monsters will be able to see you. It does not affect your visibility on dark cells.",
+	})
+	registerMagara(DisguiseMagara, magaraData{
+		Name:        "magara of disguise",
+		School:      HarmonicSchool,
+		Charges:     4,
+		MPCost:      1,
+		Duration:    DurationDisguise,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeDisguiseMagara,
+		Desc:        "surrounds you with harmonic illusions that make you look like a guard. As a result, most monsters will ignore you. Monsters with good flair may see through the illusions at less than 3 tiles away. Monsters that are already hunting you will continue doing so.",
+	})
+	registerMagara(DelayedNoiseMagara, magaraData{
+		Name:        "magara of delayed noise",
+		School:      HarmonicSchool,
+		Charges:     6,
+		MPCost:      1,
+		Duration:    DurationHarmonicNoiseDelay,
+		Delayed:     true,
+		Weight:      1,
+		StartWeight: 1,
+		Effect:      (*game).EvokeDelayedNoiseMagara,
+		Desc:        "will produce a thunderous harmonic noise in your current gruid.Point. The noise will happen after a delay.",
+	})
+	registerMagara(DispersalMagara, magaraData{
+		Name:     "magara of dispersal",
+		School:   OricSchool,
+		Charges:  5,
+		MPCost:   1,
+		Teleport: true,
+		Effect:   (*game).EvokeDispersalMagara,
+		Desc:     "will make monsters that attempt to hit you blink away.",
+	})
+	registerMagara(DelayedOricExplosionMagara, magaraData{
+		Name:     "magara of oric explosion",
+		School:   OricSchool,
+		Charges:  5,
+		MPCost:   1,
+		Duration: DurationOricExplosionDelay,
+		Delayed:  true,
+		Effect:   (*game).EvokeOricExplosionMagara,
+		Desc:     "will produce a big rock-destroying oric explosion at your current gruid.Point. The explosion will happen after a delay. It destroys only walls.",
+	})
+}
+
+// magaraKinds returns the registered magara kinds, in order, without
+// NoMagara.
+func magaraKinds() []magaraKind {
+	kinds := []magaraKind{}
+	for i, data := range magaraRegistry {
+		if magaraKind(i) != NoMagara && data.Name != "" {
+			kinds = append(kinds, magaraKind(i))
+		}
 	}
+	return kinds
+}
+
+func (m magaraKind) data() *magaraData {
+	return &magaraRegistry[m]
+}
+
+func (mag magara) Harmonic() bool {
+	return mag.Kind.data().School == HarmonicSchool
+}
+
+func (mag magara) Oric() bool {
+	return mag.Kind.data().School == OricSchool
 }
 
 func (m magaraKind) DefaultCharges() int {
-	switch m {
-	case LevitationMagara, FogMagara, NoiseMagara, FireMagara, DelayedNoiseMagara:
-		return 6
-	case ParalysisMagara, ShadowsMagara, ObstructionMagara, TransparencyMagara, DispersalMagara, DelayedOricExplosionMagara:
-		return 5
-	case EnergyMagara:
-		return 1
-	default:
-		return 4
-	}
+	return m.data().Charges
 }
 
 func (g *game) RandomStartingMagara() magara {
-	mags := []magaraKind{BlinkMagara, DigMagara, TeleportMagara,
-		SwiftnessMagara, LevitationMagara, FireMagara, FogMagara,
-		ShadowsMagara, NoiseMagara, ConfusionMagara, SleepingMagara,
-		TeleportOtherMagara, SwappingMagara, ParalysisMagara,
-		ObstructionMagara, LignificationMagara, TransparencyMagara,
-		DelayedNoiseMagara, DisguiseMagara}
-	var mag magaraKind
-loop:
-	for {
-		mag = mags[RandInt(len(mags))]
-		for _, m := range g.GeneratedMagaras {
-			if m == mag {
-				continue loop
-			}
-		}
-		break
-	}
-	return magara{Kind: mag, Charges: mag.DefaultCharges()}
+	return g.randomMagara(func(data *magaraData) int { return data.StartWeight })
 }
 
 func (g *game) RandomMagara() magara {
-	mags := []magaraKind{BlinkMagara, DigMagara, TeleportMagara,
-		SwiftnessMagara, LevitationMagara, FireMagara, FogMagara,
-		ShadowsMagara, NoiseMagara, ConfusionMagara, SleepingMagara,
-		TeleportOtherMagara, SwappingMagara, ParalysisMagara,
-		ObstructionMagara, LignificationMagara, TransparencyMagara,
-		DelayedNoiseMagara, DisguiseMagara, EnergyMagara}
-	var mag magaraKind
+	return g.randomMagara(func(data *magaraData) int { return data.Weight })
+}
+
+// randomMagara returns a random magara among those not yet generated, using
+// the given weights.
+func (g *game) randomMagara(weight func(*magaraData) int) magara {
+	kinds := []magaraKind{}
+	total := 0
 loop:
-	for {
-		mag = mags[RandInt(len(mags))]
+	for _, mk := range magaraKinds() {
+		if weight(mk.data()) <= 0 {
+			continue
+		}
 		for _, m := range g.GeneratedMagaras {
-			if m == mag {
+			if m == mk {
 				continue loop
 			}
 		}
-		break
+		kinds = append(kinds, mk)
+		total += weight(mk.data())
+	}
+	if total == 0 {
+		// should not happen
+		return magara{Kind: EnergyMagara, Charges: EnergyMagara.DefaultCharges()}
+	}
+	n := g.randInt(total)
+	mag := kinds[len(kinds)-1]
+	for _, mk := range kinds {
+		w := weight(mk.data())
+		if n < w {
+			mag = mk
+			break
+		}
+		n -= w
 	}
 	return magara{Kind: mag, Charges: mag.DefaultCharges()}
 }
@@ -128,7 +417,9 @@ func (g *game) EquipMagara(i int) (err error) {
 	return nil
 }
 
-func (g *game) UseMagara(n int) (err error) {
+// CanUseMagara returns an error if the player cannot currently evoke the
+// n-th magara, independently of its effects.
+func (g *game) CanUseMagara(n int) error {
 	if g.Player.HasStatus(StatusConfusion) {
 		return errors.New("You cannot use magaras while confused.")
 	}
@@ -142,52 +433,16 @@ func (g *game) UseMagara(n int) (err error) {
 	if mag.Charges <= 0 {
 		return errors.New("Not enough charges for using this magara.")
 	}
-	switch mag.Kind {
-	case BlinkMagara:
-		err = g.EvokeBlink()
-	case DigMagara:
-		err = g.EvokeDig()
-	case TeleportMagara:
-		err = g.EvokeTeleport()
-	case SwiftnessMagara:
-		err = g.EvokeSwiftness()
-	case LevitationMagara:
-		err = g.EvokeLevitation()
-	case FireMagara:
-		err = g.EvokeFire()
-	case FogMagara:
-		err = g.EvokeFog()
-	case ShadowsMagara:
-		err = g.EvokeShadows()
-	case NoiseMagara:
-		err = g.EvokeNoise()
-	case ConfusionMagara:
-		err = g.EvokeConfusion()
-	case ParalysisMagara:
-		err = g.EvokeParalysis()
-	case SleepingMagara:
-		err = g.EvokeSleeping()
-	case TeleportOtherMagara:
-		err = g.EvokeTeleportOther()
-	case SwappingMagara:
-		err = g.EvokeSwapping()
-	case ObstructionMagara:
-		err = g.EvokeObstruction()
-	case LignificationMagara:
-		err = g.EvokeLignification()
-	case EnergyMagara:
-		err = g.EvokeEnergyMagara()
-	case TransparencyMagara:
-		err = g.EvokeTransparencyMagara()
-	case DisguiseMagara:
-		err = g.EvokeDisguiseMagara()
-	case DelayedNoiseMagara:
-		err = g.EvokeDelayedNoiseMagara()
-	case DispersalMagara:
-		err = g.EvokeDispersalMagara()
-	case DelayedOricExplosionMagara:
-		err = g.EvokeOricExplosionMagara()
+	return nil
+}
+
+func (g *game) UseMagara(n int) (err error) {
+	err = g.CanUseMagara(n)
+	if err != nil {
+		return err
 	}
+	mag := g.Player.Magaras[n]
+	err = mag.Kind.data().Effect(g)
 	if err != nil {
 		return err
 	}
@@ -231,8 +486,7 @@ func (g *game) UseMagara(n int) (err error) {
 			AchPyromancerMaster.Get(g)
 		}
 	}
-	switch mag.Kind {
-	case TeleportMagara, TeleportOtherMagara, BlinkMagara, SwappingMagara, DispersalMagara:
+	if mag.Kind.data().Teleport {
 		g.Stats.OricTelUse++
 		if g.Stats.OricTelUse == 14 {
 			AchTeleport.Get(g)
@@ -241,56 +495,8 @@ func (g *game) UseMagara(n int) (err error) {
 	return nil
 }
 
-func (mag magara) String() (desc string) {
-	switch mag.Kind {
-	case NoMagara:
-		desc = "empty slot"
-	case BlinkMagara:
-		desc = "magara of blinking"
-	case DigMagara:
-		desc = "magara of digging"
-	case TeleportMagara:
-		desc = "magara of teleportation"
-	case SwiftnessMagara:
-		desc = "magara of swiftness"
-	case LevitationMagara:
-		desc = "magara of levitation"
-	case FireMagara:
-		desc = "magara of fire"
-	case FogMagara:
-		desc = "magara of fog"
-	case ShadowsMagara:
-		desc = "magara of shadows"
-	case NoiseMagara:
-		desc = "magara of noise"
-	case ConfusionMagara:
-		desc = "magara of confusion"
-	case SleepingMagara:
-		desc = "magara of sleeping"
-	case TeleportOtherMagara:
-		desc = "magara of teleport other"
-	case SwappingMagara:
-		desc = "magara of swapping"
-	case ParalysisMagara:
-		desc = "magara of paralysis"
-	case ObstructionMagara:
-		desc = "magara of obstruction"
-	case LignificationMagara:
-		desc = "magara of lignification"
-	case EnergyMagara:
-		desc = "magara of energy"
-	case TransparencyMagara:
-		desc = "magara of transparency"
-	case DisguiseMagara:
-		desc = "magara of disguise"
-	case DelayedNoiseMagara:
-		desc = "magara of delayed noise"
-	case DispersalMagara:
-		desc = "magara of dispersal"
-	case DelayedOricExplosionMagara:
-		desc = "magara of oric explosion"
-	}
-	return desc
+func (mag magara) String() string {
+	return mag.Kind.data().Name
 }
 
 func (mag magara) ShortDesc() string {
@@ -298,87 +504,13 @@ func (mag magara) ShortDesc() string {
 }
 
 func (mag magara) Desc(g *game) (desc string) {
-	switch mag.Kind {
-	case NoMagara:
-		desc = "can be used for a new magara."
-	case BlinkMagara:
-		desc = "makes you blink away within your line of sight by using an oric energy disturbance. The magara is more susceptible to send you to the cells that are most far from you."
-	case DigMagara:
-		desc = "makes you dig walls by walking into them like an earth dragon thanks to destructive oric magic."
-	case TeleportMagara:
-		desc = "creates an oric energy disturbance, making you teleport far away on the same level."
-	case SwiftnessMagara:
-		desc = "makes you able to move several times in a row for free."
-	case LevitationMagara:
-		desc = "makes you levitate with oric energies, allowing you to move over chasms, as well as through oric barriers."
-	case FireMagara:
-		desc = "throws small magical sparks at flammable terrain adjacent to you. Flammable terrain is first consumed by magical flames that are by themselves harmless to creatures. Then smoke will produce night clouds inducing sleep and confusion in monsters. As a gawalt monkey, you resist sleepiness, but you will still feel confused. The fire does often expand to other adjacent flammable terrain."
-	case FogMagara:
-		desc = "creates a dense fog in a 2-range radius using harmonic energies. The fog will dissipate with time."
-	case ShadowsMagara:
-		desc = "surrounds you by harmonic shadows. While standing on a dark cell, only adjacent monsters will be able to see you. It does not affect your visibility on lighted cells."
-	case NoiseMagara:
-		desc = "tricks monsters in a 12-range area with harmonic magical sounds, making them go away from you for a few turns. The possible monster destinations will be marked with noise symbols in the map. It only works on monsters that are not already seeing you."
-	case ConfusionMagara:
-		desc = "confuses monsters in sight with harmonic light and sounds, leaving them unable to attack you."
-	case ParalysisMagara:
-		desc = "makes monsters in sight unable to act by disturbing their senses with sound and light illusions."
-	case SleepingMagara:
-		desc = "induces deep sleeping and exhaustion for up to three random monsters you see in cardinal directions using hypnotic illusions."
-	case TeleportOtherMagara:
-		desc = "creates oric energy disturbances, teleporting up to two random monsters you see in cardinal directions."
-	case SwappingMagara:
-		desc = "makes you swap positions with the farthest monster in sight. If there is more than one at the same distance, it will be chosen randomly."
-	case ObstructionMagara:
-		desc = "creates temporal barriers with oric energy between you and monsters in sight."
-	case LignificationMagara:
-		desc = "liberates magical spores that lignify up to 2 monsters in view, so that they cannot move. The monsters can still fight."
-	case EnergyMagara:
-		desc = "replenishes your MP and HP."
-	case TransparencyMagara:
-		desc = "feeds surrounding light to harmonic magic to make you transparent. When standing on a lighted cell, only adjacent monsters will be able to see you. It does not affect your visibility on dark cells."
-	case DisguiseMagara:
-		desc = "surrounds you with harmonic illusions that make you look like a guard. As a result, most monsters will ignore you. Monsters with good flair may see through the illusions at less than 3 tiles away. Monsters that are already hunting you will continue doing so."
-	case DelayedNoiseMagara:
-		desc = "will produce a thunderous harmonic noise in your current gruid.Point. The noise will happen after a delay."
-	case DispersalMagara:
-		desc = "will make monsters that attempt to hit you blink away."
-	case DelayedOricExplosionMagara:
-		desc = "will produce a big rock-destroying oric explosion at your current gruid.Point. The explosion will happen after a delay. It destroys only walls."
-	}
-	duration := 0
-	switch mag.Kind {
-	case ConfusionMagara:
-		duration = DurationConfusionMonster
-	case ParalysisMagara:
-		duration = DurationParalysisMonster
-	case ObstructionMagara:
-		duration = DurationMagicalBarrier
-	case LignificationMagara:
-		duration = DurationLignificationMonster
-	case ShadowsMagara:
-		duration = DurationShadows
-	case DigMagara:
-		duration = DurationDigging
-	case SwiftnessMagara:
-		duration = DurationSwiftness
-	case LevitationMagara:
-		duration = DurationLevitation
-	case TransparencyMagara:
-		duration = DurationTransparency
-	case DisguiseMagara:
-		duration = DurationDisguise
-	case DelayedNoiseMagara:
-		duration = DurationHarmonicNoiseDelay
-	case DelayedOricExplosionMagara:
-		duration = DurationOricExplosionDelay
-	}
-	if duration > 0 {
-		switch mag.Kind {
-		case DelayedNoiseMagara, DelayedOricExplosionMagara:
-			desc += fmt.Sprintf(" Delay lasts for %d turns.", duration)
-		default:
-			desc += fmt.Sprintf(" Effect lasts for %d turns.", duration)
+	data := mag.Kind.data()
+	desc = data.Desc
+	if data.Duration > 0 {
+		if data.Delayed {
+			desc += fmt.Sprintf(" Delay lasts for %d turns.", data.Duration)
+		} else {
+			desc += fmt.Sprintf(" Effect lasts for %d turns.", data.Duration)
 		}
 	}
 	desc += fmt.Sprintf("\n\nIt currently has %d charges.", mag.Charges)
@@ -386,11 +518,7 @@ func (mag magara) Desc(g *game) (desc string) {
 }
 
 func (mag magara) MPCost(g *game) int {
-	if mag.Kind == NoMagara || mag.Kind == EnergyMagara {
-		return 0
-	}
-	cost := 1
-	return cost
+	return mag.Kind.data().MPCost
 }
 
 func (g *game) EvokeBlink() error {
@@ -442,9 +570,10 @@ func (g *game) BlinkPos(mpassable bool) gruid.Point {
 	if len(losPos) == 0 {
 		return invalidPos
 	}
-	q := losPos[RandInt(len(losPos))]
+	sortPoints(losPos)
+	q := losPos[g.randInt(len(losPos))]
 	for i := 0; i < 4; i++ {
-		p := losPos[RandInt(len(losPos))]
+		p := losPos[g.randInt(len(losPos))]
 		if distance(q, g.Player.P) < distance(p, g.Player.P) {
 			q = p
 		}
@@ -469,36 +598,40 @@ func (g *game) EvokeDig() error {
 	return nil
 }
 
-func (g *game) MonstersInLOS() []*monster {
+// monstersInSight returns the monsters in the player's LOS, in index order.
+// If cardinal is true, only monsters in the same row or column as the player
+// are returned.
+func (g *game) monstersInSight(cardinal bool) []*monster {
 	ms := []*monster{}
 	for _, mons := range g.Monsters {
-		if mons.Exists() && g.Player.Sees(mons.P) {
-			ms = append(ms, mons)
+		if !mons.Exists() || !g.Player.Sees(mons.P) {
+			continue
 		}
-	}
-	// shuffle before, because the order could be unnaturally predicted
-	for i := 0; i < len(ms); i++ {
-		j := i + RandInt(len(ms)-i)
-		ms[i], ms[j] = ms[j], ms[i]
+		if cardinal && mons.P.X != g.Player.P.X && mons.P.Y != g.Player.P.Y {
+			continue
+		}
+		ms = append(ms, mons)
 	}
 	return ms
 }
 
-func (g *game) MonstersInCardinalLOS() []*monster {
-	ms := []*monster{}
-	for _, mons := range g.Monsters {
-		if mons.Exists() && g.Player.Sees(mons.P) && (mons.P.X == g.Player.P.X || mons.P.Y == g.Player.P.Y) {
-			ms = append(ms, mons)
-		}
-	}
+func (g *game) shuffleMonsters(ms []*monster) []*monster {
 	// shuffle before, because the order could be unnaturally predicted
 	for i := 0; i < len(ms); i++ {
-		j := i + RandInt(len(ms)-i)
+		j := i + g.randInt(len(ms)-i)
 		ms[i], ms[j] = ms[j], ms[i]
 	}
 	return ms
 }
 
+func (g *game) MonstersInLOS() []*monster {
+	return g.shuffleMonsters(g.monstersInSight(false))
+}
+
+func (g *game) MonstersInCardinalLOS() []*monster {
+	return g.shuffleMonsters(g.monstersInSight(true))
+}
+
 func (g *game) EvokeTeleportOther() error {
 	ms := g.MonstersInCardinalLOS()
 	if len(ms) == 0 {
@@ -626,7 +759,7 @@ func (g *game) Fog(at gruid.Point, radius int) {
 		_, ok := g.Clouds[n.P]
 		if !ok && g.Dungeon.Cell(n.P).AllowsFog() {
 			g.Clouds[n.P] = CloudFog
-			g.PushEvent(&posEvent{P: n.P, Action: CloudEnd}, g.Turn+DurationFog+RandInt(DurationFog/2))
+			g.PushEvent(&posEvent{P: n.P, Action: CloudEnd}, g.Turn+DurationFog+g.randInt(DurationFog/2))
 		}
 	}
 	g.ComputeLOS()
@@ -683,7 +816,7 @@ func (g *game) EvokeSleeping() error {
 		}
 		mons.State = Resting
 		mons.Dir = ZP
-		mons.ExhaustTime(g, 4+RandInt(2))
+		mons.ExhaustTime(g, 4+g.randInt(2))
 		targets = append(targets, g.Ray(mons.P)...)
 	}
 	if len(targets) == 0 {
@@ -861,7 +994,7 @@ func (g *game) CreateMagicalBarrierAt(p gruid.Point) {
 	g.Dungeon.SetCell(p, BarrierCell)
 	delete(g.Clouds, p)
 	g.MagicalBarriers[p] = t
-	g.PushEvent(&posEvent{P: p, Action: ObstructionEnd}, g.Turn+DurationMagicalBarrier+RandInt(DurationMagicalBarrier/2))
+	g.PushEvent(&posEvent{P: p, Action: ObstructionEnd}, g.Turn+DurationMagicalBarrier+g.randInt(DurationMagicalBarrier/2))
 }
 
 func (g *game) EvokeEnergyMagara() error {
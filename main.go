@@ -4,26 +4,39 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/anaseto/gruid"
-	"github.com/anaseto/gruid/ui"
 )
 
 func main() {
 	optVersion := flag.Bool("v", false, "print version number")
 	optNoAnim := flag.Bool("n", false, "no animations")
 	optReplay := flag.String("r", "", "path to replay file (_ means default location)")
+	optVerify := flag.String("verify", "", "verify replay `file` and exit")
+	optExport := flag.String("export-state", "", "write the saved game state as JSON to `file` (- means standard output) and exit")
 	optLogFile := flag.String("o", "", "log to output file")
+	optSeed := flag.Int64("seed", 0, "random seed for a new game (0 means random)")
+	optLevel := flag.String("level", "", "start a new game on the hand-made level in `file`")
+	optRooms := flag.String("rooms", "", "add the room templates of the .room files in `dir`")
+	optMonsters := flag.String("monsters", "", "change or add monster definitions with `file`")
+	optPresets := flag.String("presets", "", "change or add game presets with `file`")
+	optPreset := flag.String("preset", "", "start a new game with the game preset called `name`")
+	optDaily := flag.Bool("daily", false, "play today's daily challenge")
+	optScoreboard := flag.Bool("scoreboard", false, "show the daily challenges scoreboard")
+	optBot := flag.Int("bot", 0, "play `n` games headlessly with the reference bot and print statistics")
+	optGenStats := flag.Int("genstats", 0, "generate all the levels of `n` games and print statistics")
 	opt16colors := new(bool)
 	opt256colors := new(bool)
 	optFullscreen := new(bool)
@@ -39,6 +52,78 @@ func main() {
 		fmt.Println(Version)
 		os.Exit(0)
 	}
+	if *optRooms != "" {
+		if err := LoadRoomTemplates(*optRooms); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load room templates: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *optMonsters != "" {
+		data, err := ioutil.ReadFile(*optMonsters)
+		if err == nil {
+			err = LoadMonsterData(data)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load monster definitions: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *optPresets != "" {
+		data, err := ioutil.ReadFile(*optPresets)
+		if err == nil {
+			err = LoadGamePresets(data)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load game presets: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	var preset *gamePreset
+	if *optPreset != "" {
+		preset = presetByName(*optPreset)
+		if preset == nil {
+			fmt.Fprintf(os.Stderr, "Unknown game preset: %s (available: %s)\n", *optPreset, strings.Join(presetNames(), ", "))
+			os.Exit(1)
+		}
+	}
+	if *optVerify != "" {
+		if err := VerifyReplay(*optVerify); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid replay: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Valid replay.")
+		os.Exit(0)
+	}
+	if *optExport != "" {
+		if err := ExportSavedState(*optExport); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not export game state: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if *optBot > 0 {
+		RunBots(os.Stdout, *optBot, *optSeed)
+		os.Exit(0)
+	}
+	if *optGenStats > 0 {
+		GenStats(os.Stdout, *optGenStats, *optSeed)
+		os.Exit(0)
+	}
+	if *optDaily && (*optSeed != 0 || *optLevel != "" || preset != nil || CustomRoomTemplates || CustomMonsters) {
+		fmt.Fprintln(os.Stderr, "The daily challenge cannot be combined with a seed, level, preset, room templates or monster definitions.")
+		os.Exit(1)
+	}
+	var lvl *level
+	if *optLevel != "" {
+		data, err := ioutil.ReadFile(*optLevel)
+		if err == nil {
+			lvl, err = parseLevel(data)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load level: %v\n", err)
+			os.Exit(1)
+		}
+	}
 	if *optNoAnim {
 		DisableAnimations = true
 	}
@@ -59,47 +144,31 @@ func main() {
 	if err != nil {
 		log.Print(err)
 	}
+	if err := LoadThemesFile(); err != nil {
+		log.Print(err)
+	}
 	applyThemeConf()
 	initDriver(*optFullscreen)
-	if *optReplay != "" {
+	switch {
+	case *optReplay != "":
 		RunReplay(*optReplay)
-	} else {
-		RunGame(*optLogFile)
+	case *optScoreboard:
+		RunScoreboard()
+	default:
+		g := &game{Seed: *optSeed, level: lvl, preset: preset}
+		if *optDaily {
+			g.Daily = dailyDate(time.Now())
+			g.Seed = dailySeed(g.Daily)
+		}
+		RunGame(*optLogFile, g)
 	}
 }
 
-func RunGame(logfile string) {
+// RunGame plays the given new game, unless there is a saved game, which is
+// continued instead.
+func RunGame(logfile string, g *game) {
 	gd := gruid.NewGrid(UIWidth, UIHeight)
-	m := &model{gd: gd, g: &game{}}
-	var repw io.WriteCloser
-	dir, err := DataDir()
-	defer func() {
-		if repw != nil {
-			repw.Close()
-		}
-		if m.finished && dir != "" {
-			RemoveSaveFile()
-			_, err := os.Stat(filepath.Join(dir, "replay.part"))
-			if err != nil {
-				log.Printf("no replay file: %v", err)
-				return
-			}
-			if err := os.Rename(filepath.Join(dir, "replay.part"), filepath.Join(dir, "replay")); err != nil {
-				log.Printf("writing replay file: %v", err)
-			}
-		}
-	}()
-	if err == nil {
-		replay, err := os.OpenFile(filepath.Join(dir, "replay.part"), os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
-		if err == nil {
-			repw = replay
-		} else {
-			log.Printf("writing to replay file: %v", err)
-		}
-	} else {
-		log.Print(err)
-	}
-
+	m := &model{gd: gd, g: g}
 	if logfile != "" {
 		f, err := os.OpenFile(logfile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
 		if err != nil {
@@ -115,19 +184,27 @@ func RunGame(logfile string) {
 		log.SetOutput(ioutil.Discard)
 	}
 	app := gruid.NewApp(gruid.AppConfig{
-		Driver:      driver,
-		Model:       m,
-		FrameWriter: repw,
+		Driver: driver,
+		Model:  m,
 	})
-	err = app.Start(context.Background())
+	err := app.Start(context.Background())
 	if !Tiles && !LogGame {
 		log.SetOutput(os.Stderr)
 	}
 	if err != nil {
 		log.Fatal(err)
 	}
+	if m.err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", m.err)
+		os.Exit(1)
+	}
+	if m.finished {
+		RemoveSaveFile()
+	}
 }
 
+// RunReplay plays a replay file: either a replay of player actions, from
+// which frames are derived, or a frame recording.
 func RunReplay(file string) {
 	if file == "_" {
 		dir, err := DataDir()
@@ -137,29 +214,74 @@ func RunReplay(file string) {
 			log.Print(err)
 		}
 	}
-	replay, err := os.Open(file)
+	data, err := ioutil.ReadFile(file)
 	if err != nil {
 		log.Fatalf("loading replay file: %v", err)
 	}
-	defer replay.Close()
-	fd, err := gruid.NewFrameDecoder(replay)
+	v, err := newReplayViewer(data)
 	if err != nil {
-		log.Printf("frame decoder: %v", err)
+		log.Fatalf("replay: %v", err)
 	}
-	gd := gruid.NewGrid(UIWidth, UIHeight)
-	rep := ui.NewReplay(ui.ReplayConfig{
-		Grid:         gd,
-		FrameDecoder: fd,
+	app := gruid.NewApp(gruid.AppConfig{
+		Driver: driver,
+		Model:  v,
 	})
+	if err := app.Start(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// RunScoreboard shows the daily challenges scoreboard.
+func RunScoreboard() {
+	v, err := newScoreboardViewer()
+	if err != nil {
+		log.Fatalf("scoreboard: %v", err)
+	}
 	app := gruid.NewApp(gruid.AppConfig{
 		Driver: driver,
-		Model:  rep,
+		Model:  v,
 	})
 	if err := app.Start(context.Background()); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// VerifyReplay re-simulates a replay file and checks that it leads to the
+// recorded outcome.
+func VerifyReplay(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	rep, err := DecodeReplay(data)
+	if err != nil {
+		return err
+	}
+	return rep.Verify()
+}
+
+// ExportSavedState writes the JSON representation of the saved game's state
+// to file, or to standard output if file is "-".
+func ExportSavedState(file string) error {
+	g := &game{}
+	load, err := g.Load()
+	if err != nil {
+		return err
+	}
+	if !load {
+		return errors.New("no saved game")
+	}
+	data, err := g.ExportState()
+	if err != nil {
+		return err
+	}
+	if file == "-" {
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
 func subSig(ctx context.Context, msgs chan<- gruid.Msg) {
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
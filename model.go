@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"log"
-	"math/rand"
 	"runtime"
 	"strings"
 	"time"
@@ -39,11 +38,13 @@ const (
 	modeQuitConfirmation
 	modeJumpConfirmation
 	modeWizardConfirmation
+	modeEvokeConfirmation
 	modeDump // simplified dump visualization after end
 	modeEnd  // win or death
 	modeHPCritical
 	modeWelcome
 	modeStory
+	modeOverview // reduced level overview
 )
 
 type pagerMode int
@@ -51,6 +52,7 @@ type pagerMode int
 const (
 	modeLogs pagerMode = iota
 	modeHelpKeys
+	modePastRun
 )
 
 type menuMode int
@@ -64,6 +66,8 @@ const (
 	modeEvocation
 	modeEquip
 	modeWizard
+	modePastRuns
+	modeBestiary
 )
 
 type model struct {
@@ -74,6 +78,7 @@ type model struct {
 	pagerMode   pagerMode
 	menu        *ui.Menu
 	keysMenu    *ui.Menu
+	runsMenu    *ui.Menu
 	status      *ui.Menu
 	log         *ui.Label
 	description *ui.Label
@@ -94,6 +99,16 @@ type model struct {
 	critical    bool
 	auto        bool
 	confirm     bool
+	vision      map[gruid.Point]bool // monster vision overlay
+	noise       map[gruid.Point]bool // noise preview overlay
+	noiseSource noiseSource
+	runs        []runRecord
+	runSort     runSort
+	bestiary    bestiary      // bestiary of past games
+	beasts      []monsterKind // monsters of the bestiary menu
+	evoked      int           // magara waiting for evocation confirmation
+	overview    gruid.Point   // cursor of the level overview
+	err         error         // error that prevented starting the game
 }
 
 type mapTargInfo struct {
@@ -128,6 +143,7 @@ func (md *model) initKeys() {
 		"5":                 ActionWaitTurn,
 		"G":                 ActionGoToStairs,
 		"o":                 ActionExplore,
+		"O":                 ActionStealthExplore,
 		"x":                 ActionExamine,
 		"v":                 ActionEvoke,
 		"V":                 ActionEvoke,
@@ -148,6 +164,9 @@ func (md *model) initKeys() {
 		"=":                 ActionSettings,
 		"+":                 ActionZoomIncrease,
 		"-":                 ActionZoomDecrease,
+		"c":                 ActionToggleVision,
+		"n":                 ActionNoisePreview,
+		"X":                 ActionOverview,
 		gruid.KeyEscape:     ActionEscape,
 	}
 	md.keysTarget = map[gruid.Key]action{
@@ -184,9 +203,12 @@ func (md *model) initKeys() {
 		".":                 ActionTarget,
 		gruid.KeyEnter:      ActionTarget,
 		"t":                 ActionTarget,
+		"T":                 ActionSafeTravel,
 		"g":                 ActionTarget,
 		"e":                 ActionExclude,
 		"r":                 ActionClearExclude,
+		"c":                 ActionToggleVision,
+		"n":                 ActionNoisePreview,
 		gruid.KeySpace:      ActionEscape,
 		gruid.KeyEscape:     ActionEscape,
 		"x":                 ActionEscape,
@@ -229,6 +251,12 @@ func (md *model) initWidgets() {
 		Style: style,
 		Keys:  ui.MenuKeys{Quit: []gruid.Key{gruid.KeySpace, "x", "X", gruid.KeyEscape}},
 	})
+	md.runsMenu = ui.NewMenu(ui.MenuConfig{
+		Grid:  gruid.NewGrid(UIWidth, UIHeight-1),
+		Box:   &ui.Box{},
+		Style: style,
+		Keys:  ui.MenuKeys{Quit: []gruid.Key{gruid.KeySpace, "x", "X", gruid.KeyEscape}},
+	})
 	md.status = ui.NewMenu(ui.MenuConfig{
 		Grid:  gruid.NewGrid(UIWidth, 1),
 		Style: ui.MenuStyle{Layout: gruid.Point{0, 1}, Active: style.Active},
@@ -264,16 +292,76 @@ func (md *model) init() gruid.Effect {
 	g := md.g
 
 	md.applyConfig()
+	seed := g.Seed
+	lvl := g.level
+	preset := g.preset
+	daily := g.Daily
+	ng := *g // new game, in case there is no usable save
 	load, err := g.Load()
+	if err != nil {
+		// keep the save, so that it can still be loaded by the version
+		// that wrote it
+		if err := BackupSaveFile(); err != nil {
+			log.Printf("Error backing up save file: %v", err)
+		}
+	}
+	if load && g.Daily != "" {
+		// daily challenge saves can only be loaded once, and not after
+		// the end of the challenge, to prevent save-scumming
+		err = g.ResumeDaily()
+		if err != nil && err != errDailyOver {
+			// stop without touching the save, so that the challenge
+			// can be resumed once the problem is fixed
+			md.err = fmt.Errorf("could not resume daily challenge: %v", err)
+			md.mode = modeQuit
+			return gruid.End()
+		}
+		if err := RemoveSaveFile(); err != nil {
+			log.Printf("Error removing save file: %v", err)
+		}
+		if err != nil {
+			*g = ng
+			load = false
+		}
+	}
 	md.g.md = md // TODO: avoid this? (though it's handy)
 	if !load {
+		var derr error
+		if g.Daily != "" {
+			derr = g.StartDaily()
+			if derr != nil {
+				g.Daily = ""
+				g.Seed = 0
+			}
+		}
 		g.InitLevel()
 		g.checks()
+		if derr != nil {
+			g.PrintfStyled("Warning: %v… starting normal game.", logError, derr)
+		}
 	} else {
-		g.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+		g.record(simAction{Kind: SimReload})
+		g.reloadRand()
+		if seed != 0 && seed != g.Seed {
+			g.PrintStyled("Warning: continuing saved game, requested seed ignored.", logError)
+		}
+		if lvl != nil {
+			g.PrintStyled("Warning: continuing saved game, requested level ignored.", logError)
+		}
+		if preset != nil && preset.Name != g.Preset {
+			g.PrintStyled("Warning: continuing saved game, requested game preset ignored.", logError)
+		}
+		if daily != "" && daily != g.Daily {
+			g.PrintStyled("Warning: continuing saved game, daily challenge ignored.", logError)
+		}
 	}
-	if err != nil {
+	if err == errDailyOver {
+		g.PrintStyled("Warning: saved daily challenge already over… starting new game.", logError)
+	} else if err != nil {
 		g.PrintStyled("Warning: could not load old saved game… starting new game.", logError)
+		if _, ok := err.(saveVersionError); ok {
+			g.PrintStyled(fmt.Sprintf("The %v. It was kept as backup.", err), logError)
+		}
 		log.Printf("Error: %v", err)
 	}
 
@@ -368,6 +456,27 @@ func (md *model) update(msg gruid.Msg) gruid.Effect {
 		if md.more(msg) {
 			md.finished = true
 			md.mode = modeDump
+			if md.g.Daily != "" {
+				oc := ChallengeDeath
+				if md.g.Player.HP > 0 {
+					oc = ChallengeEscape
+				}
+				if err := AppendScore(md.g.Score(oc)); err != nil {
+					log.Printf("Error writing scoreboard: %v", err)
+				}
+			}
+			// replays cannot reproduce hand-made levels or rooms
+			if !md.g.Custom {
+				if err := md.g.WriteReplay(); err != nil {
+					log.Printf("Error writing replay: %v", err)
+				}
+			}
+			if err := md.g.recordRun(time.Now().Format("2006-01-02 15:04")); err != nil {
+				log.Printf("Error writing run history: %v", err)
+			}
+			if err := md.g.recordBestiary(); err != nil {
+				log.Printf("Error writing bestiary: %v", err)
+			}
 			md.dump(md.g.WriteDump())
 		}
 		return nil
@@ -380,7 +489,11 @@ func (md *model) update(msg gruid.Msg) gruid.Effect {
 			if err != nil {
 				log.Printf("Error removing save file: %v", err)
 			}
-			RemoveReplay()
+			if md.g.Daily != "" {
+				if err := AppendScore(md.g.Score(ChallengeAbandoned)); err != nil {
+					log.Printf("Error writing scoreboard: %v", err)
+				}
+			}
 			return eff
 		}
 		return eff
@@ -390,6 +503,10 @@ func (md *model) update(msg gruid.Msg) gruid.Effect {
 	case modeWizardConfirmation:
 		md.updateWizardConfirmation(msg)
 		return nil
+	case modeEvokeConfirmation:
+		return md.updateEvokeConfirmation(msg)
+	case modeOverview:
+		return md.updateOverview(msg)
 	case modeHPCritical:
 		if md.more(msg) {
 			md.mode = modeNormal
@@ -412,6 +529,8 @@ func (md *model) update(msg gruid.Msg) gruid.Effect {
 			eff = md.updateKeysMenu(msg)
 		case modeKeysChange:
 			eff = md.updateKeysChange(msg)
+		case modePastRuns:
+			eff = md.updatePastRunsMenu(msg)
 		default:
 			eff = md.updateMenu(msg)
 		}
@@ -436,6 +555,10 @@ func (md *model) updateJumpConfirmation(msg gruid.Msg) {
 	case gruid.MsgKeyDown:
 		md.mode = modeNormal
 		if msg.Key == "y" || msg.Key == "Y" {
+			if n := len(md.g.Actions); n > 0 && md.g.Actions[n-1].Kind == SimMove {
+				// the confirmed move becomes a jump
+				md.g.Actions[n-1].Kind = SimJump
+			}
 			md.g.FallAbyss(DescendJump)
 		} else {
 			md.g.Print("No jump, then.")
@@ -448,6 +571,7 @@ func (md *model) updateWizardConfirmation(msg gruid.Msg) {
 	case gruid.MsgKeyDown:
 		md.mode = modeNormal
 		if msg.Key == "y" || msg.Key == "Y" {
+			md.g.record(simAction{Kind: SimWizard})
 			md.g.EnterWizardMode()
 		} else {
 			md.g.Print("Continuing normally, then.")
@@ -455,11 +579,32 @@ func (md *model) updateWizardConfirmation(msg gruid.Msg) {
 	}
 }
 
+func (md *model) updateEvokeConfirmation(msg gruid.Msg) gruid.Effect {
+	switch msg := msg.(type) {
+	case gruid.MsgKeyDown:
+		md.mode = modeNormal
+		md.g.Highlight = nil
+		if msg.Key != "y" && msg.Key != "Y" {
+			md.g.Print("No evocation, then.")
+			return nil
+		}
+		md.g.record(simAction{Kind: SimEvoke, N: md.evoked})
+		err := md.g.UseMagara(md.evoked)
+		if err != nil {
+			md.g.Printf("%v", err)
+			return nil
+		}
+		return md.EndTurn()
+	}
+	return nil
+}
+
 func (md *model) updateNormal(msg gruid.Msg) gruid.Effect {
 	var eff gruid.Effect
 	switch msg := msg.(type) {
 	case msgAuto:
 		if int(msg) == md.g.Turn && md.auto {
+			md.g.record(simAction{Kind: SimAuto})
 			return md.EndTurn()
 		}
 	case gruid.MsgKeyDown:
@@ -524,6 +669,7 @@ func (md *model) updateMouse(msg gruid.MsgMouse) gruid.Effect {
 		var eff gruid.Effect
 		var err error
 		if distance(p, md.g.Player.P) == 1 {
+			md.g.record(simAction{Kind: SimMove, Dir: p.Sub(md.g.Player.P)})
 			again, err = md.g.PlayerBump(p)
 		} else {
 			again, eff, err = md.normalModeAction(ActionTarget)
@@ -694,9 +840,7 @@ func (md *model) EndTurn() gruid.Effect {
 }
 
 func (md *model) updateMapInfo() {
-	md.g.ComputeNoise()
-	md.g.ComputeLOS()
-	md.g.ComputeMonsterLOS()
+	md.g.ComputeMapInfo()
 	md.updateStatusInfo()
 	if md.g.Highlight != nil {
 		md.examine(md.targ.ex.p)
@@ -707,6 +851,10 @@ func (md *model) updatePager(msg gruid.Msg) gruid.Effect {
 	md.pager.Update(msg)
 	if md.pager.Action() == ui.PagerQuit {
 		md.mode = modeNormal
+		if md.pagerMode == modePastRun {
+			md.mode = modeMenu
+			md.menuMode = modePastRuns
+		}
 	}
 	return nil
 }
@@ -805,6 +953,10 @@ func (md *model) updateMenu(msg gruid.Msg) gruid.Effect {
 			if act != ui.MenuInvoke {
 				break
 			}
+			if md.confirmEvocation(md.menu.Active()) {
+				break
+			}
+			md.g.record(simAction{Kind: SimEvoke, N: md.menu.Active()})
 			err := md.g.UseMagara(md.menu.Active())
 			if err != nil {
 				md.g.Printf("%v", err)
@@ -820,6 +972,7 @@ func (md *model) updateMenu(msg gruid.Msg) gruid.Effect {
 			if act != ui.MenuInvoke {
 				break
 			}
+			md.g.record(simAction{Kind: SimEquip, N: md.menu.Active()})
 			err := md.g.EquipMagara(md.menu.Active())
 			if err != nil {
 				md.g.Printf("%v", err)
@@ -827,6 +980,8 @@ func (md *model) updateMenu(msg gruid.Msg) gruid.Effect {
 				break
 			}
 			return md.EndTurn()
+		case modeBestiary:
+			md.updateBestiaryDescription()
 		case modeGameMenu:
 			if act != ui.MenuInvoke {
 				break
@@ -934,6 +1089,11 @@ func (md *model) applyConfig() {
 }
 
 func applyThemeConf() {
+	th := themeByName(GameConfig.Theme)
+	if th == nil {
+		th = themes[0]
+	}
+	th.apply()
 	if Only8Colors && !Tiles {
 		ColorFgLOS = ColorGreen
 	}
@@ -95,11 +95,11 @@ const (
 )
 
 func (mk monsterKind) String() string {
-	return MonsData[mk].name
+	return MonsData[mk].Name
 }
 
 func (mk monsterKind) Letter() rune {
-	return MonsData[mk].letter
+	return MonsData[mk].Letter
 }
 
 func (mk monsterKind) BaseAttack() int {
@@ -107,90 +107,52 @@ func (mk monsterKind) BaseAttack() int {
 }
 
 func (mk monsterKind) Dangerousness() int {
-	return MonsData[mk].dangerousness
+	return MonsData[mk].Dangerousness
+}
+
+func (mk monsterKind) has(flag monsterFlags) bool {
+	return MonsData[mk].Flags&flag != 0
+}
+
+// Ability returns the special ranged or smiting attack of the monster.
+func (mk monsterKind) Ability() monsterAbility {
+	return MonsData[mk].Ability
 }
 
 func (mk monsterKind) Ranged() bool {
-	switch mk {
-	//case MonsLich, MonsCyclop, MonsHighGuard, MonsSatowalgaPlant, MonsMadNixe, MonsVampire, MonsTreeMushroom:
-	case MonsHighGuard, MonsSatowalgaPlant, MonsMadNixe, MonsVampire, MonsTreeMushroom:
-		return true
-	default:
-		return false
-	}
+	return mk.Ability().Ranged()
 }
 
 func (mk monsterKind) Smiting() bool {
-	switch mk {
-	//case MonsMirrorSpecter, MonsMindCelmist:
-	case MonsMirrorSpecter, MonsOricCelmist, MonsHarmonicCelmist:
-		return true
-	default:
-		return false
-	}
+	return mk.Ability().Smiting()
 }
 
 func (mk monsterKind) Peaceful() bool {
-	switch mk {
-	case MonsButterfly, MonsEarthDragon, MonsCrazyImp:
-		return true
-	default:
-		return false
-	}
+	return mk.has(MonsFlagPeaceful)
 }
 
 func (mk monsterKind) GoodFlair() bool {
-	switch mk {
-	case MonsDog, MonsHazeCat, MonsVampire, MonsExplosiveNadre:
-		return true
-	default:
-		return false
-	}
+	return mk.has(MonsFlagGoodFlair)
 }
 
 func (mk monsterKind) Notable() bool {
-	switch mk {
-	case MonsCrazyImp, MonsEarthDragon, MonsHazeCat:
-		return true
-	default:
-		return false
-	}
+	return mk.has(MonsFlagNotable)
 }
 
 func (mk monsterKind) CanOpenDoors() bool {
-	switch mk {
-	case MonsGuard, MonsHighGuard, MonsMadNixe, MonsOricCelmist, MonsHarmonicCelmist, MonsVampire, MonsWingedMilfid:
-		return true
-	default:
-		return false
-	}
+	return mk.has(MonsFlagOpensDoors)
 }
 
 func (mk monsterKind) Patrolling() bool {
-	switch mk {
-	case MonsGuard, MonsHighGuard, MonsMadNixe, MonsOricCelmist, MonsHarmonicCelmist:
-		return true
-	default:
-		return false
-	}
+	return mk.has(MonsFlagPatrols)
 }
 
 func (mk monsterKind) CanFly() bool {
-	switch mk {
-	case MonsWingedMilfid, MonsMirrorSpecter, MonsButterfly, MonsTinyHarpy:
-		return true
-	default:
-		return false
-	}
+	return mk.has(MonsFlagFlies)
 }
 
 func (mk monsterKind) CanSwim() bool {
-	switch mk {
-	case MonsBlinkingFrog, MonsVampire, MonsDog:
-		return true
-	default:
-		return false
-	}
+	return mk.has(MonsFlagSwims)
 }
 
 func (mk monsterKind) CanAttackOnTree() bool {
@@ -199,42 +161,25 @@ func (mk monsterKind) CanAttackOnTree() bool {
 		return true
 	case mk.CanFly():
 		return true
-	case mk == MonsBlinkingFrog || mk == MonsHazeCat:
-		return true
 	default:
-		return false
+		return mk.has(MonsFlagAttacksOnTree)
 	}
 }
 
 func (mk monsterKind) ShallowSleep() bool {
-	switch mk {
-	case MonsCrazyImp, MonsHazeCat:
-		return true
-	default:
-		return false
-	}
+	return mk.has(MonsFlagShallowSleep)
 }
 
 func (mk monsterKind) ResistsLignification() bool {
-	switch mk {
-	case MonsSatowalgaPlant, MonsTreeMushroom:
-		return true
-	default:
-		return false
-	}
+	return mk.has(MonsFlagResistsLignification)
 }
 
 func (mk monsterKind) ReflectsTeleport() bool {
-	switch mk {
-	case MonsBlinkingFrog:
-		return true
-	default:
-		return false
-	}
+	return mk.has(MonsFlagReflectsTeleport)
 }
 
 func (mk monsterKind) Desc() string {
-	return MonsDesc[mk]
+	return MonsData[mk].Desc
 }
 
 func (mk monsterKind) Indefinite(capital bool) (text string) {
@@ -262,7 +207,7 @@ func (mk monsterKind) Definite(capital bool) (text string) {
 }
 
 func (mk monsterKind) Size() monsize {
-	return MonsData[mk].size
+	return MonsData[mk].Size
 }
 
 type monsize int
@@ -285,77 +230,219 @@ func (ms monsize) String() (text string) {
 	return text
 }
 
+// monsterData is the definition of a monster kind. Built-in definitions can
+// be changed, and new kinds added, with a monster definitions file (see
+// parseMonsterData).
 type monsterData struct {
-	size          monsize
-	letter        rune
-	name          string
-	dangerousness int
+	Name          string
+	Letter        rune
+	Size          monsize
+	Dangerousness int
+	Flags         monsterFlags
+	Ability       monsterAbility // ranged or smiting special attack
+	Group         monsterGroup   // group of common bands during generation
+	Desc          string
 }
 
 var MonsData = []monsterData{
-	MonsGuard:     {MonsMedium, 'g', "guard", 3},
-	MonsTinyHarpy: {MonsSmall, 't', "tiny harpy", 3},
-	//MonsOgre:            {10, 2, 20, 3, 'O', "ogre", 7},
-	MonsOricCelmist:     {MonsMedium, 'o', "oric celmist", 9},
-	MonsHarmonicCelmist: {MonsMedium, 'h', "harmonic celmist", 9},
-	MonsWorm:            {MonsSmall, 'w', "farmer worm", 4},
-	//MonsBrizzia:         {15, 1, 10, 3, 'z', "brizzia", 6},
-	MonsAcidMound: {MonsSmall, 'a', "acid mound", 4},
-	MonsDog:       {MonsMedium, 'd', "dog", 5},
-	MonsYack:      {MonsMedium, 'y', "yack", 5},
-	//MonsGiantBee:        {5, 1, 10, 1, 'B', "giant bee", 6},
-	MonsHighGuard: {MonsMedium, 'G', "high guard", 5},
-	//MonsHydra:           {10, 1, 10, 4, 'H', "hydra", 10},
-	//MonsSkeletonWarrior: {10, 1, 10, 3, 'S', "skeleton warrior", 6},
-	MonsSpider:       {MonsSmall, 's', "spider", 15},
-	MonsWingedMilfid: {MonsMedium, 'W', "winged milfid", 6},
-	MonsBlinkingFrog: {MonsMedium, 'F', "blinking frog", 6},
-	//MonsLich:           {10, MonsMedium, 'L', "lich", 15},
-	MonsEarthDragon:    {MonsLarge, 'D', "earth dragon", 18},
-	MonsMirrorSpecter:  {MonsMedium, 'm', "mirror specter", 11},
-	MonsExplosiveNadre: {MonsMedium, 'n', "explosive nadre", 8},
-	MonsSatowalgaPlant: {MonsLarge, 'P', "satowalga plant", 7},
-	MonsMadNixe:        {MonsMedium, 'N', "mad nixe", 14},
-	//MonsMindCelmist:     {10, 1, 20, 2, 'c', "mind celmist", 12},
-	MonsVampire:      {MonsMedium, 'V', "vampire", 13},
-	MonsTreeMushroom: {MonsLarge, 'T', "tree mushroom", 17},
-	//MonsMarevorHelith: {10, MonsMedium, 'M', "Marevor Helith", 18},
-	MonsButterfly: {MonsSmall, 'b', "kerejat", 2},
-	MonsCrazyImp:  {MonsSmall, 'i', "Crazy Imp", 19},
-	MonsHazeCat:   {MonsSmall, 'c', "haze cat", 16},
-}
-
-var MonsDesc = []string{
-	MonsGuard:     "Guards are low rank soldiers who patrol between Dayoriah Clan's buildings.",
-	MonsTinyHarpy: "Tiny harpies are little humanoid flying creatures. They are aggressive when hungry, but peaceful when satiated. This Underground harpy species eats fruits (including bananas) and other vegetables.",
-	//MonsOgre:            "Ogres are big clunky humanoids that can hit really hard.",
-	MonsOricCelmist:     "Oric celmists are mages that can create magical barriers in cells adjacent to you, complicating your escape.\n\nDayoriah Clan's oric celmists are famous for their knowledge of oric magic force manipulations. They are the ones who instigated the steal of Marevor's Gem Portal Artifact. According to Marevor, they plan on doing some dangerous oric experiments with the Artifact, though that's all you can say about it, because his boring explanations were a bit over your head.",
-	MonsHarmonicCelmist: "Harmonic celmists are mages specialized in manipulation of sound and light. They can illuminate you with harmonic light, making it more difficult to hide from them. They also use alert harmonic sounds around you.\n\nHarmonies are usually mainly used for sneaking around in the shadows, but they can also be used to reveal ennemies, sadly for you. Although harmonies are often considered as less prestigious magic energies than oric energies, the Dayoriah Clan knows how to make good use of them, as they clearly showed when they stole Marevor's Gem Portal Artifact.",
-	MonsWorm:            "Farmer worms are ugly creeping creatures. They furrow as they move, helping new foliage to grow.",
-	//MonsBrizzia:         "Brizzias are big slow moving biped creatures. They are quite hardy, and when hurt they can cause nausea, impeding the use of potions.",
-	MonsAcidMound: "Acid mounds are acidic creatures. They can corrode your magaras, reducing their number of charges.",
-	MonsDog:       "Dogs are carnivore quadrupeds. They can bark, and smell you from up to 5 tiles away when hunting or watching for you.",
-	MonsYack:      "Yacks are quite large herbivorous quadrupeds. They tend to eat grass peacefully, but upon seeing you they may attack, pushing you up to 5 cells away.",
-	//MonsGiantBee:        "Giant bees are fragile but extremely fast moving creatures. Their bite can sometimes enrage you.",
-	MonsHighGuard: "High guards watch over a particular location. They can throw javelins.",
-	//MonsHydra:           "Hydras are enormous creatures with four heads that can hit you each at once.",
-	//MonsSkeletonWarrior: "Skeleton warriors are good fighters, clad in chain mail.",
-	MonsSpider:       "Spiders are small creatures, with panoramic vision and whose bite can confuse you.",
-	MonsWingedMilfid: "Winged milfids are  humanoids that can fly over you and make you swap positions. They tend to be very aggressive creatures.",
-	MonsBlinkingFrog: "Blinking frogs are big frog-like creatures, whose bite can make you blink away. The science behind their attack is not clear, but many think it relies on some kind of oric deviation magic. They can jump to attack from below.",
-	//MonsLich:           "Liches are non-living mages wearing a leather armour. They can throw a bolt of torment at you, halving your HP.",
-	MonsEarthDragon:    "Earth dragons are big creatures from a dragon species that wander in the Underground. They are peaceful creatures, but they may hurt you inadvertently, pushing you up to 6 tiles away (3 if confused). They naturally emit powerful oric energies, allowing them to eat rocks and dig tunnels. Their oric energies can confuse you if you're close enough, for example if they hurt you or you jump over them.",
-	MonsMirrorSpecter:  "Mirror specters are very insubstantial creatures, which can absorb your mana.",
-	MonsExplosiveNadre: "Nadres are dragon-like biped creatures that are famous for exploding upon dying. Explosive nadres are a tiny nadre race that explodes upon attacking. The explosion confuses any adjacent creatures and occasionally destroys walls.",
-	MonsSatowalgaPlant: "Satowalga Plants are immobile bushes that throw viscous acidic projectiles at you, destroying some of your magara charges. They attack at half normal speed.",
-	MonsMadNixe:        "Nixes are magical humanoids. Usually, they specialize in illusion harmonic magic, but the so called mad nixes are a perverted variant who learned the oric arts to create a spell that can attract their foes to them, so that they can kill them without pursuing them.",
-	//MonsMindCelmist:     "Mind celmists are mages that use magical smitting mind attacks that bypass armour. They can occasionally confuse or slow you. They try to avoid melee.",
-	MonsVampire:      "Vampires are humanoids that drink blood to survive. Their nauseous spitting can cause confusion, impeding the use of magaras for a few turns.",
-	MonsTreeMushroom: "Tree mushrooms are big clunky creatures. They can throw lignifying spores at you, leaving you unable to move for a few turns, though the spores will also provide some protection against harm.",
-	//MonsMarevorHelith: "Marevor Helith is an ancient undead nakrus very fond of teleporting people away. He is a well-known expert in the field of magaras - items that many people simply call magical objects. His current research focus is monolith creation. Marevor, a repentant necromancer, is now searching for his old disciple Jaixel in the Underground to help him overcome the past.",
-	MonsButterfly: "Underground's butterflies, called kerejats, wander peacefully around, illuminating their surroundings.",
-	MonsCrazyImp:  "Crazy Imp is a crazy creature that likes to sing with its small guitar. It seems to be fond of monkeys and quite capable at finding them by flair. While singing it may attract unwanted attention.",
-	MonsHazeCat:   "Haze cats are a special variety of cats found in the Underground. They have very good night vision and are always alert.",
+	MonsGuard: {
+		Name:          "guard",
+		Letter:        'g',
+		Size:          MonsMedium,
+		Dangerousness: 3,
+		Flags:         MonsFlagOpensDoors | MonsFlagPatrols,
+		Desc:          "Guards are low rank soldiers who patrol between Dayoriah Clan's buildings.",
+	},
+	MonsYack: {
+		Name:          "yack",
+		Letter:        'y',
+		Size:          MonsMedium,
+		Dangerousness: 5,
+		Group:         GroupAnimals,
+		Desc:          "Yacks are quite large herbivorous quadrupeds. They tend to eat grass peacefully, but upon seeing you they may attack, pushing you up to 5 cells away.",
+	},
+	MonsSatowalgaPlant: {
+		Name:          "satowalga plant",
+		Letter:        'P',
+		Size:          MonsLarge,
+		Dangerousness: 7,
+		Flags:         MonsFlagResistsLignification,
+		Ability:       AbilityAcid,
+		Group:         GroupPlants,
+		Desc:          "Satowalga Plants are immobile bushes that throw viscous acidic projectiles at you, destroying some of your magara charges. They attack at half normal speed.",
+	},
+	MonsMadNixe: {
+		Name:          "mad nixe",
+		Letter:        'N',
+		Size:          MonsMedium,
+		Dangerousness: 14,
+		Flags:         MonsFlagOpensDoors | MonsFlagPatrols,
+		Ability:       AbilityAttraction,
+		Group:         GroupBipeds,
+		Desc:          "Nixes are magical humanoids. Usually, they specialize in illusion harmonic magic, but the so called mad nixes are a perverted variant who learned the oric arts to create a spell that can attract their foes to them, so that they can kill them without pursuing them.",
+	},
+	MonsBlinkingFrog: {
+		Name:          "blinking frog",
+		Letter:        'F',
+		Size:          MonsMedium,
+		Dangerousness: 6,
+		Flags:         MonsFlagSwims | MonsFlagAttacksOnTree | MonsFlagReflectsTeleport,
+		Group:         GroupAnimals,
+		Desc:          "Blinking frogs are big frog-like creatures, whose bite can make you blink away. The science behind their attack is not clear, but many think it relies on some kind of oric deviation magic. They can jump to attack from below.",
+	},
+	MonsWorm: {
+		Name:          "farmer worm",
+		Letter:        'w',
+		Size:          MonsSmall,
+		Dangerousness: 4,
+		Group:         GroupAnimals,
+		Desc:          "Farmer worms are ugly creeping creatures. They furrow as they move, helping new foliage to grow.",
+	},
+	MonsMirrorSpecter: {
+		Name:          "mirror specter",
+		Letter:        'm',
+		Size:          MonsMedium,
+		Dangerousness: 11,
+		Flags:         MonsFlagFlies,
+		Ability:       AbilityAbsorbMana,
+		Group:         GroupBipeds,
+		Desc:          "Mirror specters are very insubstantial creatures, which can absorb your mana.",
+	},
+	MonsTinyHarpy: {
+		Name:          "tiny harpy",
+		Letter:        't',
+		Size:          MonsSmall,
+		Dangerousness: 3,
+		Flags:         MonsFlagFlies,
+		Group:         GroupAnimals,
+		Desc:          "Tiny harpies are little humanoid flying creatures. They are aggressive when hungry, but peaceful when satiated. This Underground harpy species eats fruits (including bananas) and other vegetables.",
+	},
+	MonsOricCelmist: {
+		Name:          "oric celmist",
+		Letter:        'o',
+		Size:          MonsMedium,
+		Dangerousness: 9,
+		Flags:         MonsFlagOpensDoors | MonsFlagPatrols,
+		Ability:       AbilityBarrier,
+		Group:         GroupBipeds,
+		Desc:          "Oric celmists are mages that can create magical barriers in cells adjacent to you, complicating your escape.\n\nDayoriah Clan's oric celmists are famous for their knowledge of oric magic force manipulations. They are the ones who instigated the steal of Marevor's Gem Portal Artifact. According to Marevor, they plan on doing some dangerous oric experiments with the Artifact, though that's all you can say about it, because his boring explanations were a bit over your head.",
+	},
+	MonsHarmonicCelmist: {
+		Name:          "harmonic celmist",
+		Letter:        'h',
+		Size:          MonsMedium,
+		Dangerousness: 9,
+		Flags:         MonsFlagOpensDoors | MonsFlagPatrols,
+		Ability:       AbilityIlluminate,
+		Group:         GroupBipeds,
+		Desc:          "Harmonic celmists are mages specialized in manipulation of sound and light. They can illuminate you with harmonic light, making it more difficult to hide from them. They also use alert harmonic sounds around you.\n\nHarmonies are usually mainly used for sneaking around in the shadows, but they can also be used to reveal ennemies, sadly for you. Although harmonies are often considered as less prestigious magic energies than oric energies, the Dayoriah Clan knows how to make good use of them, as they clearly showed when they stole Marevor's Gem Portal Artifact.",
+	},
+	MonsDog: {
+		Name:          "dog",
+		Letter:        'd',
+		Size:          MonsMedium,
+		Dangerousness: 5,
+		Flags:         MonsFlagGoodFlair | MonsFlagSwims,
+		Group:         GroupAnimals,
+		Desc:          "Dogs are carnivore quadrupeds. They can bark, and smell you from up to 5 tiles away when hunting or watching for you.",
+	},
+	MonsHighGuard: {
+		Name:          "high guard",
+		Letter:        'G',
+		Size:          MonsMedium,
+		Dangerousness: 5,
+		Flags:         MonsFlagOpensDoors | MonsFlagPatrols,
+		Ability:       AbilityJavelins,
+		Desc:          "High guards watch over a particular location. They can throw javelins.",
+	},
+	MonsSpider: {
+		Name:          "spider",
+		Letter:        's',
+		Size:          MonsSmall,
+		Dangerousness: 15,
+		Group:         GroupRare,
+		Desc:          "Spiders are small creatures, with panoramic vision and whose bite can confuse you.",
+	},
+	MonsWingedMilfid: {
+		Name:          "winged milfid",
+		Letter:        'W',
+		Size:          MonsMedium,
+		Dangerousness: 6,
+		Flags:         MonsFlagOpensDoors | MonsFlagFlies,
+		Group:         GroupBipeds,
+		Desc:          "Winged milfids are  humanoids that can fly over you and make you swap positions. They tend to be very aggressive creatures.",
+	},
+	MonsEarthDragon: {
+		Name:          "earth dragon",
+		Letter:        'D',
+		Size:          MonsLarge,
+		Dangerousness: 18,
+		Flags:         MonsFlagPeaceful | MonsFlagNotable,
+		Group:         GroupRare,
+		Desc:          "Earth dragons are big creatures from a dragon species that wander in the Underground. They are peaceful creatures, but they may hurt you inadvertently, pushing you up to 6 tiles away (3 if confused). They naturally emit powerful oric energies, allowing them to eat rocks and dig tunnels. Their oric energies can confuse you if you're close enough, for example if they hurt you or you jump over them.",
+	},
+	MonsAcidMound: {
+		Name:          "acid mound",
+		Letter:        'a',
+		Size:          MonsSmall,
+		Dangerousness: 4,
+		Group:         GroupAnimals,
+		Desc:          "Acid mounds are acidic creatures. They can corrode your magaras, reducing their number of charges.",
+	},
+	MonsExplosiveNadre: {
+		Name:          "explosive nadre",
+		Letter:        'n',
+		Size:          MonsMedium,
+		Dangerousness: 8,
+		Flags:         MonsFlagGoodFlair,
+		Group:         GroupAnimals,
+		Desc:          "Nadres are dragon-like biped creatures that are famous for exploding upon dying. Explosive nadres are a tiny nadre race that explodes upon attacking. The explosion confuses any adjacent creatures and occasionally destroys walls.",
+	},
+	MonsVampire: {
+		Name:          "vampire",
+		Letter:        'V',
+		Size:          MonsMedium,
+		Dangerousness: 13,
+		Flags:         MonsFlagGoodFlair | MonsFlagOpensDoors | MonsFlagSwims,
+		Ability:       AbilitySpit,
+		Group:         GroupBipeds,
+		Desc:          "Vampires are humanoids that drink blood to survive. Their nauseous spitting can cause confusion, impeding the use of magaras for a few turns.",
+	},
+	MonsTreeMushroom: {
+		Name:          "tree mushroom",
+		Letter:        'T',
+		Size:          MonsLarge,
+		Dangerousness: 17,
+		Flags:         MonsFlagResistsLignification,
+		Ability:       AbilitySpores,
+		Group:         GroupRare,
+		Desc:          "Tree mushrooms are big clunky creatures. They can throw lignifying spores at you, leaving you unable to move for a few turns, though the spores will also provide some protection against harm.",
+	},
+	MonsButterfly: {
+		Name:          "kerejat",
+		Letter:        'b',
+		Size:          MonsSmall,
+		Dangerousness: 2,
+		Flags:         MonsFlagPeaceful | MonsFlagFlies,
+		Desc:          "Underground's butterflies, called kerejats, wander peacefully around, illuminating their surroundings.",
+	},
+	MonsCrazyImp: {
+		Name:          "Crazy Imp",
+		Letter:        'i',
+		Size:          MonsSmall,
+		Dangerousness: 19,
+		Flags:         MonsFlagPeaceful | MonsFlagNotable | MonsFlagShallowSleep,
+		Desc:          "Crazy Imp is a crazy creature that likes to sing with its small guitar. It seems to be fond of monkeys and quite capable at finding them by flair. While singing it may attract unwanted attention.",
+	},
+	MonsHazeCat: {
+		Name:          "haze cat",
+		Letter:        'c',
+		Size:          MonsSmall,
+		Dangerousness: 16,
+		Flags:         MonsFlagGoodFlair | MonsFlagNotable | MonsFlagAttacksOnTree | MonsFlagShallowSleep,
+		Group:         GroupRare,
+		Desc:          "Haze cats are a special variety of cats found in the Underground. They have very good night vision and are always alert.",
+	},
 }
 
 type bandInfo struct {
@@ -502,6 +589,8 @@ type monster struct {
 	Seen           bool
 	LOS            map[gruid.Point]bool
 	LastKnownState monsterState
+	LastKnownDir   gruid.Point   // facing when last seen
+	LastKnownPath  []gruid.Point // path followed when last seen
 	Swapped        bool
 	Watching       int
 	Left           bool
@@ -510,13 +599,13 @@ type monster struct {
 	Waiting        int
 }
 
-func (m *monster) Init() {
+func (m *monster) Init(g *game) {
 	m.Attack = m.Kind.BaseAttack()
 	m.P = invalidPos
 	m.LOS = make(map[gruid.Point]bool)
 	m.LastKnownPos = invalidPos
 	m.Search = invalidPos
-	if RandInt(2) == 0 {
+	if g.randInt(2) == 0 {
 		m.Left = true
 	}
 	switch m.Kind {
@@ -552,16 +641,16 @@ func (m *monster) Exists() bool {
 	return m != nil && !m.Dead
 }
 
-func (m *monster) Alternate() {
+func (m *monster) Alternate(g *game) {
 	if m.Left {
-		if RandInt(4) > 0 {
+		if g.randInt(4) > 0 {
 			m.Dir = leftDir(m.Dir)
 		} else {
 			m.Dir = rightDir(m.Dir)
 			m.Left = false
 		}
 	} else {
-		if RandInt(3) > 0 {
+		if g.randInt(3) > 0 {
 			m.Dir = rightDir(m.Dir)
 		} else {
 			m.Dir = leftDir(m.Dir)
@@ -619,7 +708,7 @@ func (m *monster) MoveTo(g *game, p gruid.Point) {
 	}
 	if !g.Player.Sees(m.P) && g.Player.Sees(p) {
 		if !m.Seen {
-			m.Seen = true
+			m.markSeen(g)
 			g.Printf("%s (%v) comes into view.", m.Kind.Indefinite(true), m.State)
 		}
 		g.StopAuto()
@@ -755,7 +844,7 @@ func (m *monster) Explode(g *game) {
 			}
 		} else if g.Player.P == p {
 			m.InflictDamage(g, 1, 1)
-		} else if c.IsDestructible() && RandInt(3) > 0 {
+		} else if c.IsDestructible() && g.randInt(3) > 0 {
 			if c.IsDiggable() {
 				g.Dungeon.SetCell(p, RubbleCell)
 			} else {
@@ -802,7 +891,7 @@ func (m *monster) RandomFreeNeighbor(g *game) gruid.Point {
 	if len(fnb) == 0 {
 		return m.P
 	}
-	samedir := fnb[RandInt(len(fnb))]
+	samedir := fnb[g.randInt(len(fnb))]
 	for _, p := range fnb {
 		// invariant: pos != m.Pos
 		if inViewCone(m.Dir, m.P, m.P.Add(p)) {
@@ -810,10 +899,10 @@ func (m *monster) RandomFreeNeighbor(g *game) gruid.Point {
 			break
 		}
 	}
-	if RandInt(4) > 0 {
+	if g.randInt(4) > 0 {
 		return samedir
 	}
-	return fnb[RandInt(len(fnb))]
+	return fnb[g.randInt(len(fnb))]
 }
 
 type mbehaviour int
@@ -836,7 +925,7 @@ func (m *monster) SearchAround(g *game, p gruid.Point, radius int) gruid.Point {
 		SearchAroundCache = append(SearchAroundCache, n.P)
 	}
 	if len(SearchAroundCache) > 0 {
-		p := SearchAroundCache[RandInt(len(SearchAroundCache))]
+		p := SearchAroundCache[g.randInt(len(SearchAroundCache))]
 		return p
 	}
 	return invalidPos
@@ -847,13 +936,13 @@ func (m *monster) NextTarget(g *game) (p gruid.Point) {
 	p = m.P
 	switch band.Beh {
 	case BehWander:
-		if distance(m.P, band.Path[0]) < 8+RandInt(8) {
+		if distance(m.P, band.Path[0]) < 8+g.randInt(8) {
 			p = m.SearchAround(g, m.P, 4)
 			if p != invalidPos {
 				break
 			}
 		}
-		if m.Search != invalidPos && RandInt(2) == 0 {
+		if m.Search != invalidPos && g.randInt(2) == 0 {
 			p = m.SearchAround(g, m.Search, 7)
 			if p != invalidPos {
 				break
@@ -866,7 +955,7 @@ func (m *monster) NextTarget(g *game) (p gruid.Point) {
 		p = band.Path[0]
 	case BehExplore:
 		if m.Kind.CanOpenDoors() {
-			if m.Search != invalidPos && RandInt(4) == 0 {
+			if m.Search != invalidPos && g.randInt(4) == 0 {
 				p = m.SearchAround(g, m.Search, 7)
 			} else {
 				p = m.SearchAround(g, p, 5)
@@ -875,9 +964,9 @@ func (m *monster) NextTarget(g *game) (p gruid.Point) {
 				break
 			}
 		}
-		p = band.Path[RandInt(len(band.Path))]
+		p = band.Path[g.randInt(len(band.Path))]
 	case BehGuard:
-		if m.Search != invalidPos && distance(m.Search, m.P) < 5 && RandInt(2) == 0 {
+		if m.Search != invalidPos && distance(m.Search, m.P) < 5 && g.randInt(2) == 0 {
 			p = m.SearchAround(g, m.Search, 3)
 			if p != invalidPos {
 				break
@@ -885,7 +974,7 @@ func (m *monster) NextTarget(g *game) (p gruid.Point) {
 		}
 		p = band.Path[0]
 	case BehPatrol:
-		if m.Search != invalidPos && RandInt(4) > 0 {
+		if m.Search != invalidPos && g.randInt(4) > 0 {
 			p = m.SearchAround(g, m.Search, 7)
 			if p != m.P && p != invalidPos {
 				break
@@ -897,12 +986,12 @@ func (m *monster) NextTarget(g *game) (p gruid.Point) {
 			p = band.Path[0]
 		} else if distance(band.Path[0], m.P) < distance(band.Path[1], m.P) {
 			p = band.Path[0]
-			if RandInt(4) == 0 {
+			if g.randInt(4) == 0 {
 				p = band.Path[1]
 			}
 		} else {
 			p = band.Path[1]
-			if RandInt(4) == 0 {
+			if g.randInt(4) == 0 {
 				p = band.Path[0]
 			}
 		}
@@ -929,8 +1018,8 @@ func (m *monster) HandleMonsSpecifics(g *game) (done bool) {
 				m.StartWatching()
 			}
 		default:
-			if RandInt(4) > 0 {
-				m.Alternate()
+			if g.randInt(4) > 0 {
+				m.Alternate(g)
 			}
 		}
 		// oklob plants are static ranged-only
@@ -945,7 +1034,8 @@ func (m *monster) HandleMonsSpecifics(g *game) (done bool) {
 				break
 			}
 		}
-		for p, on := range g.Objects.Lights {
+		for _, p := range sortedPoints(g.Objects.Lights) {
+			on := g.Objects.Lights[p]
 			if !on && p == m.P {
 				g.Dungeon.SetCell(m.P, LightCell)
 				g.Objects.Lights[m.P] = true
@@ -960,7 +1050,7 @@ func (m *monster) HandleMonsSpecifics(g *game) (done bool) {
 			}
 		}
 	case MonsCrazyImp:
-		if g.Player.Sees(m.P) && RandInt(2) == 0 && !m.Status(MonsConfused) && !m.Status(MonsExhausted) {
+		if g.Player.Sees(m.P) && g.randInt(2) == 0 && !m.Status(MonsConfused) && !m.Status(MonsExhausted) {
 			g.PrintStyled("Crazy Imp: “♫ larilon, larila ♫ ♪”", logSpecial)
 			g.MakeNoise(SingingNoise, m.P)
 			//g.ui.MusicAnimation(m.Pos)
@@ -978,8 +1068,8 @@ func (m *monster) HandleWatching(g *game) {
 	if m.Kind == MonsHazeCat {
 		turns = 3
 	}
-	if m.Watching+RandInt(2) < turns {
-		m.Alternate()
+	if m.Watching+g.randInt(2) < turns {
+		m.Alternate(g)
 		m.Watching++
 		if m.Kind == MonsDog {
 			dij := &monPath{g: g, monster: m}
@@ -994,7 +1084,7 @@ func (m *monster) HandleWatching(g *game) {
 		m.Target = m.NextTarget(g)
 		switch g.Bands[m.Band].Beh {
 		case BehGuard:
-			m.Alternate()
+			m.Alternate(g)
 			if m.P != m.Target {
 				m.MakeWander()
 				m.GatherBand(g)
@@ -1049,7 +1139,7 @@ func (m *monster) HandleEndPath(g *game) {
 		if !m.Peaceful(g) {
 			if !m.SeesPlayer(g) {
 				m.StartWatching()
-				m.Alternate()
+				m.Alternate(g)
 			}
 		} else {
 			m.Target = m.NextTarget(g)
@@ -1121,7 +1211,7 @@ func (m *monster) HandleMove(g *game) bool {
 			}
 			m.Path = m.Path[1:]
 		}
-	case (mons.P == target && m.P == monstarget || m.Waiting > 5+RandInt(2)) && !mons.Status(MonsLignified):
+	case (mons.P == target && m.P == monstarget || m.Waiting > 5+g.randInt(2)) && !mons.Status(MonsLignified):
 		target := mons.P
 		m.MoveTo(g, target)
 		m.Path = m.Path[1:]
@@ -1129,7 +1219,7 @@ func (m *monster) HandleMove(g *game) bool {
 			mons.Path = mons.Path[1:]
 		}
 	case m.State == Hunting && mons.State != Hunting:
-		if m.Waiting > 2+RandInt(3) {
+		if m.Waiting > 2+g.randInt(3) {
 			if mons.Peaceful(g) {
 				mons.MakeWander()
 			} else {
@@ -1141,7 +1231,7 @@ func (m *monster) HandleMove(g *game) bool {
 		}
 		m.Waiting++
 	case !mons.SeesPlayer(g) && mons.State != Hunting:
-		if m.Waiting > 1+RandInt(2) && mons.Kind != MonsSatowalgaPlant {
+		if m.Waiting > 1+g.randInt(2) && mons.Kind != MonsSatowalgaPlant {
 			mons.MakeWanderAt(mons.RandomFreeNeighbor(g))
 		} else {
 			m.Path = m.APath(g, m.P, m.Target)
@@ -1173,7 +1263,7 @@ func (m *monster) handleTurn(g *game) {
 	mpos := m.P
 	m.MakeAware(g)
 	if m.State == Resting {
-		if RandInt(3000) == 0 || m.Kind.ShallowSleep() && RandInt(10) == 0 {
+		if g.randInt(3000) == 0 || m.Kind.ShallowSleep() && g.randInt(10) == 0 {
 			m.NaturalAwake(g)
 		}
 		return
@@ -1238,7 +1328,7 @@ func (m *monster) InvertFoliage(g *game) {
 }
 
 func (m *monster) Exhaust(g *game) {
-	m.ExhaustTime(g, DurationExhaustionMonster+RandInt(DurationExhaustionMonster/2))
+	m.ExhaustTime(g, DurationExhaustionMonster+g.randInt(DurationExhaustionMonster/2))
 }
 
 func (m *monster) ExhaustTime(g *game, t int) {
@@ -1250,7 +1340,7 @@ func (m *monster) HitPlayer(g *game) {
 		return
 	}
 	dmg := m.Attack
-	clang := RandInt(4) == 0
+	clang := g.randInt(4) == 0
 	noise := g.HitNoise(clang)
 	g.MakeNoise(noise, g.Player.P)
 	var sclang string
@@ -1366,7 +1456,7 @@ func (m *monster) HitSideEffects(g *game) {
 		g.PlacePlayerAt(m.P)
 		g.PrintStyled("The flying milfid makes you swap positions.", logNotable)
 		g.StoryPrintf("Position swap by %s", m.Kind)
-		m.ExhaustTime(g, 5+RandInt(5))
+		m.ExhaustTime(g, 5+g.randInt(5))
 		if terrain(g.Dungeon.Cell(g.Player.P)) == ChasmCell {
 			g.PushEventFirst(&playerEvent{Action: AbyssFall}, g.Turn)
 		}
@@ -1458,18 +1548,16 @@ func (m *monster) RangedAttack(g *game) bool {
 	if m.Status(MonsExhausted) {
 		return false
 	}
-	switch m.Kind {
-	//case MonsLich:
-	//return m.TormentBolt(g, ev)
-	case MonsHighGuard:
+	switch m.Kind.Ability() {
+	case AbilityJavelins:
 		return m.ThrowJavelin(g)
-	case MonsSatowalgaPlant:
+	case AbilityAcid:
 		return m.ThrowAcid(g)
-	case MonsMadNixe:
+	case AbilityAttraction:
 		return m.NixeAttraction(g)
-	case MonsVampire:
+	case AbilitySpit:
 		return m.VampireSpit(g)
-	case MonsTreeMushroom:
+	case AbilitySpores:
 		return m.ThrowSpores(g)
 	}
 	return false
@@ -1524,7 +1612,7 @@ func (g *game) BarrierCandidates(p gruid.Point, dir gruid.Point) []gruid.Point {
 			candidates[len(candidates)-1], candidates[i] = candidates[i], candidates[len(candidates)-1]
 		}
 	}
-	if len(candidates) == 4 && RandInt(2) == 0 {
+	if len(candidates) == 4 && g.randInt(2) == 0 {
 		candidates[1], candidates[2] = candidates[2], candidates[1]
 	}
 	if len(candidates) == 4 {
@@ -1599,7 +1687,7 @@ func (m *monster) ThrowJavelin(g *game) bool {
 		return false
 	}
 	dmg := DmgNormal
-	clang := RandInt(4) == 0
+	clang := g.randInt(4) == 0
 	noise := g.HitNoise(clang)
 	var sclang string
 	if clang {
@@ -1610,14 +1698,14 @@ func (m *monster) ThrowJavelin(g *game) bool {
 	g.md.MonsterJavelinAnimation(g.Ray(m.P), true)
 	g.MakeNoise(noise, g.Player.P)
 	m.InflictDamage(g, dmg, dmg)
-	m.ExhaustTime(g, 10+RandInt(5))
+	m.ExhaustTime(g, 10+g.randInt(5))
 	return true
 }
 
 func (m *monster) Corrode(g *game) {
 	count := 0
 	for i := range g.Player.Magaras {
-		n := RandInt(2)
+		n := g.randInt(2)
 		g.Player.Magaras[i].Charges -= n
 		if g.Player.Magaras[i].Charges < 0 {
 			g.Player.Magaras[i].Charges = 0
@@ -1684,14 +1772,12 @@ func (m *monster) SmitingAttack(g *game) bool {
 	if m.Status(MonsExhausted) {
 		return false
 	}
-	switch m.Kind {
-	case MonsMirrorSpecter:
+	switch m.Kind.Ability() {
+	case AbilityAbsorbMana:
 		return m.AbsorbMana(g)
-	case MonsOricCelmist:
+	case AbilityBarrier:
 		return m.CreateBarrier(g)
-		//case MonsMindCelmist:
-		//return m.MindAttack(g, ev)
-	case MonsHarmonicCelmist:
+	case AbilityIlluminate:
 		return m.Illuminate(g)
 	}
 	return false
@@ -1704,7 +1790,7 @@ func (m *monster) AbsorbMana(g *game) bool {
 	g.Player.MP -= 1
 	g.Printf("%s absorbs your mana.", m.Kind.Definite(true))
 	g.StoryPrintf("Mana absorbed by %s (MP: %d)", m.Kind, g.Player.MP)
-	m.ExhaustTime(g, 1+RandInt(2))
+	m.ExhaustTime(g, 1+g.randInt(2))
 	return true
 }
 
@@ -1724,6 +1810,7 @@ func (m *monster) MakeHunt(g *game) (noticed bool) {
 		m.State = Hunting
 		g.Stats.NSpotted++
 		g.Stats.DSpotted[g.Depth]++
+		g.Stats.SpottingMons[m.Kind]++
 		if !m.Alerted {
 			g.Stats.NUSpotted++
 			g.Stats.DUSpotted[g.Depth]++
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// monsterFlags describes simple properties of a monster kind.
+type monsterFlags int
+
+const (
+	MonsFlagPeaceful monsterFlags = 1 << iota
+	MonsFlagGoodFlair
+	MonsFlagNotable
+	MonsFlagOpensDoors
+	MonsFlagPatrols
+	MonsFlagFlies
+	MonsFlagSwims
+	MonsFlagAttacksOnTree // even if not large nor flying
+	MonsFlagShallowSleep
+	MonsFlagResistsLignification
+	MonsFlagReflectsTeleport
+)
+
+// monsterFlagNames are the names of flags in monster definition files.
+var monsterFlagNames = map[monsterFlags]string{
+	MonsFlagPeaceful:             "peaceful",
+	MonsFlagGoodFlair:            "flair",
+	MonsFlagNotable:              "notable",
+	MonsFlagOpensDoors:           "doors",
+	MonsFlagPatrols:              "patrol",
+	MonsFlagFlies:                "fly",
+	MonsFlagSwims:                "swim",
+	MonsFlagAttacksOnTree:        "tree-attack",
+	MonsFlagShallowSleep:         "shallow-sleep",
+	MonsFlagResistsLignification: "resists-lignification",
+	MonsFlagReflectsTeleport:     "reflects-teleport",
+}
+
+// monsterAbility is a special attack of a monster, either ranged or
+// smiting.
+type monsterAbility int
+
+const (
+	NoAbility monsterAbility = iota
+	AbilityJavelins
+	AbilityAcid
+	AbilityAttraction
+	AbilitySpit
+	AbilitySpores
+	AbilityAbsorbMana
+	AbilityBarrier
+	AbilityIlluminate
+)
+
+var monsterAbilityNames = map[monsterAbility]string{
+	NoAbility:         "none",
+	AbilityJavelins:   "javelins",
+	AbilityAcid:       "acid",
+	AbilityAttraction: "attraction",
+	AbilitySpit:       "spit",
+	AbilitySpores:     "spores",
+	AbilityAbsorbMana: "absorb-mana",
+	AbilityBarrier:    "barrier",
+	AbilityIlluminate: "illuminate",
+}
+
+// Ranged reports whether the ability is a ranged attack, blocked by
+// obstacles.
+func (ab monsterAbility) Ranged() bool {
+	switch ab {
+	case AbilityJavelins, AbilityAcid, AbilityAttraction, AbilitySpit, AbilitySpores:
+		return true
+	default:
+		return false
+	}
+}
+
+// Smiting reports whether the ability is a smiting attack, only requiring
+// that the monster sees the player.
+func (ab monsterAbility) Smiting() bool {
+	switch ab {
+	case AbilityAbsorbMana, AbilityBarrier, AbilityIlluminate:
+		return true
+	default:
+		return false
+	}
+}
+
+// monsterGroup is a group of monsters whose lone bands are placed together
+// during level generation.
+type monsterGroup int
+
+const (
+	NoGroup monsterGroup = iota
+	GroupAnimals
+	GroupBipeds
+	GroupPlants
+	GroupRare
+)
+
+var monsterGroupNames = map[monsterGroup]string{
+	NoGroup:      "none",
+	GroupAnimals: "animals",
+	GroupBipeds:  "bipeds",
+	GroupPlants:  "plants",
+	GroupRare:    "rare",
+}
+
+var monsterSizeNames = map[monsize]string{
+	MonsSmall:  "small",
+	MonsMedium: "medium",
+	MonsLarge:  "large",
+}
+
+// CustomMonsters reports whether monster definitions were loaded from a
+// file.
+var CustomMonsters bool
+
+// loneBand returns the lone band of a monster kind. Every monster kind has
+// one (see addLoneBands).
+func (mk monsterKind) loneBand() monsterBand {
+	for b, bd := range MonsBands {
+		if !bd.Band && bd.Monster == mk {
+			return monsterBand(b)
+		}
+	}
+	panic(fmt.Sprintf("no lone band for %v", mk))
+}
+
+// addLoneBands adds a lone band for each monster kind without one, so that
+// new monster kinds can be generated.
+func addLoneBands() {
+	lone := map[monsterKind]bool{}
+	for _, bd := range MonsBands {
+		if !bd.Band {
+			lone[bd.Monster] = true
+		}
+	}
+	for mk := range MonsData {
+		if !lone[monsterKind(mk)] {
+			MonsBands = append(MonsBands, monsterBandData{Monster: monsterKind(mk)})
+		}
+	}
+}
+
+// groupBands returns the lone bands of the monsters in a group.
+func groupBands(grp monsterGroup) []monsterBand {
+	bands := []monsterBand{}
+	for mk, data := range MonsData {
+		if data.Group == grp {
+			bands = append(bands, monsterKind(mk).loneBand())
+		}
+	}
+	return bands
+}
+
+// parseMonsterData parses a monster definitions file, returning the updated
+// definitions. The file is made of blocks starting with a "monster NAME"
+// line, each followed by property lines:
+//
+//	letter L             letter shown on the map (required for new monsters)
+//	size S               small, medium or large
+//	dangerousness N      relative danger, used for statistics and scoring
+//	flags F...           list of flags (see monsterFlagNames)
+//	ability A            ranged or smiting attack (see monsterAbilityNames)
+//	group G              animals, bipeds, plants, rare or none
+//	desc TEXT            description (several lines are joined)
+//
+// A block with the name of an existing monster changes the given properties,
+// while a block with a new name adds a new monster kind, with generic melee
+// behavior. For example:
+//
+//	monster ogre
+//	letter O
+//	size large
+//	dangerousness 7
+//	flags doors
+//	group bipeds
+//	desc Ogres are big clunky humanoids that can hit really hard.
+//
+// Empty lines and lines starting with '#' are ignored.
+func parseMonsterData(data []byte, defs []monsterData) ([]monsterData, error) {
+	defs = append([]monsterData{}, defs...)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	cur := -1
+	added := map[int]bool{}
+	descs := map[int][]string{}
+	for i := 1; sc.Scan(); i++ {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		fields := strings.Fields(l)
+		key := fields[0]
+		value := strings.TrimSpace(strings.TrimPrefix(l, key))
+		if key == "monster" {
+			if value == "" {
+				return nil, fmt.Errorf("line %d: missing monster name", i)
+			}
+			cur = -1
+			for mk, d := range defs {
+				if strings.EqualFold(d.Name, value) {
+					cur = mk
+				}
+			}
+			if cur < 0 {
+				defs = append(defs, monsterData{Name: value, Size: MonsMedium, Dangerousness: 1})
+				cur = len(defs) - 1
+				added[cur] = true
+			}
+			continue
+		}
+		if cur < 0 {
+			return nil, fmt.Errorf("line %d: property outside of monster block", i)
+		}
+		if key == "desc" {
+			descs[cur] = append(descs[cur], value)
+			continue
+		}
+		if err := defs[cur].setProperty(key, fields[1:]); err != nil {
+			return nil, fmt.Errorf("line %d: %v", i, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for mk, lines := range descs {
+		defs[mk].Desc = strings.Join(lines, " ")
+	}
+	letters := map[rune]string{}
+	for mk, d := range defs {
+		if d.Letter == 0 {
+			return nil, fmt.Errorf("%s: missing letter", d.Name)
+		}
+		if name, ok := letters[d.Letter]; ok {
+			return nil, fmt.Errorf("%s: letter %c already used by %s", d.Name, d.Letter, name)
+		}
+		letters[d.Letter] = d.Name
+		if added[mk] && d.Desc == "" {
+			return nil, fmt.Errorf("%s: missing description", d.Name)
+		}
+	}
+	return defs, nil
+}
+
+func (d *monsterData) setProperty(key string, values []string) error {
+	if len(values) == 0 {
+		return fmt.Errorf("missing value for %s", key)
+	}
+	if key != "flags" && len(values) > 1 {
+		return fmt.Errorf("too many values for %s", key)
+	}
+	switch key {
+	case "letter":
+		r, n := utf8.DecodeRuneInString(values[0])
+		if n != len(values[0]) {
+			return errors.New("letter should be a single character")
+		}
+		d.Letter = r
+	case "size":
+		for sz, name := range monsterSizeNames {
+			if name == values[0] {
+				d.Size = sz
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown size: %s", values[0])
+	case "dangerousness":
+		n, err := strconv.Atoi(values[0])
+		if err != nil {
+			return err
+		}
+		if n < 1 {
+			return fmt.Errorf("invalid dangerousness: %d", n)
+		}
+		d.Dangerousness = n
+	case "flags":
+		d.Flags = 0
+	loop:
+		for _, v := range values {
+			for flag, name := range monsterFlagNames {
+				if name == v {
+					d.Flags |= flag
+					continue loop
+				}
+			}
+			return fmt.Errorf("unknown flag: %s", v)
+		}
+	case "ability":
+		for ab, name := range monsterAbilityNames {
+			if name == values[0] {
+				d.Ability = ab
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown ability: %s", values[0])
+	case "group":
+		for grp, name := range monsterGroupNames {
+			if name == values[0] {
+				d.Group = grp
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown group: %s", values[0])
+	default:
+		return fmt.Errorf("unknown property: %s", key)
+	}
+	return nil
+}
+
+// LoadMonsterData replaces the monster definitions by those obtained by
+// applying the given monster definitions file to the built-in ones.
+func LoadMonsterData(data []byte) error {
+	defs, err := parseMonsterData(data, MonsData)
+	if err != nil {
+		return err
+	}
+	MonsData = defs
+	addLoneBands()
+	CustomMonsters = true
+	return nil
+}
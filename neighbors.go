@@ -25,8 +25,8 @@ func (g *game) flammableNeighbors(p gruid.Point) []gruid.Point {
 	})
 }
 
-func randomNeighbor(p gruid.Point) gruid.Point {
-	switch RandInt(6) {
+func (dg *dgen) randomNeighbor(p gruid.Point) gruid.Point {
+	switch dg.rand.Intn(6) {
 	case 0, 1:
 		return p.Add(gruid.Point{1, 0})
 	case 2, 3:
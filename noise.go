@@ -0,0 +1,134 @@
+package main
+
+import (
+	"github.com/anaseto/gruid"
+)
+
+// noiseSource is a kind of noise made by the player, as shown by the noise
+// preview overlay. Note that opening doors and jumping make no noise by
+// themselves: only moving or landing on queen rock is noisy.
+type noiseSource int
+
+const (
+	NoiseSrcOff       noiseSource = iota // no noise preview
+	NoiseSrcFootsteps                    // moving or jumping to the cell
+	NoiseSrcHit                          // being hit
+	NoiseSrcClang                        // being hit with a clang
+	NoiseSrcMagara                       // magara of noise
+	NoiseSrcDelayed                      // magara of delayed noise
+	noiseSourceMax    = NoiseSrcDelayed
+)
+
+var noiseSourceNames = map[noiseSource]string{
+	NoiseSrcOff:       "off",
+	NoiseSrcFootsteps: "footsteps",
+	NoiseSrcHit:       "hit",
+	NoiseSrcClang:     "hit with clang",
+	NoiseSrcMagara:    "magara of noise",
+	NoiseSrcDelayed:   "magara of delayed noise",
+}
+
+func (ns noiseSource) String() string {
+	return noiseSourceNames[ns]
+}
+
+// Noise returns the intensity of the noise made by the source at p, that is
+// the maximum noise distance at which monsters can hear it. For the magara
+// of noise, it is the distance within which monsters are tricked.
+func (ns noiseSource) Noise(g *game, p gruid.Point) int {
+	switch ns {
+	case NoiseSrcFootsteps:
+		if terrain(g.Dungeon.Cell(p)) == QueenRockCell && !g.Player.HasStatus(StatusLevitation) {
+			return QueenRockFootstepNoise
+		}
+	case NoiseSrcHit:
+		return g.HitNoise(false)
+	case NoiseSrcClang:
+		return g.HitNoise(true)
+	case NoiseSrcMagara:
+		return DefaultLOSRange
+	case NoiseSrcDelayed:
+		return DelayedHarmonicNoise
+	}
+	return 0
+}
+
+// NoisePreview returns the cells reached by the noise of the given source
+// made at p. The value of a cell is true if a known monster there would be
+// disturbed by the noise. Monsters out of view are considered at their last
+// known position, in their last known state.
+func (g *game) NoisePreview(ns noiseSource, p gruid.Point) map[gruid.Point]bool {
+	noise := ns.Noise(g, p)
+	if noise <= 0 || !valid(p) {
+		return map[gruid.Point]bool{}
+	}
+	preview := map[gruid.Point]bool{}
+	dij := &noisePath{g: g}
+	for _, n := range g.PR.BreadthFirstMap(dij, []gruid.Point{p}, noise) {
+		if explored(g.Dungeon.Cell(n.P)) {
+			preview[n.P] = false
+		}
+	}
+	for _, m := range g.Monsters {
+		q, st, ok := g.knownMonster(m)
+		if !ok {
+			continue
+		}
+		d := g.PR.BreadthFirstMapAt(q)
+		switch ns {
+		case NoiseSrcMagara:
+			preview[q] = d <= noise && !m.SeesPlayer(g)
+		default:
+			preview[q] = m.HearsNoise(st, d, noise)
+		}
+		if !preview[q] && d > noise {
+			delete(preview, q)
+		}
+	}
+	return preview
+}
+
+// knownMonster returns the position and state of a monster as known by the
+// player: its current ones if it is visible, and its last known ones
+// otherwise. It reports false if the player knows nothing about the monster.
+func (g *game) knownMonster(m *monster) (gruid.Point, monsterState, bool) {
+	if !m.Exists() {
+		return invalidPos, m.State, false
+	}
+	if g.Player.Sees(m.P) {
+		return m.P, m.State, true
+	}
+	if !valid(m.LastKnownPos) || g.lastMonsterKnownAt(m.LastKnownPos) != m {
+		return invalidPos, m.State, false
+	}
+	return m.LastKnownPos, m.LastKnownState, true
+}
+
+// computeNoisePreview updates the noise preview overlay, if enabled. The
+// preview is made at the examined position, if any, and at the player's
+// position otherwise.
+func (md *model) computeNoisePreview() {
+	g := md.g
+	if md.noiseSource == NoiseSrcOff {
+		md.noise = nil
+		return
+	}
+	p := g.Player.P
+	if valid(md.targ.ex.p) {
+		p = md.targ.ex.p
+	}
+	md.noise = g.NoisePreview(md.noiseSource, p)
+}
+
+// cycleNoisePreview switches to the next kind of noise preview.
+func (md *model) cycleNoisePreview() {
+	md.noiseSource++
+	if md.noiseSource > noiseSourceMax {
+		md.noiseSource = NoiseSrcOff
+	}
+	if md.noiseSource == NoiseSrcOff {
+		md.g.Print("Noise preview off.")
+		return
+	}
+	md.g.Printf("Noise preview: %s.", md.noiseSource)
+}
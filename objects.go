@@ -302,7 +302,7 @@ func (g *game) ActivateNightStone() error {
 		g.Printf("%s falls asleep.", mons.Kind.Definite(true))
 		mons.State = Resting
 		mons.Dir = ZP
-		mons.ExhaustTime(g, 4+RandInt(2))
+		mons.ExhaustTime(g, 4+g.randInt(2))
 	}
 	return nil
 }
@@ -350,11 +350,8 @@ func (g *game) ActivateTeleportStone() error {
 }
 
 func (g *game) TeleportToBarrel() {
-	barrels := []gruid.Point{}
-	for p := range g.Objects.Barrels {
-		barrels = append(barrels, p)
-	}
-	p := barrels[RandInt(len(barrels))]
+	barrels := sortedPoints(g.Objects.Barrels)
+	p := barrels[g.randInt(len(barrels))]
 	op := g.Player.P
 	g.Print("You teleport away.")
 	g.md.TeleportAnimation(op, p, true)
@@ -722,7 +719,7 @@ func (g *game) RandomCloak() (it item) {
 		CloakConversion}
 loop:
 	for {
-		it = cloaks[RandInt(len(cloaks))]
+		it = cloaks[g.randInt(len(cloaks))]
 		for _, cl := range g.GeneratedCloaks {
 			if cl == it {
 				continue loop
@@ -741,7 +738,7 @@ func (g *game) RandomAmulet() (it item) {
 		AmuletObstruction}
 loop:
 	for {
-		it = amulets[RandInt(len(amulets))]
+		it = amulets[g.randInt(len(amulets))]
 		for _, cl := range g.GeneratedAmulets {
 			if cl == it {
 				continue loop
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anaseto/gruid"
+	"github.com/anaseto/gruid/ui"
+)
+
+// overviewScale is the number of map cells, in each direction, represented
+// by a single cell of the level overview.
+const overviewScale = 2
+
+// overviewSize returns the size of the level overview.
+func overviewSize() gruid.Point {
+	return gruid.Point{(DungeonWidth + overviewScale - 1) / overviewScale, (DungeonHeight + overviewScale - 1) / overviewScale}
+}
+
+// overviewPriority returns how important it is to show map position p in the
+// level overview, using only what the player knows. It returns a negative
+// value for unknown positions.
+func (g *game) overviewPriority(p gruid.Point) int {
+	if p == g.Player.P {
+		return 6
+	}
+	if m := g.MonsterAt(p); m.Exists() && g.Player.Sees(p) || g.lastMonsterKnownAt(p).Exists() {
+		return 5
+	}
+	c := g.Dungeon.Cell(p)
+	if !explored(c) {
+		return -1
+	}
+	if trkn, ok := g.TerrainKnowledge[p]; ok {
+		c = trkn | c&Explored
+	}
+	switch terrain(c) {
+	case StairCell, FakeStairCell, StoneCell, MagaraCell, ItemCell, ScrollCell, StoryCell, BananaCell, PotionCell:
+		return 4
+	case WallCell:
+		return 0
+	case GroundCell, CavernCell, RubbleCell, QueenRockCell:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// overviewCell returns the map position represented by the overview cell q:
+// the position of its area with highest overview priority.
+func (g *game) overviewCell(q gruid.Point) gruid.Point {
+	best := invalidPos
+	bestPriority := -2
+	for y := 0; y < overviewScale; y++ {
+		for x := 0; x < overviewScale; x++ {
+			p := gruid.Point{q.X*overviewScale + x, q.Y*overviewScale + y}
+			if !valid(p) {
+				continue
+			}
+			if pr := g.overviewPriority(p); pr > bestPriority {
+				best = p
+				bestPriority = pr
+			}
+		}
+	}
+	return best
+}
+
+// OverviewText returns a full scale text snapshot of the known level, as
+// shown on the map.
+func (md *model) OverviewText() string {
+	g := md.g
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "Depth %d, turn %d\n", g.Depth, g.Turn)
+	for y := 0; y < DungeonHeight; y++ {
+		line := []rune{}
+		for x := 0; x < DungeonWidth; x++ {
+			r, _, _ := md.positionDrawing(gruid.Point{x, y})
+			line = append(line, r)
+		}
+		fmt.Fprintf(buf, "%s\n", strings.TrimRight(string(line), " "))
+	}
+	return buf.String()
+}
+
+// openOverview shows the level overview, with the cursor on the player.
+func (md *model) openOverview() {
+	md.overview = gruid.Point{md.g.Player.P.X / overviewScale, md.g.Player.P.Y / overviewScale}
+	md.mode = modeOverview
+}
+
+// overviewRange returns the range of the level overview on screen, box
+// included.
+func overviewRange() gruid.Range {
+	sz := overviewSize().Add(gruid.Point{2, 2})
+	x := (UIWidth - sz.X) / 2
+	y := 2 + (DungeonHeight-sz.Y)/2
+	return gruid.NewRange(x, y, x+sz.X, y+sz.Y)
+}
+
+func (md *model) drawOverview() {
+	gd := md.gd.Slice(overviewRange())
+	st := gruid.Style{}
+	box := ui.Box{
+		Title:  ui.Textf("Depth %d", md.g.Depth).WithStyle(st.WithFg(ColorYellow)),
+		Footer: ui.Text("(.) go (T) safe (e) export").WithStyle(st.WithFg(ColorCyan)),
+	}
+	gd = box.Draw(gd)
+	it := gd.Iterator()
+	for it.Next() {
+		q := it.P()
+		p := md.g.overviewCell(q)
+		if !valid(p) || md.g.overviewPriority(p) < 0 {
+			it.SetCell(gruid.Cell{Rune: ' ', Style: st.WithBg(ColorBgDark)})
+			continue
+		}
+		r, fg, bg := md.positionDrawing(p)
+		cst := st.WithFg(fg).WithBg(bg)
+		if q == md.overview {
+			cst = cst.WithAttrs(AttrReverse)
+		}
+		it.SetCell(gruid.Cell{Rune: r, Style: cst})
+	}
+}
+
+// moveOverviewCursor moves the cursor of the level overview in the given
+// direction, staying within the overview.
+func (md *model) moveOverviewCursor(dir gruid.Point) {
+	q := md.overview.Add(dir)
+	if q.In(gruid.Range{Max: overviewSize()}) {
+		md.overview = q
+	}
+}
+
+// overviewTravel leaves the level overview and travels to the position
+// represented by the cursor.
+func (md *model) overviewTravel(safe bool) gruid.Effect {
+	md.mode = modeNormal
+	p := md.g.overviewCell(md.overview)
+	if !valid(p) {
+		return nil
+	}
+	md.targ.ex.p = p
+	action := ActionTarget
+	if safe {
+		action = ActionSafeTravel
+	}
+	again, eff, err := md.normalModeAction(action)
+	md.CancelExamine()
+	if err != nil {
+		md.g.Print(err.Error())
+		return eff
+	}
+	if again {
+		return eff
+	}
+	return md.EndTurn()
+}
+
+func (md *model) updateOverview(msg gruid.Msg) gruid.Effect {
+	switch msg := msg.(type) {
+	case gruid.MsgKeyDown:
+		switch msg.Key {
+		case gruid.KeyEscape, gruid.KeySpace, "x", "X":
+			md.mode = modeNormal
+			return nil
+		case gruid.KeyEnter, ".", "t", "g":
+			return md.overviewTravel(false)
+		case "T":
+			return md.overviewTravel(true)
+		case "e", "E":
+			md.mode = modeNormal
+			file, err := WriteOverview([]byte(md.OverviewText()))
+			if err != nil {
+				md.g.PrintfStyled("Error: %v", logError, err)
+			} else if file != "" {
+				md.g.Printf("Level overview written to %s.", file)
+			} else {
+				md.g.Print("Level overview written.")
+			}
+			return nil
+		}
+		switch md.keysNormal[msg.Key] {
+		case ActionW, ActionS, ActionN, ActionE:
+			md.moveOverviewCursor(keyToDir(md.keysNormal[msg.Key]))
+		case ActionRunW, ActionRunS, ActionRunN, ActionRunE:
+			for i := 0; i < 5; i++ {
+				md.moveOverviewCursor(keyToDir(md.keysNormal[msg.Key]))
+			}
+		}
+	case gruid.MsgMouse:
+		rg := overviewRange().Shift(1, 1, -1, -1)
+		if !msg.P.In(rg) {
+			if msg.Action == gruid.MouseMain {
+				md.mode = modeNormal
+			}
+			return nil
+		}
+		md.overview = msg.P.Sub(rg.Min)
+		if msg.Action == gruid.MouseMain {
+			return md.overviewTravel(false)
+		}
+	}
+	return nil
+}
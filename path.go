@@ -242,6 +242,11 @@ func (ap *autoexplorePath) Neighbors(p gruid.Point) []gruid.Point {
 }
 
 func (ap *autoexplorePath) Cost(from, to gruid.Point) int {
+	if ap.g.AutoStealth {
+		// the map is built from the sources, so the player goes from
+		// to to from
+		return ap.g.stealthCost(from)
+	}
 	return 1
 }
 
@@ -360,6 +365,121 @@ func (g *game) PlayerPath(from, to gruid.Point) []gruid.Point {
 	return path
 }
 
+// safePath is a player path that avoids places where known monsters could
+// spot the player, preferring foliage and shadows.
+type safePath struct {
+	g    *game
+	nbs  paths.Neighbors
+	goal gruid.Point
+}
+
+func (sp *safePath) Neighbors(p gruid.Point) []gruid.Point {
+	nbs := sp.nbs.Cardinal(p, sp.g.ppPassable)
+	sort.Slice(nbs, func(i, j int) bool {
+		return distanceChebyshev(nbs[i], sp.goal) <= distanceChebyshev(nbs[j], sp.goal)
+	})
+	return nbs
+}
+
+// Costs of a step in a safe path. A step costs at least one, so that the
+// distance is an admissible estimation.
+const (
+	safeCostHidden     = 1  // step into foliage
+	safeCostNormal     = 2  // step into a shadowed cell
+	safeCostLighted    = 4  // extra cost for illuminated cells
+	safeCostNoisy      = 6  // extra cost for noisy footsteps
+	safeCostPatrol     = 8  // extra cost for paths of patrolling monsters when last seen
+	safeCostCone       = 8  // extra cost in view cones of monsters when last seen
+	safeCostNearMons   = 10 // extra cost near last known monster positions
+	safeCostMonsterLOS = 30 // extra cost for cells seen by known monsters
+)
+
+const (
+	// safeNearMonsDist is the distance to the last known position of a
+	// monster under which a cell is considered dangerous.
+	safeNearMonsDist = 3
+	// safeConeDist is the distance to the last known position of a
+	// monster under which a cell in its view cone is considered
+	// dangerous.
+	safeConeDist = 6
+)
+
+func (sp *safePath) Cost(from, to gruid.Point) int {
+	if !sp.g.ExclusionsMap[from] && sp.g.ExclusionsMap[to] {
+		return unreachable
+	}
+	return sp.g.stealthCost(to)
+}
+
+// stealthCost returns the cost of a step into p for safe travel and stealthy
+// autoexplore, taking into account what the player knows about monsters, as
+// well as light, noise and foliage.
+func (g *game) stealthCost(p gruid.Point) int {
+	c := g.Dungeon.Cell(p)
+	if t, ok := g.TerrainKnowledge[p]; ok {
+		c = t
+	}
+	cost := safeCostNormal
+	if terrain(c) == FoliageCell {
+		cost = safeCostHidden
+	}
+	if g.Illuminated(p) && c.IsIlluminable() {
+		cost += safeCostLighted
+	}
+	if terrain(c) == QueenRockCell && !g.Player.HasStatus(StatusLevitation) {
+		cost += safeCostNoisy
+	}
+	near, cone := false, false
+	for q, i := range g.LastMonsterKnownAt {
+		d := distance(q, p)
+		if d <= safeNearMonsDist {
+			near = true
+		} else if d <= safeConeDist && i >= 0 && i < len(g.Monsters) && g.Monsters[i].LastKnownDir != ZP &&
+			inViewCone(g.Monsters[i].LastKnownDir, q, p) {
+			cone = true
+		}
+	}
+	if near {
+		cost += safeCostNearMons
+	} else if cone {
+		cost += safeCostCone
+	}
+	for _, m := range g.Monsters {
+		if !m.Kind.Patrolling() {
+			continue
+		}
+		for _, q := range m.LastKnownPath {
+			if q == p {
+				if _, _, ok := g.knownMonster(m); ok {
+					cost += safeCostPatrol
+				}
+				break
+			}
+		}
+	}
+	if g.MonsterLOS[p] {
+		cost += safeCostMonsterLOS
+	}
+	return cost
+}
+
+func (sp *safePath) Estimation(from, to gruid.Point) int {
+	return distance(from, to)
+}
+
+// SafePlayerPath returns a path for the player avoiding, when possible,
+// places where known monsters could notice the player: cells in view of
+// monsters or close to their last known positions, illuminated cells and
+// noisy terrain.
+func (g *game) SafePlayerPath(from, to gruid.Point) []gruid.Point {
+	sp := &safePath{g: g, goal: to}
+	path := g.PR.AstarPath(sp, from, to)
+	if len(path) == 0 {
+		return nil
+	}
+	return path
+}
+
 func (g *game) SortedNearestTo(cells []gruid.Point, to gruid.Point) []gruid.Point {
 	ps := posSlice{}
 	for _, p := range cells {
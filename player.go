@@ -153,7 +153,12 @@ func (g *game) MoveToTarget() bool {
 	if !valid(g.AutoTarget) {
 		return false
 	}
-	path := g.PlayerPath(g.Player.P, g.AutoTarget)
+	var path []gruid.Point
+	if g.AutoSafe {
+		path = g.SafePlayerPath(g.Player.P, g.AutoTarget)
+	} else {
+		path = g.PlayerPath(g.Player.P, g.AutoTarget)
+	}
 	if g.MonsterInLOS() != nil {
 		g.AutoTarget = invalidPos
 	}
@@ -211,7 +216,7 @@ func (g *game) Rest() error {
 	// TODO: animation
 	//g.ui.DrawMessage("Resting...")
 	g.Resting = true
-	g.RestingTurns = RandInt(5) // you do not wake up when you want
+	g.RestingTurns = g.randInt(5) // you do not wake up when you want
 	g.Player.Bananas--
 	return nil
 }
@@ -352,6 +357,10 @@ func (g *game) AbyssJump() error {
 	if g.DeepChasmDepth() {
 		return errors.New("You cannot jump into deep chasm.")
 	}
+	if g.md == nil {
+		// headless game: jumping is done with an explicit action
+		return errors.New("You have to confirm jumping into the abyss.")
+	}
 	g.AbyssJumpConfirmation()
 	return nil
 }
@@ -428,7 +437,11 @@ func (g *game) PlayerBump(p gruid.Point) (again bool, err error) {
 		if !g.Player.HasStatus(StatusSwift) {
 			g.Print("You no longer feel swift.")
 		}
-		g.md.updateMapInfo()
+		if g.md != nil {
+			g.md.updateMapInfo()
+		} else {
+			g.ComputeMapInfo()
+		}
 		return again, nil
 	}
 	return again, nil
@@ -441,7 +454,7 @@ func (g *game) SwiftFog() {
 		_, ok := g.Clouds[n.P]
 		if !ok && g.Dungeon.Cell(n.P).AllowsFog() {
 			g.Clouds[n.P] = CloudFog
-			g.PushEvent(&posEvent{P: n.P, Action: CloudEnd}, g.Turn+DurationFog+RandInt(DurationFog/2))
+			g.PushEvent(&posEvent{P: n.P, Action: CloudEnd}, g.Turn+DurationFog+g.randInt(DurationFog/2))
 		}
 	}
 	g.PutStatus(StatusSwift, DurationShortSwiftness)
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"sort"
+
 	"github.com/anaseto/gruid"
 )
 
@@ -98,6 +100,22 @@ func idx(p gruid.Point) int {
 	return p.Y*DungeonWidth + p.X
 }
 
+// sortPoints sorts points in dungeon index order. It is used to get a
+// deterministic order from positions collected while ranging over a map.
+func sortPoints(ps []gruid.Point) {
+	sort.Slice(ps, func(i, j int) bool { return idx(ps[i]) < idx(ps[j]) })
+}
+
+// sortedPoints returns the positions in a map in dungeon index order.
+func sortedPoints(m map[gruid.Point]bool) []gruid.Point {
+	ps := make([]gruid.Point, 0, len(m))
+	for p := range m {
+		ps = append(ps, p)
+	}
+	sortPoints(ps)
+	return ps
+}
+
 func valid(p gruid.Point) bool {
 	return p.Y >= 0 && p.Y < DungeonHeight && p.X >= 0 && p.X < DungeonWidth
 }
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// gamePreset is a named game mode. It overrides the randomized start
+// parameters of new games, as well as the number of played depths.
+type gamePreset struct {
+	Name   string
+	Desc   string
+	Depths int // number of played depths, the last ones (0 means all)
+	Rules  []presetRule
+	Custom bool // loaded from a file
+}
+
+// presetRule is a start parameters override of a preset.
+type presetRule struct {
+	Key     string
+	Event   specialEvent
+	Special specialRoom
+	Flavour genFlavour
+	N       int   // number of extra bananas or bands, or crazy imp depth
+	Depths  []int // nil means none
+}
+
+// builtinPresets describes the built-in presets, in the same format as
+// preset files.
+const builtinPresets = `
+preset short run
+desc Only the last five depths, starting close to Shaedra's prison.
+depths 5
+
+preset all mist levels
+desc The air is dense with mist on every level.
+event mist all
+
+preset no magaras
+desc No magaras can be found in the dungeon.
+nomagara all
+
+preset monster zoo
+desc Many more monsters than usual on every level.
+bands 6 all
+`
+
+// gamePresets contains the available game presets.
+var gamePresets []*gamePreset
+
+func init() {
+	presets, err := parseGamePresets([]byte(builtinPresets), nil)
+	if err != nil {
+		panic(fmt.Sprintf("built-in presets: %v", err))
+	}
+	gamePresets = presets
+}
+
+// presetFlagParams are the keys of rules setting the depths of boolean start
+// parameters.
+var presetFlagParams = map[string]bool{
+	"blocked":      true,
+	"windows":      true,
+	"trees":        true,
+	"holes":        true,
+	"stones":       true,
+	"tables":       true,
+	"nomagara":     true,
+	"fakestair":    true,
+	"lore":         true,
+	"healthpotion": true,
+	"mappingstone": true,
+}
+
+var specialEventNames = map[specialEvent]string{
+	NormalLevel:     "normal",
+	UnstableLevel:   "unstable",
+	EarthquakeLevel: "earthquake",
+	MistLevel:       "mist",
+}
+
+var genFlavourNames = map[genFlavour]string{
+	GenNothing: "nothing",
+	GenAmulet:  "amulet",
+	GenCloak:   "cloak",
+}
+
+// parseGamePresets parses a presets file, returning the updated presets. The
+// file is made of blocks starting with a "preset NAME" line, each followed by
+// rule lines:
+//
+//	desc TEXT            description (several lines are joined)
+//	depths N             only play the last N depths
+//	event E DEPTHS       normal, unstable, earthquake or mist level
+//	special R DEPTHS     special room (see specialRoomNames) or none
+//	plan P DEPTHS        generated item: nothing, cloak or amulet
+//	bananas N DEPTHS     extra bananas (may be negative)
+//	bands N DEPTHS       extra random monster bands
+//	crazyimp D           depth of the crazy imp (0 means none)
+//	FLAG DEPTHS          depths with the given property (see presetFlagParams)
+//
+// DEPTHS is either "all", "none", or a list of depths or ranges of depths,
+// like "2 4-6". A FLAG rule replaces the depths with the property, while
+// other rules only override the given depths. For example:
+//
+//	preset calm
+//	desc No events nor blocked stairs.
+//	event normal all
+//	blocked none
+//
+// A block with the name of an existing preset replaces it. Empty lines and
+// lines starting with '#' are ignored.
+func parseGamePresets(data []byte, presets []*gamePreset) ([]*gamePreset, error) {
+	presets = append([]*gamePreset{}, presets...)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	var cur *gamePreset
+	descs := map[*gamePreset][]string{}
+	for i := 1; sc.Scan(); i++ {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		fields := strings.Fields(l)
+		key := fields[0]
+		value := strings.TrimSpace(strings.TrimPrefix(l, key))
+		if key == "preset" {
+			if value == "" {
+				return nil, fmt.Errorf("line %d: missing preset name", i)
+			}
+			cur = &gamePreset{Name: value}
+			replaced := false
+			for j, p := range presets {
+				if strings.EqualFold(p.Name, value) {
+					presets[j] = cur
+					replaced = true
+				}
+			}
+			if !replaced {
+				presets = append(presets, cur)
+			}
+			continue
+		}
+		if cur == nil {
+			return nil, fmt.Errorf("line %d: rule outside of preset block", i)
+		}
+		if key == "desc" {
+			descs[cur] = append(descs[cur], value)
+			continue
+		}
+		if err := cur.addRule(key, fields[1:]); err != nil {
+			return nil, fmt.Errorf("line %d: %v", i, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for p, lines := range descs {
+		p.Desc = strings.Join(lines, " ")
+	}
+	return presets, nil
+}
+
+func (p *gamePreset) addRule(key string, values []string) error {
+	if len(values) == 0 {
+		return fmt.Errorf("missing value for %s", key)
+	}
+	r := presetRule{Key: key}
+	switch key {
+	case "depths", "crazyimp":
+		if len(values) != 1 {
+			return fmt.Errorf("too many values for %s", key)
+		}
+		n, err := strconv.Atoi(values[0])
+		if err != nil {
+			return err
+		}
+		if n < 0 || n > MaxDepth || key == "depths" && n == 0 {
+			return fmt.Errorf("%s out of range: %d", key, n)
+		}
+		if key == "depths" {
+			p.Depths = n
+			return nil
+		}
+		r.N = n
+		p.Rules = append(p.Rules, r)
+		return nil
+	case "event":
+		ok := false
+		for ev, name := range specialEventNames {
+			if name == values[0] {
+				r.Event = ev
+				ok = true
+			}
+		}
+		if !ok {
+			return fmt.Errorf("unknown event: %s", values[0])
+		}
+	case "special":
+		if values[0] != "none" {
+			for sr, name := range specialRoomNames {
+				if name == values[0] {
+					r.Special = sr
+				}
+			}
+			if r.Special == noSpecialRoom {
+				return fmt.Errorf("unknown special room: %s", values[0])
+			}
+			if r.Special == roomShaedra || r.Special == roomArtifact {
+				return fmt.Errorf("%s room cannot be moved", values[0])
+			}
+		}
+	case "plan":
+		ok := false
+		for fl, name := range genFlavourNames {
+			if name == values[0] {
+				r.Flavour = fl
+				ok = true
+			}
+		}
+		if !ok {
+			return fmt.Errorf("unknown item flavour: %s", values[0])
+		}
+	case "bananas", "bands":
+		n, err := strconv.Atoi(values[0])
+		if err != nil {
+			return err
+		}
+		if key == "bands" && n < 0 {
+			return fmt.Errorf("negative number of bands: %d", n)
+		}
+		r.N = n
+	default:
+		if !presetFlagParams[key] {
+			return fmt.Errorf("unknown rule: %s", key)
+		}
+		depths, err := parsePresetDepths(values)
+		if err != nil {
+			return err
+		}
+		r.Depths = depths
+		p.Rules = append(p.Rules, r)
+		return nil
+	}
+	depths, err := parsePresetDepths(values[1:])
+	if err != nil {
+		return err
+	}
+	if key == "special" {
+		for _, d := range depths {
+			if d == WinDepth || d == MaxDepth {
+				return fmt.Errorf("special room of depth %d cannot be changed", d)
+			}
+		}
+	}
+	r.Depths = depths
+	p.Rules = append(p.Rules, r)
+	return nil
+}
+
+// parsePresetDepths parses a list of depths in a preset rule.
+func parsePresetDepths(values []string) ([]int, error) {
+	if len(values) == 0 {
+		return nil, errors.New("missing depths")
+	}
+	if len(values) == 1 {
+		switch values[0] {
+		case "all":
+			depths := []int{}
+			for d := 1; d <= MaxDepth; d++ {
+				depths = append(depths, d)
+			}
+			return depths, nil
+		case "none":
+			return nil, nil
+		}
+	}
+	depths := []int{}
+	for _, v := range values {
+		from, to := v, v
+		if i := strings.Index(v, "-"); i > 0 {
+			from, to = v[:i], v[i+1:]
+		}
+		lo, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("bad depth: %s", v)
+		}
+		hi, err := strconv.Atoi(to)
+		if err != nil {
+			return nil, fmt.Errorf("bad depth: %s", v)
+		}
+		if lo < 1 || hi > MaxDepth || lo > hi {
+			return nil, fmt.Errorf("depth out of range: %s", v)
+		}
+		for d := lo; d <= hi; d++ {
+			depths = append(depths, d)
+		}
+	}
+	return depths, nil
+}
+
+// LoadGamePresets changes or adds game presets with the given presets file.
+func LoadGamePresets(data []byte) error {
+	presets, err := parseGamePresets(data, gamePresets)
+	if err != nil {
+		return err
+	}
+	old := map[*gamePreset]bool{}
+	for _, p := range gamePresets {
+		old[p] = true
+	}
+	for _, p := range presets {
+		if !old[p] {
+			p.Custom = true
+		}
+	}
+	gamePresets = presets
+	return nil
+}
+
+// presetByName returns the preset with the given name, or nil if there is
+// none.
+func presetByName(name string) *gamePreset {
+	for _, p := range gamePresets {
+		if strings.EqualFold(p.Name, name) {
+			return p
+		}
+	}
+	return nil
+}
+
+// presetNames returns the names of the available presets.
+func presetNames() []string {
+	names := []string{}
+	for _, p := range gamePresets {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// ApplyPreset overrides the start parameters of a new game with those of
+// the given preset. It should be called just after InitFirstLevel.
+func (g *game) ApplyPreset(p *gamePreset) {
+	g.Preset = p.Name
+	if p.Depths > 0 {
+		g.Depth = MaxDepth - p.Depths + 1
+	}
+	for _, r := range p.Rules {
+		switch r.Key {
+		case "event":
+			for _, d := range r.Depths {
+				g.Params.Event[d] = r.Event
+			}
+		case "special":
+			for _, d := range r.Depths {
+				g.Params.Special[d] = r.Special
+			}
+		case "plan":
+			for _, d := range r.Depths {
+				g.GenPlan[d] = r.Flavour
+			}
+		case "bananas":
+			for _, d := range r.Depths {
+				g.Params.ExtraBanana[d] = r.N
+			}
+		case "bands":
+			for _, d := range r.Depths {
+				g.Params.ExtraBands[d] = r.N
+			}
+		case "crazyimp":
+			g.Params.CrazyImp = r.N
+		default:
+			m := map[int]bool{}
+			for _, d := range r.Depths {
+				m[d] = true
+			}
+			g.setParamsFlag(r.Key, m)
+		}
+	}
+}
+
+func (g *game) setParamsFlag(key string, m map[int]bool) {
+	switch key {
+	case "blocked":
+		g.Params.Blocked = m
+	case "windows":
+		g.Params.Windows = m
+	case "trees":
+		g.Params.Trees = m
+	case "holes":
+		g.Params.Holes = m
+	case "stones":
+		g.Params.Stones = m
+	case "tables":
+		g.Params.Tables = m
+	case "nomagara":
+		g.Params.NoMagara = m
+	case "fakestair":
+		g.Params.FakeStair = m
+	case "lore":
+		g.Params.Lore = m
+	case "healthpotion":
+		g.Params.HealthPotion = m
+	case "mappingstone":
+		g.Params.MappingStone = m
+	}
+}
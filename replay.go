@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anaseto/gruid"
+)
+
+// replay contains what is needed to re-simulate a game: the game's version,
+// seed and built-in preset, if any, and the sequence of player actions, as recorded in game.Actions.
+// The outcome of the game is recorded too for finished games, so that a
+// replay can be verified.
+//
+// Replays are saved in a line-based text format, in which a turn line marks
+// the first action of each turn, serving as index for seeking:
+//
+//	harmonist replay
+//	version v0.4.1
+//	seed 1234
+//	preset short run
+//	turn 0 depth 1
+//	move E
+//	turn 1 depth 1
+//	explore
+//	turn 2 depth 1
+//	auto
+//	...
+//	end turn 2345 depth 8 escape
+type replay struct {
+	Version string
+	Seed    int64
+	Preset  string
+	Actions []simAction
+	Index   []turnMark // turn index, as in game.TurnIndex
+	End     *replayEnd // outcome of a finished game
+}
+
+// replayEnd describes the outcome of a replayed game.
+type replayEnd struct {
+	Turn  int
+	Depth int
+	Won   bool
+}
+
+func (e replayEnd) String() string {
+	outcome := "death"
+	if e.Won {
+		outcome = "escape"
+	}
+	return fmt.Sprintf("turn %d depth %d %s", e.Turn, e.Depth, outcome)
+}
+
+const replayHeader = "harmonist replay"
+
+// Replay returns the replay of the game. If the game is finished, its
+// outcome is recorded too.
+func (g *game) Replay(finished bool) *replay {
+	r := &replay{
+		Version: g.Version,
+		Seed:    g.Seed,
+		Preset:  g.Preset,
+		Actions: make([]simAction, len(g.Actions)),
+		Index:   make([]turnMark, len(g.TurnIndex)),
+	}
+	copy(r.Actions, g.Actions)
+	copy(r.Index, g.TurnIndex)
+	if finished {
+		r.End = &replayEnd{Turn: g.Turn, Depth: g.Depth, Won: g.Player.HP > 0}
+	}
+	return r
+}
+
+// Encode returns the replay in text format.
+func (r *replay) Encode() []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, replayHeader)
+	fmt.Fprintf(buf, "version %s\n", r.Version)
+	fmt.Fprintf(buf, "seed %d\n", r.Seed)
+	if r.Preset != "" {
+		fmt.Fprintf(buf, "preset %s\n", r.Preset)
+	}
+	idx := r.Index
+	for i, a := range r.Actions {
+		for len(idx) > 0 && idx[0].Action <= i {
+			fmt.Fprintf(buf, "turn %d depth %d\n", idx[0].Turn, idx[0].Depth)
+			idx = idx[1:]
+		}
+		fmt.Fprintln(buf, a)
+	}
+	if r.End != nil {
+		fmt.Fprintf(buf, "end %v\n", r.End)
+	}
+	return buf.Bytes()
+}
+
+// IsReplay reports whether the data starts like a replay in text format.
+func IsReplay(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(replayHeader))
+}
+
+// DecodeReplay parses a replay in text format.
+func DecodeReplay(data []byte) (*replay, error) {
+	if !IsReplay(data) {
+		return nil, errors.New("not a harmonist replay")
+	}
+	r := &replay{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Scan() // header
+	for n := 2; sc.Scan(); n++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var err error
+		switch fields[0] {
+		case "version":
+			if len(fields) != 2 {
+				err = errors.New("bad version")
+				break
+			}
+			r.Version = fields[1]
+		case "seed":
+			if len(fields) != 2 {
+				err = errors.New("bad seed")
+				break
+			}
+			r.Seed, err = strconv.ParseInt(fields[1], 10, 64)
+		case "preset":
+			if len(fields) < 2 {
+				err = errors.New("bad preset")
+				break
+			}
+			r.Preset = strings.Join(fields[1:], " ")
+		case "turn":
+			var m turnMark
+			m, err = parseTurnMark(fields)
+			m.Action = len(r.Actions)
+			r.Index = append(r.Index, m)
+		case "end":
+			r.End, err = parseReplayEnd(fields[1:])
+		default:
+			var a simAction
+			a, err = parseSimAction(fields)
+			r.Actions = append(r.Actions, a)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("replay line %d: %v", n, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if r.Version == "" || r.Seed == 0 {
+		return nil, errors.New("replay without version or seed")
+	}
+	return r, nil
+}
+
+func parseTurnMark(fields []string) (turnMark, error) {
+	m := turnMark{}
+	if len(fields) != 4 || fields[2] != "depth" {
+		return m, errors.New("bad turn")
+	}
+	var err error
+	m.Turn, err = strconv.Atoi(fields[1])
+	if err != nil {
+		return m, err
+	}
+	m.Depth, err = strconv.Atoi(fields[3])
+	return m, err
+}
+
+func parseReplayEnd(fields []string) (*replayEnd, error) {
+	if len(fields) != 5 || fields[0] != "turn" || fields[2] != "depth" {
+		return nil, errors.New("bad end")
+	}
+	e := &replayEnd{}
+	var err error
+	e.Turn, err = strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	e.Depth, err = strconv.Atoi(fields[3])
+	if err != nil {
+		return nil, err
+	}
+	switch fields[4] {
+	case "escape":
+		e.Won = true
+	case "death":
+	default:
+		return nil, fmt.Errorf("bad outcome: %s", fields[4])
+	}
+	return e, nil
+}
+
+// parseSimAction parses an action in the format of simAction.String.
+func parseSimAction(fields []string) (simAction, error) {
+	a := simAction{Kind: -1}
+	for k := SimWait; k <= SimStealthExplore; k++ {
+		if k.String() == fields[0] {
+			a.Kind = k
+			break
+		}
+	}
+	if a.Kind < 0 {
+		return a, fmt.Errorf("unknown action: %s", fields[0])
+	}
+	nargs := 0
+	switch a.Kind {
+	case SimMove, SimJump, SimRun, SimEvoke, SimEquip, SimTravel, SimSafeTravel, SimExclude, SimClearExclude:
+		nargs = 1
+	}
+	if len(fields) != nargs+1 {
+		return a, fmt.Errorf("bad number of arguments for %v", a.Kind)
+	}
+	var err error
+	switch a.Kind {
+	case SimMove, SimJump, SimRun:
+		a.Dir, err = parseDir(fields[1])
+	case SimEvoke, SimEquip:
+		a.N, err = strconv.Atoi(fields[1])
+	case SimTravel, SimSafeTravel, SimExclude, SimClearExclude:
+		a.Target, err = parsePoint(fields[1])
+	}
+	return a, err
+}
+
+func parseDir(s string) (gruid.Point, error) {
+	for _, dir := range []gruid.Point{{1, 0}, {1, -1}, {0, -1}, {-1, -1}, {-1, 0}, {-1, 1}, {0, 1}, {1, 1}} {
+		if dirString(dir) == s {
+			return dir, nil
+		}
+	}
+	return ZP, fmt.Errorf("bad direction: %s", s)
+}
+
+func parsePoint(s string) (gruid.Point, error) {
+	i := strings.Index(s, ",")
+	if i < 0 {
+		return invalidPos, fmt.Errorf("bad position: %s", s)
+	}
+	x, err := strconv.Atoi(s[:i])
+	if err != nil {
+		return invalidPos, err
+	}
+	y, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return invalidPos, err
+	}
+	return gruid.Point{x, y}, nil
+}
+
+// Simulate re-simulates the first n actions of the replay (all of them if n
+// is negative), calling f, if not nil, after each one. The obtained game
+// state is exactly the same as the recorded game's one after those actions.
+func (r *replay) Simulate(n int, f func(s *sim, a simAction)) (*sim, error) {
+	if r.Version != Version {
+		return nil, fmt.Errorf("replay from version %s cannot be played with version %s", r.Version, Version)
+	}
+	if n < 0 || n > len(r.Actions) {
+		n = len(r.Actions)
+	}
+	var preset *gamePreset
+	if r.Preset != "" {
+		preset = presetByName(r.Preset)
+		if preset == nil || preset.Custom {
+			return nil, fmt.Errorf("unknown built-in game preset: %s", r.Preset)
+		}
+	}
+	s := newPresetSim(r.Seed, preset)
+	s.replay = true
+	for i, a := range r.Actions[:n] {
+		if s.Finished() {
+			return s, fmt.Errorf("game ended before action %d", i+1)
+		}
+		// errors were already seen by the player in the recorded game
+		s.Do(a)
+		if f != nil {
+			f(s, a)
+		}
+	}
+	return s, nil
+}
+
+// Verify re-simulates the replay and checks that the game ends with the
+// recorded outcome.
+func (r *replay) Verify() error {
+	if r.End == nil {
+		return errors.New("unfinished game")
+	}
+	s, err := r.Simulate(-1, nil)
+	if err != nil {
+		return err
+	}
+	g := s.Game()
+	if !s.Finished() {
+		return errors.New("game not finished at the end of the replay")
+	}
+	end := replayEnd{Turn: g.Turn, Depth: g.Depth, Won: s.Won()}
+	if end != *r.End {
+		return fmt.Errorf("recorded outcome (%v) does not match simulated one (%v)", r.End, end)
+	}
+	return nil
+}
+
+// replayActionDelay is the delay between frames for player actions in frame
+// replays derived from action replays. Steps of auto-actions use the same
+// delay as in interactive games.
+const replayActionDelay = 200 * time.Millisecond
+
+// WriteFrames re-simulates the replay and writes a frame recording of it to
+// w, as gruid's apps do with their FrameWriter. The first frame shows the
+// initial state, and then there is one frame per action, even if nothing
+// changed, so that frame n (counting from zero) shows the state after n
+// actions.
+func (r *replay) WriteFrames(w io.Writer) error {
+	gzw := gzip.NewWriter(w)
+	enc := gob.NewEncoder(gzw)
+	var md *model
+	prev := gruid.NewGrid(UIWidth, UIHeight)
+	t := time.Unix(0, 0)
+	first := true
+	var encErr error
+	frame := func(s *sim, d time.Duration) {
+		if md == nil || md.g != s.Game() {
+			md = newReplayModel(s.Game())
+		}
+		md.updateStatusInfo()
+		gd := md.Draw()
+		fr := gruid.Frame{Time: t, Width: UIWidth, Height: UIHeight}
+		it := gd.Iterator()
+		for it.Next() {
+			if c := it.Cell(); c != prev.At(it.P()) || first {
+				fr.Cells = append(fr.Cells, gruid.FrameCell{Cell: c, P: it.P()})
+			}
+		}
+		prev.Copy(gd)
+		first = false
+		t = t.Add(d)
+		if encErr == nil {
+			encErr = enc.Encode(fr)
+		}
+	}
+	s, err := r.Simulate(0, nil)
+	if err != nil {
+		return err
+	}
+	frame(s, replayActionDelay)
+	_, err = r.Simulate(-1, func(s *sim, a simAction) {
+		if a.Kind == SimAuto {
+			frame(s, AnimDurShort)
+		} else {
+			frame(s, replayActionDelay)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if encErr != nil {
+		return encErr
+	}
+	return gzw.Close()
+}
+
+// newReplayModel returns a model for drawing a headless game, without
+// attaching it to the game.
+func newReplayModel(g *game) *model {
+	md := &model{gd: gruid.NewGrid(UIWidth, UIHeight), g: g}
+	md.initKeys()
+	md.initWidgets()
+	md.targ.ex = &examination{}
+	md.CancelExamine()
+	md.initAnimations()
+	return md
+}
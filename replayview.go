@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anaseto/gruid"
+	"github.com/anaseto/gruid/ui"
+)
+
+// replayViewer is the model used for watching replays. It relies on gruid's
+// ui.Replay for frame handling, but handles playing itself, adding seeking by
+// turn and depth using the replay's turn index, a status line and more speed
+// levels.
+type replayViewer struct {
+	rep        *ui.Replay
+	times      []time.Time // frame times
+	index      []turnMark  // turn index (nil for frame recordings)
+	gd         gruid.Grid
+	fidx       int // number of frames shown, as in ui.Replay
+	auto       bool
+	speed      int // delays are divided by 2^speed
+	showStatus bool
+	status     *ui.Label
+	input      *ui.TextInput
+	inputDepth bool // input is a depth instead of a turn
+	inputting  bool
+	help       bool
+	pager      *ui.Pager
+}
+
+const (
+	replayMinSpeed = -3
+	replayMaxSpeed = 6
+)
+
+type msgReplayTick int // frame number
+
+// newReplayViewer returns a viewer for the given replay data: either a replay
+// of player actions, from which frames are derived, or a frame recording.
+func newReplayViewer(data []byte) (*replayViewer, error) {
+	v := &replayViewer{
+		gd:         gruid.NewGrid(UIWidth, UIHeight),
+		auto:       true,
+		showStatus: true,
+	}
+	if IsReplay(data) {
+		r, err := DecodeReplay(data)
+		if err != nil {
+			return nil, err
+		}
+		frames := &bytes.Buffer{}
+		if err := r.WriteFrames(frames); err != nil {
+			return nil, err
+		}
+		data = frames.Bytes()
+		v.index = r.Index
+	}
+	fd, err := gruid.NewFrameDecoder(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("frame decoder: %v", err)
+	}
+	for {
+		fr := gruid.Frame{}
+		err := fd.Decode(&fr)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("frame decoding: %v", err)
+		}
+		v.times = append(v.times, fr.Time)
+	}
+	if len(v.times) == 0 {
+		return nil, errors.New("empty replay")
+	}
+	fd, err = gruid.NewFrameDecoder(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("frame decoder: %v", err)
+	}
+	v.rep = ui.NewReplay(ui.ReplayConfig{
+		Grid:         gruid.NewGrid(UIWidth, UIHeight),
+		FrameDecoder: fd,
+	})
+	v.status = ui.NewLabel(ui.StyledText{}.WithStyle(gruid.Style{}))
+	v.status.Box = &ui.Box{Title: ui.Text("Replay").WithStyle(gruid.Style{}.WithFg(ColorYellow))}
+	v.pager = ui.NewPager(ui.PagerConfig{
+		Grid: gruid.NewGrid(60, UIHeight-1),
+		Box:  &ui.Box{Title: ui.Text("Replay Keys").WithStyle(gruid.Style{}.WithFg(ColorYellow))},
+		Keys: ui.PagerKeys{Quit: []gruid.Key{gruid.KeySpace, "x", "X", gruid.KeyEscape, "?"}},
+	})
+	v.pager.SetLines(v.helpLines())
+	return v, nil
+}
+
+func (v *replayViewer) helpLines() []ui.StyledText {
+	keys := []struct{ k, desc string }{
+		{"q or esc", "Quit"},
+		{"p or space", "Pause/resume"},
+		{"+ or -", "Increase/decrease speed"},
+		{"arrow right or l", "Go to next frame"},
+		{"arrow left or h", "Go to previous frame"},
+		{"arrow down or j", "Go to next turn"},
+		{"arrow up or k", "Go to start of turn, or previous turn"},
+		{"> or <", "Go to next/previous depth"},
+		{"g", "Go to turn"},
+		{"d", "Go to depth"},
+		{"i", "Show/hide status"},
+	}
+	lines := []ui.StyledText{}
+	for _, k := range keys {
+		lines = append(lines, ui.Textf("%-20s %s", k.k, k.desc))
+	}
+	return lines
+}
+
+// Update implements gruid.Model.Update.
+func (v *replayViewer) Update(msg gruid.Msg) gruid.Effect {
+	switch {
+	case v.help:
+		v.pager.Update(msg)
+		if v.pager.Action() == ui.PagerQuit {
+			v.help = false
+		}
+		return nil
+	case v.inputting:
+		v.updateInput(msg)
+		return nil
+	}
+	switch msg := msg.(type) {
+	case gruid.MsgInit:
+		v.setFrame(1)
+		return v.tick()
+	case gruid.MsgKeyDown:
+		return v.updateKeyDown(msg)
+	case msgReplayTick:
+		if v.auto && v.fidx == int(msg) {
+			v.setFrame(v.fidx + 1)
+			if v.fidx < len(v.times) {
+				return v.tick()
+			}
+			v.auto = false
+		}
+	}
+	return nil
+}
+
+func (v *replayViewer) updateKeyDown(msg gruid.MsgKeyDown) gruid.Effect {
+	switch msg.Key {
+	case gruid.KeyEscape, "q", "Q":
+		return gruid.End()
+	case gruid.KeySpace, "p", "P":
+		v.auto = !v.auto
+		if v.auto {
+			return v.tick()
+		}
+	case "+", "}":
+		if v.speed < replayMaxSpeed {
+			v.speed++
+		}
+	case "-", "{":
+		if v.speed > replayMinSpeed {
+			v.speed--
+		}
+	case gruid.KeyArrowRight, "l":
+		v.auto = false
+		v.setFrame(v.fidx + 1)
+	case gruid.KeyArrowLeft, "h":
+		v.auto = false
+		v.setFrame(v.fidx - 1)
+	case gruid.KeyArrowDown, "j":
+		v.auto = false
+		v.nextTurn()
+	case gruid.KeyArrowUp, "k":
+		v.auto = false
+		v.previousTurn()
+	case ">":
+		v.auto = false
+		v.nextDepth()
+	case "<":
+		v.auto = false
+		v.previousDepth()
+	case "g", "d":
+		if v.index == nil {
+			break
+		}
+		v.auto = false
+		v.inputting = true
+		v.inputDepth = msg.Key == "d"
+		title := "Go to turn"
+		if v.inputDepth {
+			title = "Go to depth"
+		}
+		v.input = ui.NewTextInput(ui.TextInputConfig{
+			Grid: gruid.NewGrid(30, 3),
+			Box:  &ui.Box{Title: ui.Text(title).WithStyle(gruid.Style{}.WithFg(ColorYellow))},
+		})
+	case "i":
+		v.showStatus = !v.showStatus
+	case "?":
+		v.auto = false
+		v.help = true
+	}
+	return nil
+}
+
+func (v *replayViewer) updateInput(msg gruid.Msg) {
+	v.input.Update(msg)
+	switch v.input.Action() {
+	case ui.TextInputInvoke:
+		n, err := strconv.Atoi(strings.TrimSpace(v.input.Content()))
+		if err == nil {
+			if v.inputDepth {
+				v.goToDepth(n)
+			} else {
+				v.goToTurn(n)
+			}
+		}
+		fallthrough
+	case ui.TextInputQuit:
+		v.inputting = false
+	}
+}
+
+// setFrame shows the first n frames, n being at least one.
+func (v *replayViewer) setFrame(n int) {
+	if n < 1 {
+		n = 1
+	}
+	if n > len(v.times) {
+		n = len(v.times)
+	}
+	v.rep.SetFrame(n)
+	v.fidx = n
+}
+
+// mark returns the position in the turn index of the current turn, or -1 if
+// unknown. Frame n shows the state after n-1 actions.
+func (v *replayViewer) mark() int {
+	return sort.Search(len(v.index), func(i int) bool { return v.index[i].Action > v.fidx-1 }) - 1
+}
+
+// goToMark shows the state before the first action of the turn at position i
+// in the turn index.
+func (v *replayViewer) goToMark(i int) {
+	if i < 0 || i >= len(v.index) {
+		return
+	}
+	v.setFrame(v.index[i].Action + 1)
+}
+
+func (v *replayViewer) nextTurn() {
+	v.goToMark(v.mark() + 1)
+}
+
+func (v *replayViewer) previousTurn() {
+	i := v.mark()
+	if i < 0 {
+		return
+	}
+	if v.index[i].Action+1 == v.fidx {
+		i--
+	}
+	v.goToMark(i)
+}
+
+// depthStart returns the position in the turn index of the first turn of the
+// depth of the turn at position i.
+func (v *replayViewer) depthStart(i int) int {
+	for i > 0 && v.index[i-1].Depth == v.index[i].Depth {
+		i--
+	}
+	return i
+}
+
+func (v *replayViewer) nextDepth() {
+	i := v.mark()
+	if i < 0 {
+		return
+	}
+	for j := i + 1; j < len(v.index); j++ {
+		if v.index[j].Depth != v.index[i].Depth {
+			v.goToMark(j)
+			return
+		}
+	}
+}
+
+func (v *replayViewer) previousDepth() {
+	i := v.mark()
+	if i < 0 {
+		return
+	}
+	i = v.depthStart(i)
+	if v.index[i].Action+1 == v.fidx && i > 0 {
+		i = v.depthStart(i - 1)
+	}
+	v.goToMark(i)
+}
+
+func (v *replayViewer) goToTurn(turn int) {
+	v.goToMark(sort.Search(len(v.index), func(i int) bool { return v.index[i].Turn >= turn }))
+}
+
+func (v *replayViewer) goToDepth(depth int) {
+	for i, m := range v.index {
+		if m.Depth == depth {
+			v.goToMark(i)
+			return
+		}
+	}
+}
+
+func (v *replayViewer) tick() gruid.Cmd {
+	if v.fidx >= len(v.times) {
+		return nil
+	}
+	d := v.times[v.fidx].Sub(v.times[v.fidx-1])
+	if d >= 2*time.Second {
+		d = 2 * time.Second
+	}
+	if v.speed >= 0 {
+		d /= 1 << v.speed
+	} else {
+		d *= 1 << -v.speed
+	}
+	if d <= time.Second/240 {
+		d = time.Second / 240
+	}
+	n := v.fidx
+	return func() gruid.Msg {
+		t := time.NewTimer(d)
+		<-t.C
+		return msgReplayTick(n)
+	}
+}
+
+func (v *replayViewer) speedString() string {
+	if v.speed >= 0 {
+		return fmt.Sprintf("x%d", 1<<v.speed)
+	}
+	return fmt.Sprintf("x1/%d", 1<<-v.speed)
+}
+
+func (v *replayViewer) statusText() string {
+	b := &strings.Builder{}
+	if i := v.mark(); i >= 0 {
+		fmt.Fprintf(b, "Turn %d Depth %d", v.index[i].Turn, v.index[i].Depth)
+	} else {
+		fmt.Fprintf(b, "Frame %d/%d", v.fidx, len(v.times))
+	}
+	fmt.Fprintf(b, " %s", v.speedString())
+	if !v.auto {
+		b.WriteString(" (paused)")
+	}
+	b.WriteString(" ? help")
+	return b.String()
+}
+
+// Draw implements gruid.Model.Draw.
+func (v *replayViewer) Draw() gruid.Grid {
+	v.gd.Copy(v.rep.Draw())
+	if v.help {
+		v.gd.Slice(gruid.NewRange(10, 0, UIWidth, UIHeight-1)).Copy(v.pager.Draw())
+		return v.gd
+	}
+	if v.showStatus {
+		v.status.SetText(v.statusText())
+		w := len(v.status.Content.Text()) + 2
+		v.status.Draw(v.gd.Slice(v.gd.Range().Lines(UIHeight-4, UIHeight-1).Columns(UIWidth-w, UIWidth)))
+	}
+	if v.inputting {
+		v.gd.Slice(gruid.NewRange(UIWidth/2-15, UIHeight/2-1, UIWidth/2+15, UIHeight/2+2)).Copy(v.input.Draw())
+	}
+	return v.gd
+}
@@ -83,17 +83,17 @@ func (r *room) Dig(dg *dgen) {
 		case 'B':
 			// obstacle
 			t := WallCell
-			switch RandInt(9) {
+			switch dg.rand.Intn(9) {
 			case 0, 6:
 				t = TreeCell
 			case 1:
-				if RandInt(2) == 0 {
+				if dg.rand.Intn(2) == 0 {
 					t = QueenRockCell
 				} else {
 					t = LightCell
 				}
 			case 2:
-				if RandInt(2) == 0 {
+				if dg.rand.Intn(2) == 0 {
 					t = ChasmCell
 				} else {
 					t = TableCell
@@ -205,7 +205,7 @@ func (r *room) Dig(dg *dgen) {
 
 // UnusedEntry returns an unused entry, if possible, or a random entry
 // otherwise.
-func (r *room) UnusedEntry() int {
+func (r *room) UnusedEntry(dg *dgen) int {
 	ens := []int{}
 	for i, e := range r.entries {
 		if !e.used {
@@ -213,12 +213,12 @@ func (r *room) UnusedEntry() int {
 		}
 	}
 	if len(ens) == 0 {
-		return RandInt(len(r.entries))
+		return dg.rand.Intn(len(r.entries))
 	}
-	return ens[RandInt(len(ens))]
+	return ens[dg.rand.Intn(len(ens))]
 }
 
-func (r *room) RandomPlace(kind placeKind) gruid.Point {
+func (r *room) RandomPlace(dg *dgen, kind placeKind) gruid.Point {
 	var p []int
 	for i, pl := range r.places {
 		if pl.kind == kind && !pl.used {
@@ -228,17 +228,17 @@ func (r *room) RandomPlace(kind placeKind) gruid.Point {
 	if len(p) == 0 {
 		return invalidPos
 	}
-	j := p[RandInt(len(p))]
+	j := p[dg.rand.Intn(len(p))]
 	r.places[j].used = true
 	return r.places[j].p
 }
 
 var PlaceSpecialOrStatic = []placeKind{PlaceSpecialStatic, PlaceStatic}
 
-func (r *room) RandomPlaces(kinds []placeKind) gruid.Point {
+func (r *room) RandomPlaces(dg *dgen, kinds []placeKind) gruid.Point {
 	p := invalidPos
 	for _, kind := range kinds {
-		p = r.RandomPlace(kind)
+		p = r.RandomPlace(dg, kind)
 		if p != invalidPos {
 			break
 		}
@@ -924,28 +924,21 @@ const (
 	roomArtifact
 )
 
-func (sr specialRoom) Templates() (tpl []string) {
-	switch sr {
-	case roomMilfids:
-		tpl = append(tpl, RoomSpecialMilfids, RoomSpecialMilfids2)
-	case roomFrogs:
-		tpl = append(tpl, RoomSpecialFrogs)
-	case roomVampires:
-		tpl = append(tpl, RoomSpecialVampires, RoomSpecialVampires2)
-	case roomCelmists:
-		tpl = append(tpl, RoomSpecialCelmists, RoomSpecialCelmists2, RoomSpecialCelmists3)
-	case roomNixes:
-		tpl = append(tpl, RoomSpecialNixes)
-	case roomHarpies:
-		tpl = append(tpl, RoomSpecialHarpies, RoomSpecialHarpies2)
-	case roomTreeMushrooms:
-		tpl = append(tpl, RoomSpecialTreeMushrooms, RoomSpecialTreeMushrooms2)
-	case roomMirrorSpecters:
-		tpl = append(tpl, RoomSpecialMirrorSpecters, RoomSpecialMirrorSpecters2)
-	case roomShaedra:
-		tpl = roomCellTemplates
-	case roomArtifact:
-		tpl = roomArtifactTemplates
-	}
-	return tpl
+// specialRoomTemplates are the built-in templates of special rooms.
+var specialRoomTemplates = map[specialRoom][]string{
+	roomMilfids:        {RoomSpecialMilfids, RoomSpecialMilfids2},
+	roomFrogs:          {RoomSpecialFrogs},
+	roomVampires:       {RoomSpecialVampires, RoomSpecialVampires2},
+	roomCelmists:       {RoomSpecialCelmists, RoomSpecialCelmists2, RoomSpecialCelmists3},
+	roomNixes:          {RoomSpecialNixes},
+	roomHarpies:        {RoomSpecialHarpies, RoomSpecialHarpies2},
+	roomTreeMushrooms:  {RoomSpecialTreeMushrooms, RoomSpecialTreeMushrooms2},
+	roomMirrorSpecters: {RoomSpecialMirrorSpecters, RoomSpecialMirrorSpecters2},
+	roomShaedra:        roomCellTemplates,
+	roomArtifact:       roomArtifactTemplates,
+}
+
+// Templates returns the templates, built-in or loaded, of a special room.
+func (sr specialRoom) Templates() []roomTemplate {
+	return roomTemplatesOf(roomKindSpecial, sr)
 }
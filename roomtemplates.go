@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/anaseto/gruid"
+	"github.com/anaseto/gruid/rl"
+)
+
+// roomKind describes how a room template is used by level generation.
+type roomKind int
+
+const (
+	roomKindNormal roomKind = iota
+	roomKindBig
+	roomKindSpecial
+)
+
+// roomTemplate is a room template with its generation metadata. Templates
+// are either built-in, or loaded from files with parseRoomTemplate.
+//
+// A template file is made of metadata lines, followed by a line containing
+// only "---", and then the room itself. Metadata lines can be:
+//
+//	kind K         normal, big, or a special room name (see specialRoomNames)
+//	mindepth N     minimum depth (default 1)
+//	maxdepth N     maximum depth (default MaxDepth)
+//	placement P    only use for random, center or edge placement (default any)
+//	weight N       relative frequency among eligible templates (default 1)
+//
+// The room uses the same runes as built-in templates, as described in
+// room.Dig.
+type roomTemplate struct {
+	Name      string
+	Kind      roomKind
+	Special   specialRoom // special room, for roomKindSpecial
+	MinDepth  int
+	MaxDepth  int
+	Placement placement
+	Weight    int
+	Content   string
+}
+
+// specialRoomNames are the names of special rooms in template files.
+var specialRoomNames = map[specialRoom]string{
+	roomMilfids:        "milfids",
+	roomFrogs:          "frogs",
+	roomNixes:          "nixes",
+	roomVampires:       "vampires",
+	roomCelmists:       "celmists",
+	roomHarpies:        "harpies",
+	roomTreeMushrooms:  "tree-mushrooms",
+	roomMirrorSpecters: "mirror-specters",
+	roomShaedra:        "shaedra",
+	roomArtifact:       "artifact",
+}
+
+var placementNames = map[placement]string{
+	PlacementRandom: "random",
+	PlacementCenter: "center",
+	PlacementEdge:   "edge",
+	PlacementAny:    "any",
+}
+
+// roomTemplates contains all the available room templates.
+var roomTemplates = builtinRoomTemplates()
+
+// CustomRoomTemplates reports whether room templates were loaded from files.
+var CustomRoomTemplates bool
+
+func builtinRoomTemplates() []roomTemplate {
+	tpls := []roomTemplate{}
+	add := func(kind roomKind, sr specialRoom, contents []string) {
+		for i, content := range contents {
+			tpl := roomTemplate{
+				Kind:      kind,
+				Special:   sr,
+				MinDepth:  1,
+				MaxDepth:  MaxDepth,
+				Placement: PlacementAny,
+				Weight:    1,
+				Content:   content,
+			}
+			tpl.Name = fmt.Sprintf("built-in %s %d", tpl.KindString(), i+1)
+			tpls = append(tpls, tpl)
+		}
+	}
+	add(roomKindNormal, noSpecialRoom, roomNormalTemplates)
+	add(roomKindBig, noSpecialRoom, roomBigTemplates)
+	for sr := roomMilfids; sr <= roomArtifact; sr++ {
+		add(roomKindSpecial, sr, specialRoomTemplates[sr])
+	}
+	return tpls
+}
+
+// KindString returns the kind of the template, as in template files.
+func (tpl roomTemplate) KindString() string {
+	switch tpl.Kind {
+	case roomKindNormal:
+		return "normal"
+	case roomKindBig:
+		return "big"
+	default:
+		return specialRoomNames[tpl.Special]
+	}
+}
+
+// roomTemplatesOf returns the templates of the given kind.
+func roomTemplatesOf(kind roomKind, sr specialRoom) []roomTemplate {
+	tpls := []roomTemplate{}
+	for _, tpl := range roomTemplates {
+		if tpl.Kind == kind && tpl.Special == sr {
+			tpls = append(tpls, tpl)
+		}
+	}
+	return tpls
+}
+
+// eligibleRoomTemplates returns the templates that can be used at the given
+// depth and placement.
+func eligibleRoomTemplates(tpls []roomTemplate, depth int, pl placement) []roomTemplate {
+	etpls := []roomTemplate{}
+	for _, tpl := range tpls {
+		if depth < tpl.MinDepth || depth > tpl.MaxDepth {
+			continue
+		}
+		if tpl.Placement != PlacementAny && tpl.Placement != pl {
+			continue
+		}
+		etpls = append(etpls, tpl)
+	}
+	return etpls
+}
+
+// RandomRoomTemplate returns a random template among the given ones, taking
+// weights into account.
+func (dg *dgen) RandomRoomTemplate(tpls []roomTemplate) roomTemplate {
+	total := 0
+	for _, tpl := range tpls {
+		total += tpl.Weight
+	}
+	n := dg.rand.Intn(total)
+	for _, tpl := range tpls {
+		if n < tpl.Weight {
+			return tpl
+		}
+		n -= tpl.Weight
+	}
+	// should not happen
+	return tpls[len(tpls)-1]
+}
+
+// roomRunes are the runes allowed in room templates.
+const roomRunes = `.>!P_|G-B#+TπlW"?,~cqSMΔA`
+
+// Check returns an error if the template is not valid.
+func (tpl roomTemplate) Check() error {
+	v := &rl.Vault{}
+	v.SetRunes(roomRunes)
+	if err := v.Parse(tpl.Content); err != nil {
+		return err
+	}
+	sz := v.Size()
+	if sz.X == 0 || sz.Y == 0 {
+		return errors.New("empty room")
+	}
+	if (sz.X > DungeonWidth || sz.Y > DungeonHeight) && (sz.Y > DungeonWidth || sz.X > DungeonHeight) {
+		return fmt.Errorf("room too big: %dx%d", sz.X, sz.Y)
+	}
+	counts := map[rune]int{}
+	v.Iter(func(p gruid.Point, r rune) {
+		counts[r]++
+	})
+	if counts['+']+counts['-'] == 0 {
+		return errors.New("no entries ('+' or '-')")
+	}
+	var story string
+	switch tpl.Special {
+	case roomShaedra:
+		story = "SMΔ"
+	case roomArtifact:
+		story = "AMΔ"
+	}
+	for _, r := range "SMΔA" {
+		n := counts[r]
+		switch {
+		case strings.ContainsRune(story, r) && n != 1:
+			return fmt.Errorf("%s rooms need exactly one %q", tpl.KindString(), r)
+		case !strings.ContainsRune(story, r) && n > 0:
+			return fmt.Errorf("%q only allowed in shaedra or artifact rooms", r)
+		}
+	}
+	if tpl.MinDepth < 1 || tpl.MaxDepth > MaxDepth || tpl.MinDepth > tpl.MaxDepth {
+		return fmt.Errorf("invalid depth range: %d-%d", tpl.MinDepth, tpl.MaxDepth)
+	}
+	if tpl.Weight < 1 {
+		return fmt.Errorf("invalid weight: %d", tpl.Weight)
+	}
+	return nil
+}
+
+// parseRoomTemplate parses a room template file, as described in
+// roomTemplate, and checks it.
+func parseRoomTemplate(name string, data []byte) (roomTemplate, error) {
+	tpl := roomTemplate{
+		Name:      name,
+		MinDepth:  1,
+		MaxDepth:  MaxDepth,
+		Placement: PlacementAny,
+		Weight:    1,
+	}
+	lines := []string{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		lines = append(lines, strings.TrimRight(sc.Text(), "\r"))
+	}
+	if err := sc.Err(); err != nil {
+		return tpl, err
+	}
+	sep := -1
+	for i, l := range lines {
+		if l == "---" {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 {
+		return tpl, errors.New("no metadata separator line (---)")
+	}
+	kind := false
+	for i, l := range lines[:sep] {
+		fields := strings.Fields(l)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return tpl, fmt.Errorf("line %d: bad metadata line", i+1)
+		}
+		if err := tpl.setMetadata(fields[0], fields[1]); err != nil {
+			return tpl, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		if fields[0] == "kind" {
+			kind = true
+		}
+	}
+	if !kind {
+		return tpl, errors.New("missing kind")
+	}
+	tpl.Content = strings.Join(lines[sep+1:], "\n")
+	if err := tpl.Check(); err != nil {
+		return tpl, err
+	}
+	return tpl, nil
+}
+
+func (tpl *roomTemplate) setMetadata(key, value string) error {
+	switch key {
+	case "kind":
+		switch value {
+		case "normal":
+			tpl.Kind = roomKindNormal
+			return nil
+		case "big":
+			tpl.Kind = roomKindBig
+			return nil
+		}
+		for sr, name := range specialRoomNames {
+			if name == value {
+				tpl.Kind = roomKindSpecial
+				tpl.Special = sr
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown kind: %s", value)
+	case "placement":
+		for pl, name := range placementNames {
+			if name == value {
+				tpl.Placement = pl
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown placement: %s", value)
+	case "mindepth", "maxdepth", "weight":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "mindepth":
+			tpl.MinDepth = n
+		case "maxdepth":
+			tpl.MaxDepth = n
+		case "weight":
+			tpl.Weight = n
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown metadata: %s", key)
+	}
+}
+
+// AddRoomTemplates adds templates to the built-in ones.
+func AddRoomTemplates(tpls []roomTemplate) {
+	if len(tpls) == 0 {
+		return
+	}
+	roomTemplates = append(roomTemplates, tpls...)
+	CustomRoomTemplates = true
+}
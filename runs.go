@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/anaseto/gruid"
+	"github.com/anaseto/gruid/ui"
+)
+
+// runRecord is an entry of the run history, recorded at the end of every
+// finished game. The history is a text file with one JSON record per line,
+// like the following (without line breaks):
+//
+//	{"version": "v0.4.1", "date": "2021-04-03 18:12", "seed": 1234,
+//	 "outcome": "escape", "depth": 11, "turns": 5432, "kills": 3,
+//	 "achievements": ["Harmonist Novice", ...],
+//	 "spotted_perc": [12, 0, 33, ...], // per explored depth
+//	 "magaras": {"magara of blinking": 4, ...}, // number of uses
+//	 "dump": "..."}
+type runRecord struct {
+	Version      string         `json:"version"`
+	Date         string         `json:"date"`
+	Seed         int64          `json:"seed"`
+	Outcome      string         `json:"outcome"`
+	Depth        int            `json:"depth"`
+	Turns        int            `json:"turns"`
+	Kills        int            `json:"kills"`
+	Achievements []string       `json:"achievements"`
+	SpottedPerc  []int          `json:"spotted_perc"`
+	Magaras      map[string]int `json:"magaras"`
+	Preset       string         `json:"preset,omitempty"`
+	Daily        string         `json:"daily,omitempty"`
+	Dump         string         `json:"dump"`
+}
+
+// RunRecord returns the run history record of the finished game, ended at
+// the given date.
+func (g *game) RunRecord(date string) runRecord {
+	oc := ChallengeDeath
+	if g.Player.HP > 0 {
+		oc = ChallengeEscape
+	}
+	r := runRecord{
+		Version:      Version,
+		Date:         date,
+		Seed:         g.Seed,
+		Outcome:      oc.String(),
+		Depth:        max(g.Depth, g.ExploredLevels),
+		Turns:        g.Turn,
+		Kills:        g.Stats.Killed,
+		Achievements: []string{},
+		SpottedPerc:  []int{},
+		Magaras:      map[string]int{},
+		Preset:       g.Preset,
+		Daily:        g.Daily,
+		Dump:         g.Dump(),
+	}
+	for ach := range g.Stats.Achievements {
+		r.Achievements = append(r.Achievements, string(ach))
+	}
+	sort.Strings(r.Achievements)
+	for d := 1; d <= r.Depth && d <= MaxDepth; d++ {
+		r.SpottedPerc = append(r.SpottedPerc, g.Stats.DUSpottedPerc[d])
+	}
+	for mk, n := range g.Stats.UsedMagaras {
+		if n > 0 {
+			r.Magaras[magara{Kind: mk}.String()] = n
+		}
+	}
+	return r
+}
+
+// Escaped reports whether the player escaped alive in the recorded run.
+func (r runRecord) Escaped() bool {
+	return r.Outcome == ChallengeEscape.String()
+}
+
+// encodeRun returns the line of the run history file for a record.
+func encodeRun(r runRecord) ([]byte, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// parseRuns parses the run history file, as described in runRecord.
+func parseRuns(data []byte) ([]runRecord, error) {
+	runs := []runRecord{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<24)
+	for n := 1; sc.Scan(); n++ {
+		l := bytes.TrimSpace(sc.Bytes())
+		if len(l) == 0 {
+			continue
+		}
+		r := runRecord{}
+		if err := json.Unmarshal(l, &r); err != nil {
+			return nil, fmt.Errorf("runs line %d: %v", n, err)
+		}
+		runs = append(runs, r)
+	}
+	return runs, sc.Err()
+}
+
+// runSort is an ordering of the run history.
+type runSort int
+
+const (
+	RunsByDate         runSort = iota // most recent first
+	RunsByDepth                       // escapes first, then deepest, then fastest
+	RunsByTurns                       // longest first
+	RunsByAchievements                // most achievements first
+	runSortMax         = RunsByAchievements
+)
+
+var runSortNames = map[runSort]string{
+	RunsByDate:         "date",
+	RunsByDepth:        "depth",
+	RunsByTurns:        "turns",
+	RunsByAchievements: "achievements",
+}
+
+func (rs runSort) String() string {
+	return runSortNames[rs]
+}
+
+// sortRuns sorts the run history with the given ordering. Ties are broken
+// by date, most recent first.
+func sortRuns(runs []runRecord, by runSort) {
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Date > runs[j].Date })
+	var less func(r, s runRecord) bool
+	switch by {
+	case RunsByDepth:
+		less = func(r, s runRecord) bool {
+			if r.Escaped() != s.Escaped() {
+				return r.Escaped()
+			}
+			if r.Depth != s.Depth {
+				return r.Depth > s.Depth
+			}
+			return r.Turns < s.Turns
+		}
+	case RunsByTurns:
+		less = func(r, s runRecord) bool { return r.Turns > s.Turns }
+	case RunsByAchievements:
+		less = func(r, s runRecord) bool { return len(r.Achievements) > len(s.Achievements) }
+	default:
+		return
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return less(runs[i], runs[j]) })
+}
+
+// earnedAchievements returns, for each achievement, the number of recorded
+// runs in which it was earned.
+func earnedAchievements(runs []runRecord) map[achievement]int {
+	earned := map[achievement]int{}
+	for _, r := range runs {
+		for _, ach := range r.Achievements {
+			earned[achievement(ach)]++
+		}
+	}
+	return earned
+}
+
+// recordRun appends the finished game to the run history. Wizard games are
+// not recorded.
+func (g *game) recordRun(date string) error {
+	if g.Wizard {
+		return nil
+	}
+	return AppendRun(g.RunRecord(date))
+}
+
+func (md *model) openPastRuns() {
+	runs, err := LoadRuns()
+	if err != nil {
+		md.g.PrintfStyled("Error: %v", logError, err)
+		md.mode = modeNormal
+		return
+	}
+	md.runs = runs
+	md.setPastRunsEntries()
+	md.mode = modeMenu
+	md.menuMode = modePastRuns
+}
+
+func (md *model) setPastRunsEntries() {
+	sortRuns(md.runs, md.runSort)
+	entries := []ui.MenuEntry{}
+	for _, r := range md.runs {
+		entries = append(entries, ui.MenuEntry{
+			Text: ui.Textf(" %-16s  %-6s  depth %2d  turns %5d  kills %3d  achievements %2d",
+				r.Date, r.Outcome, r.Depth, r.Turns, r.Kills, len(r.Achievements)),
+		})
+	}
+	if len(entries) == 0 {
+		entries = append(entries, ui.MenuEntry{Text: ui.Text(" No finished games yet."), Disabled: true})
+	}
+	altBgEntries(entries)
+	title := fmt.Sprintf("Past Runs (by %s)", md.runSort)
+	md.runsMenu.SetBox(&ui.Box{Title: ui.Text(title).WithStyle(gruid.Style{}.WithFg(ColorYellow))})
+	md.runsMenu.SetEntries(entries)
+}
+
+func (md *model) updatePastRunsMenu(msg gruid.Msg) gruid.Effect {
+	md.runsMenu.Update(msg)
+	switch md.runsMenu.Action() {
+	case ui.MenuQuit:
+		md.mode = modeNormal
+	case ui.MenuInvoke:
+		r := md.runs[md.runsMenu.Active()]
+		lines := []ui.StyledText{}
+		for _, l := range strings.Split(r.Dump, "\n") {
+			lines = append(lines, ui.Text(l))
+		}
+		md.pager.SetBox(&ui.Box{Title: ui.Textf(" %s (%s) ", r.Date, r.Outcome).WithStyle(gruid.Style{}.WithFg(ColorYellow))})
+		md.pager.SetLines(lines)
+		md.pager.SetCursor(gruid.Point{0, 0})
+		md.mode = modePager
+		md.pagerMode = modePastRun
+	case ui.MenuPass:
+		msg, ok := msg.(gruid.MsgKeyDown)
+		if !ok {
+			return nil
+		}
+		if msg.Key == "s" || msg.Key == "S" {
+			md.runSort++
+			if md.runSort > runSortMax {
+				md.runSort = RunsByDate
+			}
+			md.setPastRunsEntries()
+			md.runsMenu.SetActive(0)
+		}
+	}
+	return nil
+}
+
+// openAchievements shows the list of achievements, marking those earned in
+// the current game and those ever earned in recorded runs.
+func (md *model) openAchievements() {
+	runs, err := LoadRuns()
+	if err != nil {
+		md.g.PrintfStyled("Error: %v", logError, err)
+	}
+	earned := earnedAchievements(runs)
+	st := gruid.Style{}
+	lines := []ui.StyledText{}
+	for _, ach := range allAchievements {
+		stt := ui.Textf(" - %s", ach).WithStyle(st.WithFg(ColorFgDark))
+		switch {
+		case md.g.Stats.Achievements[ach] > 0:
+			stt = ui.Textf(" * %s (turn %d)", ach, md.g.Stats.Achievements[ach]).WithStyle(st.WithFg(ColorGreen))
+		case earned[ach] > 0:
+			stt = ui.Textf(" + %s (past runs: %d)", ach, earned[ach])
+		}
+		lines = append(lines, stt)
+	}
+	md.smallPager.SetBox(&ui.Box{Title: ui.Text("Achievements").WithStyle(st.WithFg(ColorCyan))})
+	md.smallPager.SetLines(lines)
+	md.smallPager.SetCursor(gruid.Point{0, 0})
+	md.mode = modeSmallPager
+}
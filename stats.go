@@ -8,6 +8,9 @@ type stats struct {
 	Story             []string
 	Killed            int
 	KilledMons        map[monsterKind]int
+	SeenMons          map[monsterKind]int
+	SpottingMons      map[monsterKind]int // times spotted by each kind
+	MonsDepths        map[monsterKind]map[int]bool
 	Moves             int
 	Waits             int
 	Jumps             int
@@ -163,6 +166,53 @@ const (
 	AchAntimagicMaster     achievement = "Antimagic Master"
 )
 
+// allAchievements lists the achievements, in the order they are shown.
+var allAchievements = []achievement{
+	NoAchievement,
+	AchBananaCollector,
+	AchHarmonistNovice,
+	AchHarmonistInitiate,
+	AchHarmonistMaster,
+	AchNoviceOricCelmist,
+	AchInitiateOricCelmist,
+	AchMasterOricCelmist,
+	AchUnstealthy,
+	AchStealthNovice,
+	AchStealthInitiate,
+	AchStealthMaster,
+	AchPyromancerNovice,
+	AchPyromancerInitiate,
+	AchPyromancerMaster,
+	AchDestructorNovice,
+	AchDestructorInitiate,
+	AchDestructorMaster,
+	AchTeleport,
+	AchCloak,
+	AchAmulet,
+	AchRescuedShaedra,
+	AchRetrievedArtifact,
+	AchAcrobat,
+	AchTree,
+	AchTable,
+	AchHole,
+	AchDoors,
+	AchBarrels,
+	AchExtinguisher,
+	AchLoreStudent,
+	AchLoremaster,
+	AchNoviceExplorer,
+	AchInitiateExplorer,
+	AchMasterExplorer,
+	AchAssassin,
+	AchInsomniaNovice,
+	AchInsomniaInitiate,
+	AchInsomniaMaster,
+	AchSleepy,
+	AchAntimagicNovice,
+	AchAntimagicInitiate,
+	AchAntimagicMaster,
+}
+
 func (ach achievement) Get(g *game) {
 	if g.Stats.Achievements[ach] == 0 {
 		g.Stats.Achievements[ach] = g.Turn
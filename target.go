@@ -230,7 +230,7 @@ func (md *model) drawPosInfo() {
 			mdesc = append(mdesc, fmt.Sprintf("@hStatuses:@N %s", statuses))
 		}
 	}
-	mdesc = append(mdesc, "@hTraits:@N "+mons.traits())
+	mdesc = append(mdesc, "@hTraits:@N "+mons.Kind.traits())
 	if !md.targ.ex.scroll {
 		formatBox(t, desc, fg)
 	}
@@ -271,31 +271,31 @@ func (m *monster) statusesText() string {
 	return strings.Join(infos, ", ")
 }
 
-func (m *monster) traits() string {
+func (mk monsterKind) traits() string {
 	var info string
-	info += fmt.Sprintf("Their size is %s.", m.Kind.Size())
-	if m.Kind.Peaceful() {
+	info += fmt.Sprintf("Their size is %s.", mk.Size())
+	if mk.Peaceful() {
 		info += "They are peaceful."
 	}
-	if m.Kind.CanOpenDoors() {
+	if mk.CanOpenDoors() {
 		info += " " + "They can open doors."
 	}
-	if m.Kind.CanFly() {
+	if mk.CanFly() {
 		info += " " + "They can fly."
 	}
-	if m.Kind.CanSwim() {
+	if mk.CanSwim() {
 		info += " " + "They can swim."
 	}
-	if m.Kind.ShallowSleep() {
+	if mk.ShallowSleep() {
 		info += " " + "They have very shallow sleep."
 	}
-	if m.Kind.ResistsLignification() {
+	if mk.ResistsLignification() {
 		info += " " + "They are unaffected by lignification."
 	}
-	if m.Kind.ReflectsTeleport() {
+	if mk.ReflectsTeleport() {
 		info += " " + "They partially reflect back oric teleport magic."
 	}
-	if m.Kind.GoodFlair() {
+	if mk.GoodFlair() {
 		info += " " + "They have good flair."
 	}
 	return info
@@ -362,20 +362,41 @@ func (g *game) computePathHighlight(p gruid.Point) {
 }
 
 func (md *model) target() error {
-	g := md.g
-	p := md.targ.ex.p
+	return md.g.SetAutoTarget(md.targ.ex.p)
+}
+
+// SetAutoTarget sets p as auto-travel destination, if there is a safe path
+// to it.
+func (g *game) SetAutoTarget(p gruid.Point) error {
+	return g.setAutoTarget(p, false)
+}
+
+// SetSafeAutoTarget is like SetAutoTarget, but the travel then follows a path
+// avoiding places where known monsters could notice the player, as computed
+// by SafePlayerPath.
+func (g *game) SetSafeAutoTarget(p gruid.Point) error {
+	return g.setAutoTarget(p, true)
+}
+
+func (g *game) setAutoTarget(p gruid.Point, safe bool) error {
 	if !explored(g.Dungeon.Cell(p)) {
 		return errors.New("You do not know this place.")
 	}
 	if terrain(g.Dungeon.Cell(p)) == WallCell && !g.Player.HasStatus(StatusDig) {
 		return errors.New("You cannot travel into a wall.")
 	}
-	path := g.PlayerPath(g.Player.P, p)
+	var path []gruid.Point
+	if safe {
+		path = g.SafePlayerPath(g.Player.P, p)
+	} else {
+		path = g.PlayerPath(g.Player.P, p)
+	}
 	if len(path) == 0 {
 		return errors.New("There is no safe path to this place.")
 	}
 	if c := g.Dungeon.Cell(p); explored(c) && terrain(c) != WallCell {
 		g.AutoTarget = p
+		g.AutoSafe = safe
 		return nil
 	}
 	return errors.New("Invalid destination.")
@@ -466,8 +487,8 @@ func (md *model) nextObject(np gruid.Point, data *examination) {
 	md.Examine(np)
 }
 
-func (md *model) excludeZone(p gruid.Point) {
-	g := md.g
+// ExcludeZone excludes from auto-travel the area seen from p.
+func (g *game) ExcludeZone(p gruid.Point) {
 	if !explored(g.Dungeon.Cell(p)) {
 		g.Print("You cannot choose an unexplored cell for exclusion.")
 	} else {
@@ -475,9 +496,10 @@ func (md *model) excludeZone(p gruid.Point) {
 	}
 }
 
-func (md *model) clearExcludeZone(p gruid.Point) {
+// ClearExcludeZone clears exclusions around p.
+func (g *game) ClearExcludeZone(p gruid.Point) {
 	rg := visionRange(p, DefaultMonsterLOSRange)
 	rg.Iter(func(p gruid.Point) {
-		delete(md.g.ExclusionsMap, p)
+		delete(g.ExclusionsMap, p)
 	})
 }
@@ -112,6 +112,9 @@ const (
 )
 
 func map16ColorTo256(c gruid.Color, fg bool) gruid.Color {
+	if pc, ok := CurrentTheme.palette(c, fg); ok {
+		return pc.X256
+	}
 	switch c {
 	case ColorBackground:
 		if fg {
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/anaseto/gruid"
+)
+
+// theme is a named color theme. It changes the palette colors used for each
+// semantic color, as well as the rendering of palette colors with tiles and
+// in xterm 256-color terminals, in dark and light modes. Other terminals use
+// their own palette, so only semantic colors can be changed for them.
+type theme struct {
+	Name   string
+	Desc   string
+	Colors map[string]gruid.Color  // semantic color name -> palette color
+	Dark   map[string]paletteColor // palette color name -> rendering in dark mode
+	Light  map[string]paletteColor // palette color name -> rendering in light mode
+	Custom bool                    // loaded from a file
+}
+
+// paletteColor is the rendering of a palette color: an RGB color with tiles,
+// and a color index in xterm 256-color terminals.
+type paletteColor struct {
+	RGB  color.RGBA
+	X256 gruid.Color
+}
+
+// builtinThemes describes the built-in themes, in the same format as theme
+// files. The colorblind-friendly themes use colors of the Okabe-Ito palette.
+const builtinThemes = `
+theme solarized
+desc The default solarized colors.
+
+theme red-green
+desc For red-green color blindness (deuteranopia and protanopia): good
+desc statuses are blue, and bad ones vermillion.
+color fg-hp-ok blue
+color fg-hp-wounded yellow
+color fg-hp-critical red
+color fg-confused-monster magenta
+color fg-lignified-monster green
+color fg-wandering-monster yellow
+dark red #D55E00 166
+light red #D55E00 166
+dark orange #E69F00 214
+light orange #E69F00 172
+dark green #009E73 36
+light green #009E73 36
+dark blue #56B4E9 74
+light blue #0072B2 25
+dark yellow #F0E442 185
+dark magenta #CC79A7 175
+light magenta #CC79A7 175
+
+theme blue-yellow
+desc For blue-yellow color blindness (tritanopia): good statuses are cyan,
+desc partial ones yellow, and bad ones red.
+color fg-hp-ok cyan
+color fg-mp-ok cyan
+color fg-mp-partial yellow
+color fg-mp-critical red
+color fg-status-good cyan
+color fg-status-expire magenta
+dark cyan #56B4E9 74
+light cyan #0072B2 25
+`
+
+// themes contains the available color themes.
+var themes []*theme
+
+// CurrentTheme is the theme in use.
+var CurrentTheme *theme
+
+func init() {
+	ths, err := parseThemes([]byte(builtinThemes), nil)
+	if err != nil {
+		panic(fmt.Sprintf("built-in themes: %v", err))
+	}
+	themes = ths
+	CurrentTheme = themes[0]
+}
+
+// paletteNames maps the names used in theme files to palette colors. The
+// background and foreground are both the terminal's default color.
+var paletteNames = map[string]gruid.Color{
+	"background":           ColorBackground,
+	"foreground":           ColorForeground,
+	"background-secondary": ColorBackgroundSecondary,
+	"foreground-secondary": ColorForegroundSecondary,
+	"foreground-emph":      ColorForegroundEmph,
+	"yellow":               ColorYellow,
+	"orange":               ColorOrange,
+	"red":                  ColorRed,
+	"magenta":              ColorMagenta,
+	"violet":               ColorViolet,
+	"blue":                 ColorBlue,
+	"cyan":                 ColorCyan,
+	"green":                ColorGreen,
+}
+
+// paletteName returns the name of palette color c, used either as
+// foreground or background.
+func paletteName(c gruid.Color, fg bool) string {
+	if c == gruid.ColorDefault {
+		if fg {
+			return "foreground"
+		}
+		return "background"
+	}
+	for name, pc := range paletteNames {
+		if pc == c {
+			return name
+		}
+	}
+	return ""
+}
+
+// semanticColors maps the names used in theme files to semantic colors.
+var semanticColors = map[string]*gruid.Color{
+	"bg":                      &ColorBg,
+	"bg-dark":                 &ColorBgDark,
+	"bg-los":                  &ColorBgLOS,
+	"bg-hunting-los":          &ColorBgHuntingLOS,
+	"bg-monster-los":          &ColorBgMonsterLOS,
+	"bg-noise":                &ColorBgNoise,
+	"fg":                      &ColorFg,
+	"fg-object":               &ColorFgObject,
+	"fg-tree":                 &ColorFgTree,
+	"fg-confused-monster":     &ColorFgConfusedMonster,
+	"fg-lignified-monster":    &ColorFgLignifiedMonster,
+	"fg-paralysed-monster":    &ColorFgParalysedMonster,
+	"fg-dark":                 &ColorFgDark,
+	"fg-excluded":             &ColorFgExcluded,
+	"fg-explosion-end":        &ColorFgExplosionEnd,
+	"fg-explosion-start":      &ColorFgExplosionStart,
+	"fg-explosion-wall-end":   &ColorFgExplosionWallEnd,
+	"fg-explosion-wall-start": &ColorFgExplosionWallStart,
+	"fg-hp-critical":          &ColorFgHPcritical,
+	"fg-hp-ok":                &ColorFgHPok,
+	"fg-hp-wounded":           &ColorFgHPwounded,
+	"fg-los":                  &ColorFgLOS,
+	"fg-los-light":            &ColorFgLOSLight,
+	"fg-mp-critical":          &ColorFgMPcritical,
+	"fg-mp-ok":                &ColorFgMPok,
+	"fg-mp-partial":           &ColorFgMPpartial,
+	"fg-magic-place":          &ColorFgMagicPlace,
+	"fg-monster":              &ColorFgMonster,
+	"fg-place":                &ColorFgPlace,
+	"fg-player":               &ColorFgPlayer,
+	"fg-bananas":              &ColorFgBananas,
+	"fg-sleeping-monster":     &ColorFgSleepingMonster,
+	"fg-status-bad":           &ColorFgStatusBad,
+	"fg-status-good":          &ColorFgStatusGood,
+	"fg-status-expire":        &ColorFgStatusExpire,
+	"fg-status-other":         &ColorFgStatusOther,
+	"fg-wandering-monster":    &ColorFgWanderingMonster,
+}
+
+// parseThemes parses a themes file, returning the updated themes. The file
+// is made of blocks starting with a "theme NAME" line, each followed by
+// lines of the form:
+//
+//	desc TEXT                description (several lines are joined)
+//	color NAME PALETTE       use palette color PALETTE for semantic color NAME
+//	dark PALETTE #RRGGBB N   render PALETTE in dark mode as the given RGB color
+//	                         with tiles, and as color N in 256-color terminals
+//	light PALETTE #RRGGBB N  same as dark, but in light mode
+//
+// Palette colors are named as in paletteNames, and semantic colors as in
+// semanticColors (for example "fg-hp-ok" for the color of the health bar when
+// unwounded). For example:
+//
+//	theme calm
+//	desc No red.
+//	color fg-hp-critical magenta
+//	dark magenta #CC79A7 175
+//
+// A block with the name of an existing theme replaces it. Empty lines and
+// lines starting with '#' are ignored.
+func parseThemes(data []byte, ths []*theme) ([]*theme, error) {
+	ths = append([]*theme{}, ths...)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	var cur *theme
+	descs := map[*theme][]string{}
+	for i := 1; sc.Scan(); i++ {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		fields := strings.Fields(l)
+		key := fields[0]
+		value := strings.TrimSpace(strings.TrimPrefix(l, key))
+		if key == "theme" {
+			if value == "" {
+				return nil, fmt.Errorf("line %d: missing theme name", i)
+			}
+			cur = &theme{Name: value, Colors: map[string]gruid.Color{},
+				Dark: map[string]paletteColor{}, Light: map[string]paletteColor{}}
+			replaced := false
+			for j, th := range ths {
+				if strings.EqualFold(th.Name, value) {
+					ths[j] = cur
+					replaced = true
+				}
+			}
+			if !replaced {
+				ths = append(ths, cur)
+			}
+			continue
+		}
+		if cur == nil {
+			return nil, fmt.Errorf("line %d: line outside of theme block", i)
+		}
+		if key == "desc" {
+			descs[cur] = append(descs[cur], value)
+			continue
+		}
+		if err := cur.addLine(key, fields[1:]); err != nil {
+			return nil, fmt.Errorf("line %d: %v", i, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for th, lines := range descs {
+		th.Desc = strings.Join(lines, " ")
+	}
+	return ths, nil
+}
+
+func (th *theme) addLine(key string, values []string) error {
+	switch key {
+	case "color":
+		if len(values) != 2 {
+			return fmt.Errorf("bad number of values for %s", key)
+		}
+		if _, ok := semanticColors[values[0]]; !ok {
+			return fmt.Errorf("unknown semantic color: %s", values[0])
+		}
+		c, ok := paletteNames[values[1]]
+		if !ok {
+			return fmt.Errorf("unknown palette color: %s", values[1])
+		}
+		th.Colors[values[0]] = c
+	case "dark", "light":
+		if len(values) != 3 {
+			return fmt.Errorf("bad number of values for %s", key)
+		}
+		if _, ok := paletteNames[values[0]]; !ok {
+			return fmt.Errorf("unknown palette color: %s", values[0])
+		}
+		rgb, err := parseRGB(values[1])
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(values[2])
+		if err != nil {
+			return err
+		}
+		if n < 0 || n > 255 {
+			return fmt.Errorf("256-color index out of range: %d", n)
+		}
+		pc := paletteColor{RGB: rgb, X256: gruid.Color(n)}
+		if key == "dark" {
+			th.Dark[values[0]] = pc
+		} else {
+			th.Light[values[0]] = pc
+		}
+	default:
+		return fmt.Errorf("unknown key: %s", key)
+	}
+	return nil
+}
+
+// parseRGB parses a color of the form #RRGGBB.
+func parseRGB(s string) (color.RGBA, error) {
+	if len(s) != 7 || s[0] != '#' {
+		return color.RGBA{}, fmt.Errorf("bad RGB color: %s", s)
+	}
+	n, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("bad RGB color: %s", s)
+	}
+	return color.RGBA{uint8(n >> 16), uint8(n >> 8), uint8(n), 255}, nil
+}
+
+// LoadThemes adds the themes of a themes file to the available ones.
+func LoadThemes(data []byte) error {
+	ths, err := parseThemes(data, themes)
+	if err != nil {
+		return fmt.Errorf("themes: %v", err)
+	}
+	old := map[*theme]bool{}
+	for _, th := range themes {
+		old[th] = true
+	}
+	for _, th := range ths {
+		if !old[th] {
+			th.Custom = true
+		}
+	}
+	themes = ths
+	return nil
+}
+
+// themeByName returns the theme with the given name, or nil if there is
+// none.
+func themeByName(name string) *theme {
+	for _, th := range themes {
+		if strings.EqualFold(th.Name, name) {
+			return th
+		}
+	}
+	return nil
+}
+
+// palette returns the rendering of palette color c, used either as
+// foreground or background, in the current dark or light mode. It reports
+// false if the theme keeps the default rendering.
+func (th *theme) palette(c gruid.Color, fg bool) (paletteColor, bool) {
+	if th == nil {
+		return paletteColor{}, false
+	}
+	colors := th.Light
+	if GameConfig.DarkLOS {
+		colors = th.Dark
+	}
+	pc, ok := colors[paletteName(c, fg)]
+	return pc, ok
+}
+
+// apply sets the semantic colors of the theme.
+func (th *theme) apply() {
+	setDefaultColors()
+	for name, c := range th.Colors {
+		*semanticColors[name] = c
+	}
+	CurrentTheme = th
+}
+
+// nextTheme returns the theme following the current one.
+func nextTheme() *theme {
+	for i, th := range themes {
+		if th == CurrentTheme {
+			return themes[(i+1)%len(themes)]
+		}
+	}
+	return themes[0]
+}
@@ -31,6 +31,9 @@ func (md *model) ApplyToggleTiles() {
 }
 
 func ColorToRGBA(c gruid.Color, fg bool) color.Color {
+	if pc, ok := CurrentTheme.palette(c, fg); ok {
+		return pc.RGB
+	}
 	cl := color.RGBA{}
 	opaque := uint8(255)
 	switch c {